@@ -4,7 +4,7 @@ echo Building devrag...
 if not exist bin mkdir bin
 
 set LDFLAGS=-s -w
-set TAGS=netgo
+set TAGS=netgo,sqlite_fts5
 
 REM Version info
 if "%VERSION%"=="" set VERSION=1.0.0
@@ -9,7 +9,7 @@ mkdir -p bin
 
 # Build flags
 LDFLAGS="-s -w"
-TAGS=""
+TAGS="sqlite_fts5"
 
 # Version info
 VERSION=${VERSION:-"1.0.0"}
@@ -26,17 +26,17 @@ echo ""
 # Note: CGO is required for sqlite-vec, so cross-compilation is limited
 # Build for current platform first
 echo "Building for current platform..."
-CGO_ENABLED=1 go build -ldflags="$LDFLAGS" -o bin/devrag cmd/main.go
+CGO_ENABLED=1 go build -tags "$TAGS" -ldflags="$LDFLAGS" -o bin/devrag ./cmd
 
 # macOS (Apple Silicon) - only on macOS arm64
 if [[ "$OSTYPE" == "darwin"* ]] && [[ "$(uname -m)" == "arm64" ]]; then
   echo "Building for macOS (arm64)..."
-  CGO_ENABLED=1 GOOS=darwin GOARCH=arm64 go build -ldflags="$LDFLAGS" \
-    -o bin/devrag-darwin-arm64 cmd/main.go
+  CGO_ENABLED=1 GOOS=darwin GOARCH=arm64 go build -tags "$TAGS" -ldflags="$LDFLAGS" \
+    -o bin/devrag-darwin-arm64 ./cmd
 
   echo "Building for macOS (amd64)..."
-  CGO_ENABLED=1 GOOS=darwin GOARCH=amd64 go build -ldflags="$LDFLAGS" \
-    -o bin/devrag-darwin-amd64 cmd/main.go
+  CGO_ENABLED=1 GOOS=darwin GOARCH=amd64 go build -tags "$TAGS" -ldflags="$LDFLAGS" \
+    -o bin/devrag-darwin-amd64 ./cmd
 fi
 
 # Note: For Windows and Linux builds from macOS, you would need:
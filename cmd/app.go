@@ -0,0 +1,175 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/tomohiro-owada/devrag/internal/config"
+	"github.com/tomohiro-owada/devrag/internal/embedder"
+	"github.com/tomohiro-owada/devrag/internal/indexer"
+	"github.com/tomohiro-owada/devrag/internal/vectordb"
+)
+
+// settingsPolicy selects how openApp treats an index built with different
+// embedding settings
+type settingsPolicy int
+
+const (
+	// skipSettings does not load the model; for commands that never embed
+	skipSettings settingsPolicy = iota
+	// checkSettings refuses an index built with different settings
+	checkSettings
+	// ensureSettings rebuilds or refuses the index as selected by model.on_mismatch
+	ensureSettings
+)
+
+// app holds the components shared by all commands
+type app struct {
+	cfg *config.Config
+	db  *vectordb.DB
+	emb embedder.Embedder // nil if opened with skipSettings
+	idx *indexer.Indexer
+}
+
+// loadConfig loads the configuration, applies command-line overrides and validates it
+// The config file is the one given with -config, or the one found by config.FindConfigFiles
+func loadConfig(override func(cfg *config.Config)) (*config.Config, error) {
+	cfg, err := config.LoadFrom(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	// Command-line flags take precedence over config files and the environment
+	if override != nil {
+		override(cfg)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	for _, adjustment := range cfg.Clamp() {
+		fmt.Fprintf(os.Stderr, "[WARN] Configuration: %v\n", adjustment)
+	}
+
+	fmt.Fprintf(os.Stderr, "[INFO] Configuration loaded successfully\n")
+	if len(cfg.Sources) > 0 {
+		for _, source := range cfg.Sources {
+			fmt.Fprintf(os.Stderr, "[INFO] Source: %s (include: %v, exclude: %v)\n", source.Path, source.Include, source.Exclude)
+		}
+	} else {
+		fmt.Fprintf(os.Stderr, "[INFO] Documents directory: %s\n", cfg.DocumentsDir)
+	}
+	fmt.Fprintf(os.Stderr, "[INFO] Database path: %s\n", cfg.DBPath)
+	fmt.Fprintf(os.Stderr, "[INFO] Models directory: %s\n", cfg.ModelsDir)
+	fmt.Fprintf(os.Stderr, "[INFO] Model: %s (dimensions: %d)\n", cfg.Model.Name, cfg.Model.Dimensions)
+	fmt.Fprintf(os.Stderr, "[INFO] Device: %s\n", cfg.Compute.Device)
+	if cfg.ReadOnly {
+		fmt.Fprintf(os.Stderr, "[INFO] Read-only mode: documents and the index will not be modified\n")
+	}
+	return cfg, nil
+}
+
+// openApp opens the index and, unless policy is skipSettings, loads the embedding
+// model and checks that the index was built with its settings
+func openApp(cfg *config.Config, policy settingsPolicy) (*app, error) {
+	// Resolve the embedding model (config.Validate has checked its name and dimensions)
+	spec, err := embedder.LookupModel(cfg.Model.Name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	// Ensure documents directory exists (configured sources must exist already)
+	if !cfg.ReadOnly && len(cfg.Sources) == 0 {
+		if err := os.MkdirAll(cfg.DocumentsDir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create documents directory: %w", err)
+		}
+	}
+
+	// Initialize database
+	var db *vectordb.DB
+	if cfg.ReadOnly {
+		db, err = vectordb.OpenReadOnly(cfg.DBPath)
+	} else {
+		if err := cfg.CheckWritable(); err != nil {
+			return nil, fmt.Errorf("invalid configuration: %w", err)
+		}
+		db, err = vectordb.Init(cfg.DBPath, spec.Dimensions)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+
+	a := &app{cfg: cfg, db: db}
+	if policy == skipSettings {
+		a.idx = indexer.NewIndexer(db, nil, cfg)
+		return a, nil
+	}
+
+	if err := a.loadEmbedder(spec, policy); err != nil {
+		a.Close()
+		return nil, err
+	}
+	return a, nil
+}
+
+// loadEmbedder loads the embedding model, downloading it if needed, and checks
+// the embedding settings of the index
+func (a *app) loadEmbedder(spec embedder.ModelSpec, policy settingsPolicy) error {
+	modelDir := filepath.Join(a.cfg.ModelsDir, spec.Name)
+	if err := embedder.DownloadModelFiles(modelDir, spec); err != nil {
+		return fmt.Errorf("failed to download model files: %w", err)
+	}
+
+	device := embedder.DetectDevice(a.cfg.Compute.Device, a.cfg.Compute.FallbackToCPU)
+	fmt.Fprintf(os.Stderr, "[INFO] Using device: %s\n", device)
+
+	// Note: Model file is required for production use
+	// For testing purposes, we'll use mock embedder if model is not available
+	settings := spec.Settings(a.cfg.Model.UsePrefixes)
+	modelPath := filepath.Join(modelDir, "model.onnx")
+	if _, err := os.Stat(modelPath); err == nil {
+		onnxEmb, err := embedder.NewONNXEmbedder(modelPath, spec, device, a.cfg.Compute.BatchSize)
+		if err != nil {
+			return fmt.Errorf("failed to initialize embedder: %w", err)
+		}
+		if a.cfg.Model.UsePrefixes {
+			onnxEmb.SetPrefixes(spec.QueryPrefix, spec.PassagePrefix)
+		}
+		a.emb = onnxEmb
+		fmt.Fprintf(os.Stderr, "[INFO] Loaded ONNX model from %s\n", modelPath)
+	} else {
+		fmt.Fprintf(os.Stderr, "[WARN] Model not found at %s, using mock embedder\n", modelPath)
+		a.emb = &embedder.MockEmbedder{Dimensions: spec.Dimensions}
+		settings = embedder.Settings{Model: embedder.MockModel, Dimensions: spec.Dimensions}
+	}
+
+	a.idx = indexer.NewIndexer(a.db, a.emb, a.cfg)
+
+	// A read-only index cannot be rebuilt, so it must already match the current model
+	if policy == checkSettings || a.cfg.ReadOnly {
+		if err := a.idx.CheckEmbeddingSettings(settings); err != nil {
+			return fmt.Errorf("failed to check embedding settings: %w (run devrag sync without read-only mode to rebuild the index)", err)
+		}
+		return nil
+	}
+
+	// Rebuild the index if it was embedded with different settings
+	if _, err := a.idx.EnsureEmbeddingSettings(settings); err != nil {
+		if errors.Is(err, indexer.ErrEmbeddingMismatch) {
+			return fmt.Errorf("failed to check embedding settings: %w (set model.on_mismatch to %q or delete %s to rebuild the index)",
+				err, config.OnMismatchRebuild, a.cfg.DBPath)
+		}
+		return fmt.Errorf("failed to check embedding settings: %w", err)
+	}
+	return nil
+}
+
+// Close releases the embedder and the database
+func (a *app) Close() {
+	if a.emb != nil {
+		a.emb.Close()
+	}
+	a.db.Close()
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tomohiro-owada/devrag/internal/config"
+	"github.com/tomohiro-owada/devrag/internal/indexer"
+	"github.com/tomohiro-owada/devrag/internal/vectordb"
+)
+
+type PlaceholderEmbedder struct {
+	dimensions int
+	callCount  int
+}
+
+func (e *PlaceholderEmbedder) Embed(text string) ([]float32, error) {
+	e.callCount++
+	vec := make([]float32, e.dimensions)
+	for i := range vec {
+		vec[i] = float32((len(text)+i)%100) / 100.0
+	}
+	return vec, nil
+}
+
+func (e *PlaceholderEmbedder) EmbedBatch(texts []string) ([][]float32, error) {
+	results := make([][]float32, len(texts))
+	for i, text := range texts {
+		emb, err := e.Embed(text)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = emb
+	}
+	return results, nil
+}
+
+func (e *PlaceholderEmbedder) EmbedQuery(text string) ([]float32, error) {
+	return e.Embed(text)
+}
+
+func (e *PlaceholderEmbedder) EmbedDocuments(texts []string) ([][]float32, error) {
+	return e.EmbedBatch(texts)
+}
+
+func (e *PlaceholderEmbedder) Close() error {
+	return nil
+}
+
+var (
+	searchSizes   = flag.String("search-sizes", "10000,100000,1000000", "comma-separated chunk counts for the search benchmark (empty to skip)")
+	searchQueries = flag.Int("search-queries", 100, "number of queries per search benchmark")
+)
+
+func main() {
+	flag.Parse()
+
+	fmt.Printf("=== Indexing Performance Test ===\n\n")
+
+	// Setup
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[FATAL] Failed to load config: %v\n", err)
+		os.Exit(1)
+	}
+	cfg.DBPath = "./benchmark.db"
+	cfg.ChunkSize = 500
+
+	// Start from an empty database and leave none behind
+	os.Remove(cfg.DBPath)
+	defer os.Remove(cfg.DBPath)
+
+	db, err := vectordb.Init(cfg.DBPath, 384)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[FATAL] Failed to initialize database: %v\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	emb := &PlaceholderEmbedder{dimensions: 384}
+	idx := indexer.NewIndexer(db, emb, cfg)
+
+	// Test 1: Single small document
+	fmt.Println("[Test 1] Single small document")
+	content1 := generateContent(300) // ~300 chars
+	testFile1 := "./bench_small.md"
+	os.WriteFile(testFile1, []byte(content1), 0644)
+	defer os.Remove(testFile1)
+
+	start := time.Now()
+	idx.IndexFile(testFile1)
+	t1 := time.Since(start)
+	fmt.Printf("  Time: %v\n\n", t1)
+
+	// Test 2: Single medium document
+	fmt.Println("[Test 2] Single medium document")
+	content2 := generateContent(2000) // ~2KB
+	testFile2 := "./bench_medium.md"
+	os.WriteFile(testFile2, []byte(content2), 0644)
+	defer os.Remove(testFile2)
+
+	start = time.Now()
+	idx.IndexFile(testFile2)
+	t2 := time.Since(start)
+	fmt.Printf("  Time: %v\n\n", t2)
+
+	// Test 3: Single large document
+	fmt.Println("[Test 3] Single large document")
+	content3 := generateContent(10000) // ~10KB
+	testFile3 := "./bench_large.md"
+	os.WriteFile(testFile3, []byte(content3), 0644)
+	defer os.Remove(testFile3)
+
+	start = time.Now()
+	idx.IndexFile(testFile3)
+	t3 := time.Since(start)
+	fmt.Printf("  Time: %v\n\n", t3)
+
+	// Statistics
+	docs, _ := db.ListDocuments()
+
+	fmt.Println("=== Summary ===")
+	fmt.Printf("Total documents indexed: %d\n", len(docs))
+	fmt.Printf("Total embeddings generated: %d\n", emb.callCount)
+	fmt.Printf("\nPerformance:\n")
+	fmt.Printf("  Small doc:  %v (%.2f ms)\n", t1, float64(t1.Microseconds())/1000.0)
+	fmt.Printf("  Medium doc: %v (%.2f ms)\n", t2, float64(t2.Microseconds())/1000.0)
+	fmt.Printf("  Large doc:  %v (%.2f ms)\n", t3, float64(t3.Microseconds())/1000.0)
+
+	// Calculate throughput
+	totalTime := t1 + t2 + t3
+	chunksPerSec := float64(emb.callCount) / totalTime.Seconds()
+	fmt.Printf("\nThroughput: %.0f chunks/sec\n", chunksPerSec)
+
+	// Search latency at increasing index sizes
+	if *searchSizes != "" {
+		fmt.Printf("\n=== Search Performance Test ===\n\n")
+		for _, field := range strings.Split(*searchSizes, ",") {
+			size, err := strconv.Atoi(strings.TrimSpace(field))
+			if err != nil || size <= 0 {
+				fmt.Fprintf(os.Stderr, "[WARN] Invalid search size: %q\n", field)
+				continue
+			}
+			if err := benchmarkSearch(size, *searchQueries); err != nil {
+				fmt.Fprintf(os.Stderr, "[ERROR] Search benchmark with %d chunks failed: %v\n", size, err)
+			}
+		}
+	}
+}
+
+// benchmarkSearch fills a fresh database with random vectors and measures KNN query latency
+func benchmarkSearch(numChunks, numQueries int) error {
+	const chunksPerDoc = 100
+	const dimensions = 384
+	domains := []string{"frontend", "backend", "mobile", "infrastructure"}
+
+	dbPath := fmt.Sprintf("./benchmark_search_%d.db", numChunks)
+	os.Remove(dbPath)
+	defer os.Remove(dbPath)
+
+	db, err := vectordb.Init(dbPath, dimensions)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	fmt.Printf("[%d chunks]\n", numChunks)
+
+	rng := rand.New(rand.NewSource(42))
+
+	start := time.Now()
+	for docIdx := 0; docIdx*chunksPerDoc < numChunks; docIdx++ {
+		n := chunksPerDoc
+		if remaining := numChunks - docIdx*chunksPerDoc; remaining < n {
+			n = remaining
+		}
+
+		chunks := make([]vectordb.ChunkInterface, n)
+		vectors := make([][]float32, n)
+		for i := 0; i < n; i++ {
+			chunks[i] = indexer.Chunk{Content: fmt.Sprintf("chunk %d of document %d", i, docIdx), Position: i}
+			vectors[i] = randomUnitVector(rng, dimensions)
+		}
+
+		metadata := &vectordb.DocumentMetadata{Domain: domains[docIdx%len(domains)]}
+		filename := fmt.Sprintf("doc_%06d.md", docIdx)
+		if err := db.InsertDocument(filename, time.Now(), "", metadata, chunks, vectors); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("  Insert time: %v\n", time.Since(start))
+
+	filter, err := vectordb.ParseFilter("domain=backend")
+	if err != nil {
+		return err
+	}
+
+	cases := []struct {
+		name    string
+		filters []vectordb.Filter
+	}{
+		{"KNN (top 5)", nil},
+		{"KNN + domain filter (top 5)", []vectordb.Filter{filter}},
+	}
+
+	for _, c := range cases {
+		latencies := make([]time.Duration, 0, numQueries)
+		for i := 0; i < numQueries; i++ {
+			query := vectordb.SearchQuery{
+				Vector:  randomUnitVector(rng, dimensions),
+				TopK:    5,
+				Filters: c.filters,
+			}
+
+			start := time.Now()
+			if _, err := db.Search(query); err != nil {
+				return err
+			}
+			latencies = append(latencies, time.Since(start))
+		}
+
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		fmt.Printf("  %-28s p50: %v  p95: %v  max: %v\n", c.name,
+			latencies[len(latencies)/2],
+			latencies[len(latencies)*95/100],
+			latencies[len(latencies)-1])
+	}
+	fmt.Println()
+
+	return nil
+}
+
+// randomUnitVector returns a random L2-normalized vector
+func randomUnitVector(rng *rand.Rand, dimensions int) []float32 {
+	vec := make([]float32, dimensions)
+	var norm float64
+	for i := range vec {
+		vec[i] = float32(rng.NormFloat64())
+		norm += float64(vec[i]) * float64(vec[i])
+	}
+	scale := float32(1 / math.Sqrt(norm))
+	for i := range vec {
+		vec[i] *= scale
+	}
+	return vec
+}
+
+func generateContent(targetSize int) string {
+	var content string
+	content += "# Performance Test Document\n\n"
+
+	for i := 1; len(content) < targetSize; i++ {
+		content += fmt.Sprintf("## Section %d\n\n", i)
+		content += "This is a test paragraph with some content to generate a document of appropriate size. "
+		content += "It contains multiple sentences to make the chunking more realistic. "
+		content += "The content is repetitive but that's acceptable for performance testing.\n\n"
+	}
+
+	return content
+}
@@ -0,0 +1,543 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tomohiro-owada/devrag/internal/indexer"
+	"github.com/tomohiro-owada/devrag/internal/sources"
+	"github.com/tomohiro-owada/devrag/internal/vectordb"
+)
+
+// runIndex indexes the given markdown files and the markdown files below the
+// given directories
+func runIndex(args []string) error {
+	flags := newFlagSet("index")
+	asJSON := flags.Bool("json", false, "print results as JSON")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return exitStatus(2)
+	}
+
+	a, err := openWritableApp("index", ensureSettings)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	var files []string
+	for _, arg := range flags.Args() {
+		path, err := documentPath(arg, a.idx.Sources())
+		if err != nil {
+			return err
+		}
+		found, err := sourceFiles(path, a.idx.Sources())
+		if err != nil {
+			return err
+		}
+		files = append(files, found...)
+	}
+
+	type fileResult struct {
+		File string `json:"file"`
+		*indexer.IndexResult
+		Error string `json:"error,omitempty"`
+	}
+	results := []fileResult{}
+	failed := false
+	a.idx.IndexFiles(files, func(file string, result *indexer.IndexResult, err error) {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[ERROR] Failed to index %s: %v\n", file, err)
+			results = append(results, fileResult{File: file, Error: err.Error()})
+			failed = true
+			return
+		}
+		results = append(results, fileResult{File: file, IndexResult: result})
+	})
+	sort.Slice(results, func(i, j int) bool { return results[i].File < results[j].File })
+
+	if *asJSON {
+		if err := printJSON(results); err != nil {
+			return err
+		}
+	} else {
+		for _, r := range results {
+			if r.Error != "" {
+				fmt.Printf("%s: failed: %s\n", r.File, r.Error)
+				continue
+			}
+			fmt.Printf("%s: %d chunks (%d reused, %d embedded)\n", r.File, r.Chunks, r.Reused, r.Embedded)
+		}
+	}
+
+	if failed {
+		return exitStatus(1)
+	}
+	return nil
+}
+
+// runSync brings the index up to date with the documents directory
+func runSync(args []string) error {
+	flags := newFlagSet("sync")
+	asJSON := flags.Bool("json", false, "print the result as JSON")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	a, err := openWritableApp("sync", ensureSettings)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	result, err := a.idx.Sync()
+	if err != nil {
+		return fmt.Errorf("sync failed: %w", err)
+	}
+
+	if *asJSON {
+		if err := printJSON(result); err != nil {
+			return err
+		}
+	} else {
+		fmt.Printf("Added:   %d\n", len(result.Added))
+		fmt.Printf("Updated: %d\n", len(result.Updated))
+		fmt.Printf("Deleted: %d\n", len(result.Deleted))
+		fmt.Printf("Touched: %d\n", len(result.Touched))
+		fmt.Printf("Failed:  %d\n", len(result.Failed))
+		for _, f := range result.Failed {
+			fmt.Printf("  %s: %s\n", f.File, f.Error)
+		}
+		fmt.Printf("Chunks:  %d reused, %d embedded\n", result.ReusedChunks, result.EmbeddedChunks)
+	}
+
+	if len(result.Failed) > 0 {
+		return exitStatus(1)
+	}
+	return nil
+}
+
+// runSearch searches the index and prints the results
+func runSearch(args []string) error {
+	flags := newFlagSet("search")
+	asJSON := flags.Bool("json", false, "print results as JSON")
+	topK := flags.Int("top-k", 0, "maximum number of results (default search_top_k)")
+	modeName := flags.String("mode", "", "search mode: vector, keyword or hybrid (default vector)")
+	contextWindow := flags.Int("context-window", 0, "neighboring chunks to include before and after each hit")
+	var filterExprs stringList
+	flags.Var(&filterExprs, "filter", "frontmatter filter such as \"domain=backend\" (repeatable, combined with AND)")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return exitStatus(2)
+	}
+	query := strings.Join(flags.Args(), " ")
+
+	mode, err := vectordb.ParseSearchMode(*modeName)
+	if err != nil {
+		return err
+	}
+	if *contextWindow < 0 {
+		return fmt.Errorf("-context-window must not be negative")
+	}
+	var filters []vectordb.Filter
+	for _, expr := range filterExprs {
+		filter, err := vectordb.ParseFilter(expr)
+		if err != nil {
+			return err
+		}
+		filters = append(filters, filter)
+	}
+
+	cfg, err := loadConfig(nil)
+	if err != nil {
+		return err
+	}
+	if *topK <= 0 {
+		*topK = cfg.SearchTopK
+	}
+
+	a, err := openApp(cfg, checkSettings)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	results, err := a.idx.Search(vectordb.SearchQuery{
+		Text:          query,
+		TopK:          *topK,
+		Mode:          mode,
+		Filters:       filters,
+		ContextWindow: *contextWindow,
+	})
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		return printJSON(results)
+	}
+	if len(results) == 0 {
+		fmt.Printf("No results\n")
+	}
+	for i, r := range results {
+		fmt.Printf("%d. %s (score %.4f, chunk %d)\n", i+1, r.DocumentName, r.Score, r.ChunkID)
+		if r.HeadingPath != "" {
+			fmt.Printf("   %s\n", r.HeadingPath)
+		}
+		content := r.ChunkContent
+		if r.Context != "" {
+			content = r.Context
+		}
+		fmt.Printf("   %s\n\n", preview(content, 200))
+	}
+	return nil
+}
+
+// runStats prints statistics of the index without loading the model
+func runStats(args []string) error {
+	flags := newFlagSet("stats")
+	asJSON := flags.Bool("json", false, "print statistics as JSON")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(nil)
+	if err != nil {
+		return err
+	}
+	a, err := openApp(cfg, skipSettings)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	stats, err := a.db.Stats()
+	if err != nil {
+		return err
+	}
+	model, err := a.idx.RecordedModel()
+	if err != nil {
+		return err
+	}
+	var size int64
+	if info, err := os.Stat(cfg.DBPath); err == nil {
+		size = info.Size()
+	}
+
+	roots := a.idx.Sources().Roots()
+	if *asJSON {
+		return printJSON(struct {
+			DocumentsDir string   `json:"documents_dir"`
+			Sources      []string `json:"sources"`
+			DBPath       string   `json:"db_path"`
+			DBSize       int64    `json:"db_size"`
+			Model        string   `json:"model"`
+			*vectordb.IndexStats
+		}{cfg.DocumentsDir, roots, cfg.DBPath, size, model, stats})
+	}
+	fmt.Printf("Sources:             %s\n", strings.Join(roots, ", "))
+	fmt.Printf("Database:            %s (%d bytes, schema version %d)\n", cfg.DBPath, size, stats.SchemaVersion)
+	fmt.Printf("Model:               %s (dimensions: %d)\n", model, stats.Dimensions)
+	fmt.Printf("Keyword index:       %t\n", stats.KeywordIndex)
+	fmt.Printf("Documents:           %d\n", stats.Documents)
+	fmt.Printf("Chunks:              %d\n", stats.Chunks)
+	fmt.Printf("Vectors:             %d\n", stats.Vectors)
+	return nil
+}
+
+// cliSession is the audit log session of changes made from the command line
+const cliSession = "cli"
+
+// runDelete removes a document from the index and deletes its file, like the
+// delete_document tool
+func runDelete(args []string) error {
+	flags := newFlagSet("delete")
+	asJSON := flags.Bool("json", false, "print the result as JSON")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return exitStatus(2)
+	}
+
+	a, err := openWritableApp("delete", skipSettings)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	// Accept the document as indexed or relative to the sources' base directory, as the tool does
+	filename := flags.Arg(0)
+	if _, err := a.db.GetDocument(filename); errors.Is(err, vectordb.ErrDocumentNotFound) {
+		filename = filepath.Join(a.idx.Sources().Base(), filename)
+	}
+
+	// Record the deletion in the audit log, like the delete_document tool
+	// The hash stays empty if the file is gone
+	hash, _ := indexer.HashFile(filename)
+	entry := &vectordb.AuditEntry{Tool: "delete_document", Session: cliSession, Document: filename, ContentHash: hash}
+	if args, err := json.Marshal(map[string]string{"filename": flags.Arg(0)}); err == nil {
+		entry.Arguments = string(args)
+	}
+	deleteErr := a.idx.DeleteDocument(filename)
+	if deleteErr != nil {
+		entry.Error = deleteErr.Error()
+	}
+	if err := a.db.AppendAudit(entry); err != nil {
+		fmt.Fprintf(os.Stderr, "[ERROR] Failed to record the deletion in the audit log: %v\n", err)
+	}
+
+	if deleteErr != nil {
+		return fmt.Errorf("failed to delete from database: %w", deleteErr)
+	}
+	if err := os.Remove(filename); err != nil {
+		fmt.Fprintf(os.Stderr, "[WARN] Failed to delete file: %v\n", err)
+	}
+
+	if *asJSON {
+		return printJSON(map[string]interface{}{"deleted": filename})
+	}
+	fmt.Printf("Deleted %s\n", filename)
+	return nil
+}
+
+// runCheck checks index integrity without modifying the index
+func runCheck(args []string) error {
+	flags := newFlagSet("check")
+	asJSON := flags.Bool("json", false, "print the report as JSON")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(nil)
+	if err != nil {
+		return err
+	}
+	a, err := openApp(cfg, skipSettings)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	report, err := a.db.CheckIntegrity()
+	if err != nil {
+		return err
+	}
+	if err := printIntegrity(report, nil, *asJSON); err != nil {
+		return err
+	}
+	if !report.OK() {
+		return exitStatus(1)
+	}
+	return nil
+}
+
+// runRepair repairs index integrity problems and re-indexes affected documents
+func runRepair(args []string) error {
+	flags := newFlagSet("repair")
+	asJSON := flags.Bool("json", false, "print the report as JSON")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	// Repair runs after the index matches the current model
+	a, err := openWritableApp("repair", ensureSettings)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	report, reindexed, err := a.idx.RepairIndex()
+	if err != nil {
+		return err
+	}
+	return printIntegrity(report, reindexed, *asJSON)
+}
+
+// runAudit prints matching audit log entries
+func runAudit(args []string) error {
+	flags := newFlagSet("audit")
+	auditQuery := auditFlags(flags)
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	query, err := auditQuery()
+	if err != nil {
+		return err
+	}
+	return printAudit(query)
+}
+
+// auditFlags registers the audit log filters and returns a function building
+// the query once the flags are parsed
+func auditFlags(flags *flag.FlagSet) func() (vectordb.AuditQuery, error) {
+	tool := flags.String("tool", "", "only print audit entries of this tool")
+	document := flags.String("document", "", "only print audit entries for this file")
+	session := flags.String("session", "", "only print audit entries of this MCP session")
+	since := flags.String("since", "", "only print audit entries since this time (RFC3339)")
+	limit := flags.Int("limit", 0, "only print the most recent audit entries (0 for all)")
+
+	return func() (vectordb.AuditQuery, error) {
+		query := vectordb.AuditQuery{Tool: *tool, Document: *document, Session: *session, Limit: *limit}
+		if *since != "" {
+			t, err := time.Parse(time.RFC3339, *since)
+			if err != nil {
+				return query, fmt.Errorf("invalid -since: %w", err)
+			}
+			query.Since = t
+		}
+		return query, nil
+	}
+}
+
+// printAudit prints matching audit log entries to stdout, one JSON object per line
+func printAudit(query vectordb.AuditQuery) error {
+	cfg, err := loadConfig(nil)
+	if err != nil {
+		return err
+	}
+	a, err := openApp(cfg, skipSettings)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	entries, err := a.db.QueryAudit(query)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			return fmt.Errorf("failed to write audit entry: %w", err)
+		}
+	}
+	return nil
+}
+
+// openWritableApp opens the index for a command that modifies it
+func openWritableApp(name string, policy settingsPolicy) (*app, error) {
+	cfg, err := loadConfig(nil)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.ReadOnly {
+		return nil, fmt.Errorf("%s cannot be used in read-only mode", name)
+	}
+	return openApp(cfg, policy)
+}
+
+// printIntegrity prints an integrity report; reindexed is non-nil after a repair
+func printIntegrity(report *vectordb.IntegrityReport, reindexed []string, asJSON bool) error {
+	if asJSON {
+		return printJSON(struct {
+			OK bool `json:"ok"`
+			*vectordb.IntegrityReport
+			Reindexed []string `json:"reindexed,omitempty"`
+		}{report.OK(), report, reindexed})
+	}
+
+	fmt.Printf("Orphan vectors:           %d\n", len(report.OrphanVectors))
+	fmt.Printf("Orphan chunks:            %d\n", len(report.OrphanChunks))
+	fmt.Printf("Chunks without vectors:   %d\n", len(report.ChunksWithoutVectors))
+	fmt.Printf("Documents without chunks: %d\n", len(report.DocumentsWithoutChunks))
+	for _, filename := range report.AffectedDocuments() {
+		fmt.Printf("  %s\n", filename)
+	}
+
+	if reindexed != nil {
+		fmt.Printf("Re-indexed documents:     %d\n", len(reindexed))
+		return nil
+	}
+	if !report.OK() {
+		fmt.Printf("Index has problems, run devrag repair to fix them\n")
+		return nil
+	}
+	fmt.Printf("Index is consistent\n")
+	return nil
+}
+
+// printJSON writes v to stdout as indented JSON
+func printJSON(v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal output: %w", err)
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// sourceFiles returns path if it is a file of the sources, or the files of the
+// sources below it
+func sourceFiles(path string, set *sources.Set) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		if !set.Match(path) {
+			return nil, fmt.Errorf("%s is excluded from the document sources", path)
+		}
+		return []string{path}, nil
+	}
+
+	files := []string{}
+	err = set.Walk(path, func(p string, info os.FileInfo) error {
+		if !info.IsDir() {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk directory: %w", err)
+	}
+	return files, nil
+}
+
+// documentPath returns path in the form sync indexes it, joined to the root of
+// its source. Files outside the sources are rejected, since the next sync would
+// remove them from the index again.
+func documentPath(path string, set *sources.Set) (string, error) {
+	located, ok := set.Locate(path)
+	if !ok {
+		return "", fmt.Errorf("%s is outside the document sources %s", path, strings.Join(set.Roots(), ", "))
+	}
+	return located, nil
+}
+
+// preview shortens text to at most n runes on a single line
+func preview(text string, n int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	runes := []rune(text)
+	if len(runes) > n {
+		return string(runes[:n]) + "..."
+	}
+	return text
+}
+
+// stringList collects the values of a repeatable flag
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
@@ -1,103 +1,214 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/tomohiro-owada/devrag/internal/config"
-	"github.com/tomohiro-owada/devrag/internal/embedder"
 	"github.com/tomohiro-owada/devrag/internal/indexer"
 	"github.com/tomohiro-owada/devrag/internal/mcp"
-	"github.com/tomohiro-owada/devrag/internal/vectordb"
 )
 
+// command is a devrag subcommand
+type command struct {
+	name    string
+	args    string // argument synopsis shown in usage
+	summary string
+	run     func(args []string) error
+}
+
+// commands lists the subcommands in the order they are shown in usage
+var commands []command
+
+func init() {
+	commands = []command{
+		{"serve", "", "sync documents and serve the index over MCP (default)", runServe},
+		{"index", "<path>...", "index markdown files or directories", runIndex},
+		{"sync", "", "index new and changed documents and remove deleted ones", runSync},
+		{"search", "<query>", "search the index", runSearch},
+		{"stats", "", "show index statistics", runStats},
+		{"delete", "<document>", "remove a document from the index and delete its file", runDelete},
+		{"check", "", "check index integrity (exit status 1 if problems are found)", runCheck},
+		{"repair", "", "repair index integrity problems and re-index affected documents", runRepair},
+		{"audit", "", "print the audit log of mutating tool calls as JSON lines", runAudit},
+	}
+}
+
+// configPath is the config file selected with -config, shared by all subcommands
+var configPath string
+
+// exitStatus is returned by commands that fail without an error to report
+type exitStatus int
+
+func (s exitStatus) Error() string {
+	return fmt.Sprintf("exit status %d", int(s))
+}
+
 func main() {
-	fmt.Fprintf(os.Stderr, "[INFO] DevRag starting...\n")
+	os.Exit(run(os.Args[1:]))
+}
 
-	// 1. Load configuration
-	cfg, err := config.Load()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "[FATAL] Failed to load config: %v\n", err)
-		os.Exit(1)
+// run executes the subcommand named by the first argument and returns the exit status
+// Without a subcommand devrag serves MCP, so existing client configurations keep working
+func run(args []string) int {
+	name := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+
+	if name == "help" {
+		usage(os.Stdout)
+		return 0
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		err := cmd.run(args)
+		var status exitStatus
+		switch {
+		case err == nil:
+			return 0
+		case errors.As(err, &status):
+			return int(status)
+		default:
+			fmt.Fprintf(os.Stderr, "[FATAL] %v\n", err)
+			return 1
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "[FATAL] Unknown command: %s\n\n", name)
+	usage(os.Stderr)
+	return 2
+}
+
+// usage prints the list of subcommands
+func usage(w *os.File) {
+	fmt.Fprintf(w, "Usage: devrag <command> [flags] [arguments]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-30s %s\n", strings.TrimSpace(cmd.name+" "+cmd.args), cmd.summary)
 	}
+	fmt.Fprintf(w, "\nRun devrag <command> -h for the flags of a command.\n")
+}
 
-	// Validate configuration
-	if err := cfg.Validate(); err != nil {
-		fmt.Fprintf(os.Stderr, "[FATAL] Invalid configuration: %v\n", err)
-		os.Exit(1)
+// newFlagSet creates the flag set of a subcommand
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("devrag "+name, flag.ContinueOnError)
+	fs.StringVar(&configPath, "config", "", "config file to use instead of searching for one (also set by "+config.ConfigEnv+")")
+	for _, cmd := range commands {
+		if cmd.name == name {
+			fs.Usage = func() {
+				fmt.Fprintf(fs.Output(), "Usage: devrag %s [flags] %s\n\n%s\n\nFlags:\n", cmd.name, cmd.args, cmd.summary)
+				fs.PrintDefaults()
+			}
+		}
 	}
+	return fs
+}
 
-	fmt.Fprintf(os.Stderr, "[INFO] Configuration loaded successfully\n")
-	fmt.Fprintf(os.Stderr, "[INFO] Documents directory: %s\n", cfg.DocumentsDir)
-	fmt.Fprintf(os.Stderr, "[INFO] Database path: %s\n", cfg.DBPath)
-	fmt.Fprintf(os.Stderr, "[INFO] Model: %s (dimensions: %d)\n", cfg.Model.Name, cfg.Model.Dimensions)
-	fmt.Fprintf(os.Stderr, "[INFO] Device: %s\n", cfg.Compute.Device)
-
-	// 2. Download model files if needed
-	modelDir := "models"
-	if err := embedder.DownloadModelFiles(modelDir); err != nil {
-		fmt.Fprintf(os.Stderr, "[FATAL] Failed to download model files: %v\n", err)
-		os.Exit(1)
+// parseFlags parses the flags of a subcommand, mapping -h to a clean exit
+// Flags may follow positional arguments, as in devrag search "query" -json;
+// everything after "--" is positional
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return exitStatus(0)
+			}
+			return exitStatus(2)
+		}
+		rest := fs.Args()
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			positional = append(positional, rest...)
+			break
+		}
+		if len(rest) == 0 {
+			break
+		}
+		// flag stops at the first positional argument; keep it and parse on
+		positional = append(positional, rest[0])
+		args = rest[1:]
 	}
+	// Leave only the positional arguments in fs.Args
+	return fs.Parse(append([]string{"--"}, positional...))
+}
 
-	// 3. Detect device
-	device := embedder.DetectDevice(cfg.Compute.Device, cfg.Compute.FallbackToCPU)
-	fmt.Fprintf(os.Stderr, "[INFO] Using device: %s\n", device)
+// runServe syncs the documents, watches them for changes and serves MCP until
+// interrupted
+func runServe(args []string) error {
+	fs := newFlagSet("serve")
+	transport := fs.String("transport", "", "MCP transport: stdio, http or sse (overrides server.transport)")
+	listen := fs.String("listen", "", "listen address for the http and sse transports (overrides server.address)")
+	readOnly := fs.Bool("read-only", false, "serve search and list tools only and never modify documents or the index (overrides read_only)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
 
-	// 4. Initialize components
+	fmt.Fprintf(os.Stderr, "[INFO] DevRag starting...\n")
 
-	// Ensure documents directory exists
-	if err := os.MkdirAll(cfg.DocumentsDir, 0755); err != nil {
-		fmt.Fprintf(os.Stderr, "[FATAL] Failed to create documents directory: %v\n", err)
-		os.Exit(1)
+	cfg, err := loadConfig(func(cfg *config.Config) {
+		if *transport != "" {
+			cfg.Server.Transport = *transport
+		}
+		if *listen != "" {
+			cfg.Server.Address = *listen
+		}
+		if *readOnly {
+			cfg.ReadOnly = true
+		}
+	})
+	if err != nil {
+		return err
 	}
 
-	// Initialize database
-	db, err := vectordb.Init(cfg.DBPath)
+	a, err := openApp(cfg, ensureSettings)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "[FATAL] Failed to initialize database: %v\n", err)
-		os.Exit(1)
+		return err
 	}
-	defer db.Close()
-
-	// Initialize embedder
-	// Note: Model file is required for production use
-	// For testing purposes, we'll use mock embedder if model is not available
-	var emb embedder.Embedder
-	modelPath := "models/multilingual-e5-small/model.onnx"
-	if _, err := os.Stat(modelPath); err == nil {
-		emb, err = embedder.NewONNXEmbedder(modelPath, device)
+	defer a.Close()
+
+	// Sync documents (a read-only index is served as it is)
+	if !cfg.ReadOnly {
+		fmt.Fprintf(os.Stderr, "[INFO] Syncing documents...\n")
+		syncResult, err := a.idx.Sync()
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "[FATAL] Failed to initialize embedder: %v\n", err)
-			os.Exit(1)
+			fmt.Fprintf(os.Stderr, "[WARN] Sync error: %v\n", err)
+		} else {
+			fmt.Fprintf(os.Stderr, "[INFO] Sync complete: +%d, ~%d, -%d (%d touched, %d failed)\n",
+				len(syncResult.Added),
+				len(syncResult.Updated),
+				len(syncResult.Deleted),
+				len(syncResult.Touched),
+				len(syncResult.Failed))
 		}
-		defer emb.Close()
-		fmt.Fprintf(os.Stderr, "[INFO] Loaded ONNX model from %s\n", modelPath)
-	} else {
-		fmt.Fprintf(os.Stderr, "[WARN] Model not found at %s, using mock embedder\n", modelPath)
-		emb = &embedder.MockEmbedder{}
 	}
 
-	// Initialize indexer
-	idx := indexer.NewIndexer(db, emb, cfg)
-
-	// 4. Sync documents
-	fmt.Fprintf(os.Stderr, "[INFO] Syncing documents...\n")
-	syncResult, err := idx.Sync()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "[WARN] Sync error: %v\n", err)
-	} else {
-		fmt.Fprintf(os.Stderr, "[INFO] Sync complete: +%d, ~%d, -%d\n",
-			len(syncResult.Added),
-			len(syncResult.Updated),
-			len(syncResult.Deleted))
+	// Keep the index up to date while the server runs
+	if cfg.Watch.Enabled && !cfg.ReadOnly {
+		watcher, err := indexer.NewWatcher(a.idx, time.Duration(cfg.Watch.DebounceMs)*time.Millisecond)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[WARN] Failed to start watcher: %v\n", err)
+		} else {
+			defer watcher.Close()
+		}
 	}
 
-	// 5. Start MCP server
-	fmt.Fprintf(os.Stderr, "[INFO] Starting MCP server...\n")
-	server := mcp.NewMCPServer(idx, db, emb, cfg)
-	if err := server.Start(); err != nil {
-		fmt.Fprintf(os.Stderr, "[FATAL] MCP server error: %v\n", err)
-		os.Exit(1)
+	// Start MCP server
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := mcp.NewMCPServer(a.idx, a.db, a.emb, cfg)
+	if err := server.Start(ctx); err != nil {
+		return fmt.Errorf("MCP server error: %w", err)
 	}
+	return nil
 }
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tomohiro-owada/devrag/internal/config"
+	"github.com/tomohiro-owada/devrag/internal/sources"
+	"github.com/tomohiro-owada/devrag/internal/vectordb"
+)
+
+// chdirTemp runs the test in an empty directory without config files
+func chdirTemp(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "xdg"))
+	t.Setenv("HOME", dir)
+	t.Setenv("DEVRAG_CONFIG", "")
+	t.Cleanup(func() { os.Chdir(wd) })
+	return dir
+}
+
+// runCapture runs devrag with args and returns its exit status and stdout
+func runCapture(t *testing.T, args ...string) (int, string) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	status := run(args)
+	os.Stdout = stdout
+	w.Close()
+
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return status, string(out)
+}
+
+func TestRun_Stats(t *testing.T) {
+	chdirTemp(t)
+
+	status, out := runCapture(t, "stats", "--json")
+	if status != 0 {
+		t.Fatalf("Expected exit status 0, got %d", status)
+	}
+
+	var stats struct {
+		DocumentsDir  string `json:"documents_dir"`
+		Documents     int    `json:"documents"`
+		SchemaVersion int    `json:"schema_version"`
+		Dimensions    int    `json:"dimensions"`
+	}
+	if err := json.Unmarshal([]byte(out), &stats); err != nil {
+		t.Fatalf("Failed to parse stats output %q: %v", out, err)
+	}
+	if stats.DocumentsDir != "./documents" || stats.Documents != 0 || stats.SchemaVersion == 0 || stats.Dimensions != 384 {
+		t.Errorf("Unexpected stats for a new index: %+v", stats)
+	}
+}
+
+func TestRun_Check(t *testing.T) {
+	chdirTemp(t)
+
+	status, out := runCapture(t, "check", "-json")
+	if status != 0 {
+		t.Fatalf("Expected exit status 0 for a new index, got %d", status)
+	}
+	var report struct {
+		OK            bool    `json:"ok"`
+		OrphanVectors []int64 `json:"orphan_vectors"`
+	}
+	if err := json.Unmarshal([]byte(out), &report); err != nil {
+		t.Fatalf("Failed to parse check output %q: %v", out, err)
+	}
+	if !report.OK || report.OrphanVectors == nil {
+		t.Errorf("Expected a consistent index, got %s", out)
+	}
+}
+
+func TestRun_Delete(t *testing.T) {
+	dir := chdirTemp(t)
+
+	if status, _ := runCapture(t, "delete", "missing.md"); status != 1 {
+		t.Errorf("Expected exit status 1 for a document that is not indexed, got %d", status)
+	}
+	if status, _ := runCapture(t, "delete"); status != 2 {
+		t.Errorf("Expected exit status 2 without a document, got %d", status)
+	}
+
+	// An indexed document is deleted with its file
+	content := "# Guide\n\nRun the installer."
+	if err := os.MkdirAll(filepath.Join(dir, "documents"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "documents", "guide.md"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	db, err := vectordb.Init("vectors.db", 384)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.InsertDocument(filepath.Join("documents", "guide.md"), time.Now(), "", nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	if status, _ := runCapture(t, "delete", "guide.md"); status != 0 {
+		t.Fatalf("Expected exit status 0 for an indexed document, got %d", status)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "documents", "guide.md")); !os.IsNotExist(err) {
+		t.Errorf("Expected the file to be deleted, got %v", err)
+	}
+
+	// Both deletions are audited, with the hash of the deleted file
+	status, out := runCapture(t, "audit", "-tool", "delete_document")
+	if status != 0 {
+		t.Fatalf("Expected exit status 0 for audit, got %d", status)
+	}
+	var entries []vectordb.AuditEntry
+	decoder := json.NewDecoder(strings.NewReader(out))
+	for decoder.More() {
+		var entry vectordb.AuditEntry
+		if err := decoder.Decode(&entry); err != nil {
+			t.Fatalf("Failed to parse audit output %q: %v", out, err)
+		}
+		entries = append(entries, entry)
+	}
+	sum := sha256.Sum256([]byte(content))
+	if len(entries) != 2 || entries[0].Error == "" || entries[1].Session != cliSession ||
+		entries[1].Document != filepath.Join("documents", "guide.md") || entries[1].ContentHash != hex.EncodeToString(sum[:]) {
+		t.Errorf("Expected the failed and the successful deletion to be audited, got %+v", entries)
+	}
+
+	// Mutating commands are refused in read-only mode
+	config := `{"documents_dir": "./documents", "db_path": "./vectors.db", "read_only": true}`
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := runCapture(t, "delete", "missing.md"); status != 1 {
+		t.Errorf("Expected exit status 1 in read-only mode, got %d", status)
+	}
+}
+
+func TestRun_UsageErrors(t *testing.T) {
+	chdirTemp(t)
+
+	tests := []struct {
+		args   []string
+		status int
+	}{
+		{[]string{"help"}, 0},
+		{[]string{"search", "-h"}, 0},
+		{[]string{"unknown"}, 2},
+		{[]string{"search"}, 2},
+		{[]string{"index"}, 2},
+		{[]string{"stats", "-no-such-flag"}, 2},
+	}
+	for _, tt := range tests {
+		if status, _ := runCapture(t, tt.args...); status != tt.status {
+			t.Errorf("devrag %v: expected exit status %d, got %d", tt.args, tt.status, status)
+		}
+	}
+}
+
+func TestParseFlags_Interspersed(t *testing.T) {
+	fs := newFlagSet("search")
+	asJSON := fs.Bool("json", false, "")
+	topK := fs.Int("top-k", 0, "")
+	if err := parseFlags(fs, []string{"JWT", "--json", "refresh", "-top-k", "3", "--", "-literal"}); err != nil {
+		t.Fatalf("Failed to parse flags: %v", err)
+	}
+	if !*asJSON || *topK != 3 {
+		t.Errorf("Expected -json and -top-k 3 after the query, got json=%v top-k=%d", *asJSON, *topK)
+	}
+	if got := strings.Join(fs.Args(), " "); got != "JWT refresh -literal" {
+		t.Errorf("Expected positional arguments \"JWT refresh -literal\", got %q", got)
+	}
+}
+
+func TestDocumentPath(t *testing.T) {
+	set := sources.New([]config.Source{{Path: "./documents"}})
+	tests := []struct {
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{"documents/guide.md", "documents/guide.md", false},
+		{"./documents/sub/../guide.md", "documents/guide.md", false},
+		{"documents", "documents", false},
+		{"other/guide.md", "", true},
+		{"documents/../../guide.md", "", true},
+	}
+	for _, tt := range tests {
+		got, err := documentPath(tt.path, set)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("documentPath(%q): unexpected error %v", tt.path, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("documentPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
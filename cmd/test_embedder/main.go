@@ -34,7 +34,12 @@ func main() {
 
 	// Create embedder
 	fmt.Fprintf(os.Stderr, "[INFO] Creating embedder...\n")
-	emb, err := embedder.NewONNXEmbedder(modelPath, embedder.CPU)
+	spec, err := embedder.LookupModel(embedder.DefaultModel)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[ERROR] %v\n", err)
+		os.Exit(1)
+	}
+	emb, err := embedder.NewONNXEmbedder(modelPath, spec, embedder.CPU, embedder.DefaultBatchSize)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "[ERROR] Failed to create embedder: %v\n", err)
 		os.Exit(1)
@@ -27,7 +27,7 @@ func main() {
 	fmt.Fprintf(os.Stderr, "[INFO] Using test database: %s\n", cfg.DBPath)
 
 	// Initialize database
-	db, err := vectordb.Init(cfg.DBPath)
+	db, err := vectordb.Init(cfg.DBPath, embedder.DefaultDimensions)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "[FATAL] Failed to initialize database: %v\n", err)
 		os.Exit(1)
@@ -73,7 +73,7 @@ More content here for testing the chunking logic.`
 		// Test indexing
 		fmt.Fprintf(os.Stderr, "\n[TEST] Indexing test file...\n")
 		start := time.Now()
-		if err := idx.IndexFile(testFile); err != nil {
+		if _, err := idx.IndexFile(testFile); err != nil {
 			fmt.Fprintf(os.Stderr, "[FATAL] Indexing failed: %v\n", err)
 			os.Exit(1)
 		}
@@ -97,7 +97,12 @@ More content here for testing the chunking logic.`
 	}
 
 	// Load actual ONNX model
-	emb, err := embedder.NewONNXEmbedder(modelPath, device)
+	spec, err := embedder.LookupModel(cfg.Model.Name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[FATAL] %v\n", err)
+		os.Exit(1)
+	}
+	emb, err := embedder.NewONNXEmbedder(modelPath, spec, device, cfg.Compute.BatchSize)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "[FATAL] Failed to initialize embedder: %v\n", err)
 		os.Exit(1)
@@ -164,6 +169,14 @@ func (e *PlaceholderEmbedder) EmbedBatch(texts []string) ([][]float32, error) {
 	return results, nil
 }
 
+func (e *PlaceholderEmbedder) EmbedQuery(text string) ([]float32, error) {
+	return e.Embed(text)
+}
+
+func (e *PlaceholderEmbedder) EmbedDocuments(texts []string) ([][]float32, error) {
+	return e.EmbedBatch(texts)
+}
+
 func (e *PlaceholderEmbedder) Close() error {
 	return nil
 }
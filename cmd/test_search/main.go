@@ -17,7 +17,7 @@ func main() {
 	os.Remove(dbPath) // Clean up from previous runs
 
 	// Initialize database
-	db, err := vectordb.Init(dbPath)
+	db, err := vectordb.Init(dbPath, 384)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "[FATAL] Failed to initialize database: %v\n", err)
 		os.Exit(1)
@@ -78,7 +78,7 @@ func main() {
 		}
 
 		// Insert document
-		err := db.InsertDocument(tc.filename, time.Now(), convertToChunkInterfaces(tc.chunks), embeddings)
+		err := db.InsertDocument(tc.filename, time.Now(), "", nil, convertToChunkInterfaces(tc.chunks), embeddings)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "[ERROR] Failed to insert document %s: %v\n", tc.filename, err)
 			continue
@@ -98,7 +98,7 @@ func main() {
 
 	// Search for top 5 results
 	topK := 5
-	results, err := db.Search(queryVector, topK)
+	results, err := db.Search(vectordb.SearchQuery{Vector: queryVector, TopK: topK})
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "[FATAL] Search failed: %v\n", err)
 		os.Exit(1)
@@ -116,7 +116,7 @@ func main() {
 
 	// Test with different topK values
 	fmt.Fprintf(os.Stderr, "\n[INFO] Testing with topK=3...\n")
-	results, err = db.Search(queryVector, 3)
+	results, err = db.Search(vectordb.SearchQuery{Vector: queryVector, TopK: 3})
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "[ERROR] Search with topK=3 failed: %v\n", err)
 	} else {
@@ -125,7 +125,7 @@ func main() {
 
 	// Test edge case: topK larger than available results
 	fmt.Fprintf(os.Stderr, "\n[INFO] Testing with topK=100 (more than available)...\n")
-	results, err = db.Search(queryVector, 100)
+	results, err = db.Search(vectordb.SearchQuery{Vector: queryVector, TopK: 100})
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "[ERROR] Search with topK=100 failed: %v\n", err)
 	} else {
@@ -141,7 +141,7 @@ func main() {
 	start := time.Now()
 	numSearches := 100
 	for i := 0; i < numSearches; i++ {
-		_, err := db.Search(queryVector, 5)
+		_, err := db.Search(vectordb.SearchQuery{Vector: queryVector, TopK: 5})
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "[ERROR] Search %d failed: %v\n", i, err)
 			break
@@ -210,5 +210,6 @@ type testChunk struct {
 	position int
 }
 
-func (c *testChunk) GetContent() string { return c.content }
-func (c *testChunk) GetPosition() int   { return c.position }
+func (c *testChunk) GetContent() string     { return c.content }
+func (c *testChunk) GetPosition() int       { return c.position }
+func (c *testChunk) GetHeadingPath() string { return "" }
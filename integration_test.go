@@ -1,14 +1,27 @@
 package main
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/mark3labs/mcp-go/client"
+	mcpgo "github.com/mark3labs/mcp-go/mcp"
 	"github.com/tomohiro-owada/devrag/internal/config"
 	"github.com/tomohiro-owada/devrag/internal/embedder"
 	"github.com/tomohiro-owada/devrag/internal/indexer"
+	"github.com/tomohiro-owada/devrag/internal/mcp"
 	"github.com/tomohiro-owada/devrag/internal/vectordb"
 )
 
@@ -36,7 +49,7 @@ func TestEndToEnd_FirstRun(t *testing.T) {
 	cfg.DBPath = dbPath
 	cfg.ChunkSize = 100
 
-	db, err := vectordb.Init(dbPath)
+	db, err := vectordb.Init(dbPath, embedder.DefaultDimensions)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -47,7 +60,7 @@ func TestEndToEnd_FirstRun(t *testing.T) {
 	idx := indexer.NewIndexer(db, emb, cfg)
 
 	// Test indexing
-	err = idx.IndexFile(testFile)
+	_, err = idx.IndexFile(testFile)
 	if err != nil {
 		t.Errorf("Indexing failed: %v", err)
 	}
@@ -97,7 +110,7 @@ func TestEndToEnd_Reindex(t *testing.T) {
 	cfg.DocumentsDir = testDir
 	cfg.DBPath = dbPath
 
-	db, err := vectordb.Init(dbPath)
+	db, err := vectordb.Init(dbPath, embedder.DefaultDimensions)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -107,7 +120,7 @@ func TestEndToEnd_Reindex(t *testing.T) {
 	idx := indexer.NewIndexer(db, emb, cfg)
 
 	// First index
-	err = idx.IndexFile(testFile)
+	_, err = idx.IndexFile(testFile)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -122,7 +135,7 @@ func TestEndToEnd_Reindex(t *testing.T) {
 	}
 
 	// Re-index
-	err = idx.IndexFile(testFile)
+	_, err = idx.IndexFile(testFile)
 	if err != nil {
 		t.Errorf("Re-indexing failed: %v", err)
 	}
@@ -168,7 +181,7 @@ func TestEndToEnd_MultipleFiles(t *testing.T) {
 	cfg.DocumentsDir = testDir
 	cfg.DBPath = dbPath
 
-	db, err := vectordb.Init(dbPath)
+	db, err := vectordb.Init(dbPath, embedder.DefaultDimensions)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -180,7 +193,7 @@ func TestEndToEnd_MultipleFiles(t *testing.T) {
 	// Index all files
 	for filename := range files {
 		filepath := testDir + "/" + filename
-		err = idx.IndexFile(filepath)
+		_, err = idx.IndexFile(filepath)
 		if err != nil {
 			t.Errorf("Failed to index %s: %v", filename, err)
 		}
@@ -232,7 +245,7 @@ func TestEndToEnd_DeleteDocument(t *testing.T) {
 	cfg.DocumentsDir = testDir
 	cfg.DBPath = dbPath
 
-	db, err := vectordb.Init(dbPath)
+	db, err := vectordb.Init(dbPath, embedder.DefaultDimensions)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -241,7 +254,7 @@ func TestEndToEnd_DeleteDocument(t *testing.T) {
 	emb := &embedder.MockEmbedder{}
 	idx := indexer.NewIndexer(db, emb, cfg)
 
-	err = idx.IndexFile(testFile)
+	_, err = idx.IndexFile(testFile)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -305,7 +318,7 @@ func TestEndToEnd_Sync(t *testing.T) {
 	cfg.DocumentsDir = testDir
 	cfg.DBPath = dbPath
 
-	db, err := vectordb.Init(dbPath)
+	db, err := vectordb.Init(dbPath, embedder.DefaultDimensions)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -353,6 +366,188 @@ func TestEndToEnd_Sync(t *testing.T) {
 	}
 }
 
+func TestEndToEnd_Sources(t *testing.T) {
+	// A monorepo with documentation spread over several directories
+	repo := t.TempDir()
+	dbPath := filepath.Join(t.TempDir(), "test_vectors.db")
+	files := map[string]string{
+		".gitignore":                     "build/\n",
+		"docs/guide.md":                  "# Guide\n\nHow to use the service.",
+		"docs/drafts/plan.md":            "# Plan\n\nNot ready yet.",
+		"adr/001-storage.md":             "# Storage\n\nWe use SQLite.",
+		"adr/002-search.md":              "# Search\n\nWe use vector search.",
+		"api/README.md":                  "# API\n\nThe API service.",
+		"web/README.md":                  "# Web\n\nThe web frontend.",
+		"web/node_modules/pkg/README.md": "# Package\n\nA vendored dependency.",
+		"web/build/README.md":            "# Build\n\nGenerated output.",
+		"CHANGELOG.md":                   "# Changelog\n\nNot documentation.",
+	}
+	for name, content := range files {
+		path := filepath.Join(repo, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.DBPath = dbPath
+	cfg.Sources = []config.Source{
+		{Path: filepath.Join(repo, "docs"), Exclude: []string{"drafts/**"}},
+		{Path: filepath.Join(repo, "adr")},
+		{Path: repo, Include: []string{"*/README.md", "*/*/README.md"}, Exclude: []string{"**/node_modules/**"}},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected sources to be valid, got %v", err)
+	}
+
+	db, err := vectordb.Init(dbPath, embedder.DefaultDimensions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	idx := indexer.NewIndexer(db, &embedder.MockEmbedder{}, cfg)
+
+	indexed := func() []string {
+		t.Helper()
+		docs, err := db.ListDocuments()
+		if err != nil {
+			t.Fatal(err)
+		}
+		names := []string{}
+		for filename := range docs {
+			rel, err := filepath.Rel(repo, filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			names = append(names, filepath.ToSlash(rel))
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	if _, err := idx.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"adr/001-storage.md", "adr/002-search.md", "api/README.md", "docs/guide.md", "web/README.md"}
+	if got := indexed(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v to be indexed, got %v", want, got)
+	}
+
+	// Files that become ignored are removed from the index by the next sync
+	if err := os.WriteFile(filepath.Join(repo, "adr", ".devragignore"), []byte("002-*.md\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := idx.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"adr/001-storage.md", "api/README.md", "docs/guide.md", "web/README.md"}
+	if got := indexed(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v to be indexed after ignoring a file, got %v", want, got)
+	}
+}
+
+// countingEmbedder records the number of texts of each EmbedDocuments call
+type countingEmbedder struct {
+	embedder.MockEmbedder
+	calls []int
+}
+
+func (e *countingEmbedder) EmbedDocuments(texts []string) ([][]float32, error) {
+	e.calls = append(e.calls, len(texts))
+	return e.MockEmbedder.EmbedDocuments(texts)
+}
+
+func TestEndToEnd_ParallelIndexing(t *testing.T) {
+	tmpDir := t.TempDir()
+	testDir := filepath.Join(tmpDir, "test_documents")
+	dbPath := filepath.Join(tmpDir, "test_vectors.db")
+
+	// Many small files, each with a chunk of its own and a chunk shared by all
+	const files = 40
+	for i := 0; i < files; i++ {
+		dir := filepath.Join(testDir, fmt.Sprintf("dir%d", i%4))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		content := fmt.Sprintf("# Document %d\n\nContent of document %d.\n\n## License\n\nShared license text.", i, i)
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("doc%d.md", i)), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.DocumentsDir = testDir
+	cfg.DBPath = dbPath
+	cfg.ChunkSize = 40
+	cfg.Compute.BatchSize = 16
+	cfg.Indexing.Workers = 4
+	cfg.Indexing.WriteBatchSize = 8
+
+	db, err := vectordb.Init(dbPath, embedder.DefaultDimensions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	emb := &countingEmbedder{}
+	idx := indexer.NewIndexer(db, emb, cfg)
+
+	result, err := idx.Sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Added) != files {
+		t.Errorf("Expected %d added files, got %d", files, len(result.Added))
+	}
+
+	stats, err := db.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Documents != files || stats.Chunks != 2*files || stats.Vectors != 2*files {
+		t.Errorf("Expected %d documents with 2 chunks and vectors each, got %+v", files, stats)
+	}
+	if result.EmbeddedChunks+result.ReusedChunks != 2*files {
+		t.Errorf("Expected embedded and reused chunks to add up to %d, got %d + %d", 2*files, result.EmbeddedChunks, result.ReusedChunks)
+	}
+
+	// Chunks of several files are embedded together: every call but the last
+	// has at least compute.batch_size texts
+	for i, n := range emb.calls[:len(emb.calls)-1] {
+		if n < cfg.Compute.BatchSize {
+			t.Errorf("Expected call %d to embed at least %d texts, got %d (calls: %v)", i, cfg.Compute.BatchSize, n, emb.calls)
+		}
+	}
+
+	// Every file is stored with the vectors of its own chunks
+	doc7 := filepath.Join(testDir, "dir3", "doc7.md")
+	chunks, err := db.GetDocumentChunks(doc7, 0, 0)
+	if err != nil || len(chunks) != 1 {
+		t.Fatalf("Failed to get the first chunk of doc7.md: %v", err)
+	}
+	results, err := idx.Search(vectordb.SearchQuery{Text: chunks[0].Content, TopK: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].DocumentName != doc7 {
+		t.Errorf("Expected %s as the top result, got %+v", doc7, results)
+	}
+
+	// A second sync finds nothing to do
+	emb.calls = nil
+	result, err = idx.Sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Added)+len(result.Updated)+len(result.Touched) != 0 || len(emb.calls) != 0 {
+		t.Errorf("Expected no changes on the second sync, got %+v and embedding calls %v", result, emb.calls)
+	}
+}
+
 func TestEndToEnd_ConfigValidation(t *testing.T) {
 	// Test invalid configuration
 	cfg := config.DefaultConfig()
@@ -380,7 +575,7 @@ func TestEndToEnd_EmptyDirectory(t *testing.T) {
 	cfg.DocumentsDir = testDir
 	cfg.DBPath = dbPath
 
-	db, err := vectordb.Init(dbPath)
+	db, err := vectordb.Init(dbPath, embedder.DefaultDimensions)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -404,3 +599,1568 @@ func TestEndToEnd_EmptyDirectory(t *testing.T) {
 		t.Errorf("Expected 0 documents, got %d", len(docs))
 	}
 }
+
+func TestEndToEnd_FrontmatterFilter(t *testing.T) {
+	// Setup
+	tmpDir := t.TempDir()
+	testDir := tmpDir + "/test_documents"
+	dbPath := tmpDir + "/test_vectors.db"
+
+	err := os.MkdirAll(testDir, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{
+		"auth.md":  "---\ndomain: backend\ndocType: spec\ntags: [auth, jwt]\n---\n\n# Auth\n\nToken refresh flow.",
+		"ui.md":    "---\ndomain: frontend\ndocType: guide\n---\n\n# UI\n\nComponent guidelines.",
+		"notes.md": "# Notes\n\nNo frontmatter here.",
+	}
+	for filename, content := range files {
+		if err := os.WriteFile(testDir+"/"+filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Initialize
+	cfg := config.DefaultConfig()
+	cfg.DocumentsDir = testDir
+	cfg.DBPath = dbPath
+
+	db, err := vectordb.Init(dbPath, embedder.DefaultDimensions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	emb := &embedder.MockEmbedder{}
+	idx := indexer.NewIndexer(db, emb, cfg)
+
+	if _, err := idx.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	queryVector, err := emb.Embed("token refresh")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filter, err := vectordb.ParseFilter("domain=backend")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := db.Search(vectordb.SearchQuery{Vector: queryVector, TopK: 10, Filters: []vectordb.Filter{filter}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) == 0 {
+		t.Fatal("Expected results for domain=backend")
+	}
+	for _, r := range results {
+		if filepath.Base(r.DocumentName) != "auth.md" {
+			t.Errorf("Unexpected document in filtered results: %s", r.DocumentName)
+		}
+	}
+}
+
+func TestEndToEnd_PrefixSettingChangeRebuildsIndex(t *testing.T) {
+	// Setup
+	tmpDir := t.TempDir()
+	testDir := tmpDir + "/test_documents"
+	dbPath := tmpDir + "/test_vectors.db"
+
+	err := os.MkdirAll(testDir, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(testDir+"/doc.md", []byte("# Doc\n\nContent."), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.DocumentsDir = testDir
+	cfg.DBPath = dbPath
+
+	db, err := vectordb.Init(dbPath, embedder.DefaultDimensions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	idx := indexer.NewIndexer(db, &embedder.MockEmbedder{}, cfg)
+
+	spec, err := embedder.LookupModel(cfg.Model.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// First run records the setting without clearing anything
+	rebuilt, err := idx.EnsureEmbeddingSettings(spec.Settings(cfg.Model.UsePrefixes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rebuilt {
+		t.Error("Expected no rebuild on a fresh index")
+	}
+	if _, err := idx.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Unchanged setting keeps the index
+	rebuilt, err = idx.EnsureEmbeddingSettings(spec.Settings(cfg.Model.UsePrefixes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rebuilt {
+		t.Error("Expected no rebuild when the setting is unchanged")
+	}
+
+	// Changing the setting clears the index and the next sync re-adds everything
+	cfg.Model.UsePrefixes = !cfg.Model.UsePrefixes
+	rebuilt, err = idx.EnsureEmbeddingSettings(spec.Settings(cfg.Model.UsePrefixes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rebuilt {
+		t.Fatal("Expected rebuild after changing use_prefixes")
+	}
+
+	docs, err := db.ListDocuments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 0 {
+		t.Errorf("Expected empty index after rebuild, got %d documents", len(docs))
+	}
+
+	result, err := idx.Sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Added) != 1 {
+		t.Errorf("Expected 1 re-added document, got %d", len(result.Added))
+	}
+}
+
+func TestEndToEnd_ModelChange(t *testing.T) {
+	// Setup
+	tmpDir := t.TempDir()
+	testDir := tmpDir + "/test_documents"
+	dbPath := tmpDir + "/test_vectors.db"
+
+	err := os.MkdirAll(testDir, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(testDir+"/doc.md", []byte("# Doc\n\nContent."), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.DocumentsDir = testDir
+	cfg.DBPath = dbPath
+
+	small, err := embedder.LookupModel("multilingual-e5-small")
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, err := embedder.LookupModel("multilingual-e5-base")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := vectordb.Init(dbPath, small.Dimensions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idx := indexer.NewIndexer(db, &embedder.MockEmbedder{Dimensions: small.Dimensions}, cfg)
+	if _, err := idx.EnsureEmbeddingSettings(small.Settings(true)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := idx.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	// Reopen with a model of a different dimension; the existing table keeps its own
+	db, err = vectordb.Init(dbPath, base.Dimensions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if db.Dimensions() != small.Dimensions {
+		t.Fatalf("Expected existing index to keep %d dimensions, got %d", small.Dimensions, db.Dimensions())
+	}
+
+	idx = indexer.NewIndexer(db, &embedder.MockEmbedder{Dimensions: base.Dimensions}, cfg)
+
+	// Refuse leaves the index untouched
+	cfg.Model.OnMismatch = config.OnMismatchRefuse
+	rebuilt, err := idx.EnsureEmbeddingSettings(base.Settings(true))
+	if !errors.Is(err, indexer.ErrEmbeddingMismatch) {
+		t.Fatalf("Expected ErrEmbeddingMismatch, got %v", err)
+	}
+	if rebuilt {
+		t.Error("Expected no rebuild when refusing")
+	}
+	docs, err := db.ListDocuments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 1 {
+		t.Errorf("Expected index to be kept, got %d documents", len(docs))
+	}
+
+	// Rebuild recreates the vector table at the new dimension
+	cfg.Model.OnMismatch = config.OnMismatchRebuild
+	rebuilt, err = idx.EnsureEmbeddingSettings(base.Settings(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rebuilt {
+		t.Fatal("Expected rebuild after changing the model")
+	}
+	if db.Dimensions() != base.Dimensions {
+		t.Errorf("Expected %d dimensions after rebuild, got %d", base.Dimensions, db.Dimensions())
+	}
+
+	result, err := idx.Sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Added) != 1 {
+		t.Errorf("Expected 1 re-added document, got %d", len(result.Added))
+	}
+
+	queryVec, err := (&embedder.MockEmbedder{Dimensions: base.Dimensions}).EmbedQuery("Content")
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := db.Search(vectordb.SearchQuery{Vector: queryVec, TopK: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Errorf("Expected 1 search result, got %d", len(results))
+	}
+
+	// Switching from the real model to the mock embedder is also a mismatch
+	rebuilt, err = idx.EnsureEmbeddingSettings(embedder.Settings{Model: embedder.MockModel, Dimensions: base.Dimensions})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rebuilt {
+		t.Error("Expected rebuild after switching to the mock embedder")
+	}
+
+	// An empty index without recorded settings is rebuilt if its vector table has another dimension
+	emptyDB, err := vectordb.Init(tmpDir+"/empty_vectors.db", small.Dimensions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer emptyDB.Close()
+
+	idx = indexer.NewIndexer(emptyDB, &embedder.MockEmbedder{Dimensions: base.Dimensions}, cfg)
+	rebuilt, err = idx.EnsureEmbeddingSettings(base.Settings(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rebuilt {
+		t.Error("Expected rebuild of an empty index with another dimension")
+	}
+	if emptyDB.Dimensions() != base.Dimensions {
+		t.Errorf("Expected %d dimensions after rebuild, got %d", base.Dimensions, emptyDB.Dimensions())
+	}
+	if _, err := idx.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	stats, err := emptyDB.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Documents != 1 {
+		t.Errorf("Expected 1 indexed document, got %d", stats.Documents)
+	}
+}
+
+func TestEndToEnd_Watcher(t *testing.T) {
+	// Setup
+	tmpDir := t.TempDir()
+	testDir := tmpDir + "/test_documents"
+	dbPath := tmpDir + "/test_vectors.db"
+
+	err := os.MkdirAll(testDir, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.DocumentsDir = testDir
+	cfg.DBPath = dbPath
+
+	db, err := vectordb.Init(dbPath, embedder.DefaultDimensions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	idx := indexer.NewIndexer(db, &embedder.MockEmbedder{}, cfg)
+
+	watcher, err := indexer.NewWatcher(idx, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Close()
+
+	// waitForDocuments polls the index until it holds exactly the expected files
+	waitForDocuments := func(expected ...string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			idx.RLock()
+			docs, err := db.ListDocuments()
+			idx.RUnlock()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			matched := len(docs) == len(expected)
+			for _, name := range expected {
+				if _, ok := docs[name]; !ok {
+					matched = false
+				}
+			}
+			if matched {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected documents %v, got %v", expected, docs)
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+
+	// Create
+	docPath := filepath.Join(testDir, "doc.md")
+	if err := os.WriteFile(docPath, []byte("# Doc\n\nFirst version."), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitForDocuments(docPath)
+
+	// New subdirectory with a file
+	subDir := filepath.Join(testDir, "sub")
+	if err := os.MkdirAll(subDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	subPath := filepath.Join(subDir, "nested.md")
+	if err := os.WriteFile(subPath, []byte("# Nested\n\nNested content."), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitForDocuments(docPath, subPath)
+
+	// Rename
+	renamedPath := filepath.Join(testDir, "renamed.md")
+	if err := os.Rename(docPath, renamedPath); err != nil {
+		t.Fatal(err)
+	}
+	waitForDocuments(renamedPath, subPath)
+
+	// Modify
+	updated := "# Doc\n\nSecond version."
+	if err := os.WriteFile(renamedPath, []byte(updated), 0644); err != nil {
+		t.Fatal(err)
+	}
+	queryVec, err := (&embedder.MockEmbedder{}).Embed(updated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		idx.RLock()
+		results, err := db.Search(vectordb.SearchQuery{Vector: queryVec, TopK: 1})
+		idx.RUnlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) == 1 && results[0].ChunkContent == updated {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected updated content to be indexed, got %+v", results)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// Delete a directory
+	if err := os.RemoveAll(subDir); err != nil {
+		t.Fatal(err)
+	}
+	waitForDocuments(renamedPath)
+
+	// Non-markdown files are ignored
+	if err := os.WriteFile(filepath.Join(testDir, "notes.txt"), []byte("text"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	waitForDocuments(renamedPath)
+}
+
+func TestEndToEnd_SyncContentHash(t *testing.T) {
+	// Setup
+	tmpDir := t.TempDir()
+	testDir := tmpDir + "/test_documents"
+	dbPath := tmpDir + "/test_vectors.db"
+
+	err := os.MkdirAll(testDir, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	touchedFile := testDir + "/touched.md"
+	editedFile := testDir + "/edited.md"
+	if err := os.WriteFile(touchedFile, []byte("# Touched\n\nSame content."), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(editedFile, []byte("# Edited\n\nFirst version."), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.DocumentsDir = testDir
+	cfg.DBPath = dbPath
+
+	db, err := vectordb.Init(dbPath, embedder.DefaultDimensions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	idx := indexer.NewIndexer(db, &embedder.MockEmbedder{}, cfg)
+
+	if _, err := idx.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Touch one file and edit the other within the same second
+	base := time.Now().Truncate(time.Second).Add(-time.Minute)
+	if err := os.Chtimes(touchedFile, base, base.Add(300*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(editedFile, []byte("# Edited\n\nSecond version."), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(editedFile, base, base.Add(600*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	result, err := idx.Sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Touched) != 1 || result.Touched[0] != touchedFile {
+		t.Errorf("Expected %s to be reported as touched, got %v", touchedFile, result.Touched)
+	}
+	if len(result.Updated) != 1 || result.Updated[0] != editedFile {
+		t.Errorf("Expected %s to be reported as updated, got %v", editedFile, result.Updated)
+	}
+
+	// Edit again within the same second as the previous edit
+	if err := os.WriteFile(editedFile, []byte("# Edited\n\nThird version."), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(editedFile, base, base.Add(900*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err = idx.Sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Updated) != 1 || result.Updated[0] != editedFile {
+		t.Errorf("Expected sub-second edit of %s to be detected, got %v", editedFile, result.Updated)
+	}
+	if len(result.Touched) != 0 {
+		t.Errorf("Expected no touched files on the third sync, got %v", result.Touched)
+	}
+
+	// Touching again reports the file without re-embedding it
+	if err := os.Chtimes(touchedFile, base, base.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	result, err = idx.Sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Touched) != 1 || result.Touched[0] != touchedFile {
+		t.Errorf("Expected %s to be reported as touched, got %v", touchedFile, result.Touched)
+	}
+	if len(result.Updated) != 0 {
+		t.Errorf("Expected no updated files, got %v", result.Updated)
+	}
+
+	// The recorded timestamp is refreshed, so the next sync sees no changes
+	result, err = idx.Sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Added)+len(result.Updated)+len(result.Deleted)+len(result.Touched) != 0 {
+		t.Errorf("Expected no changes, got %+v", result)
+	}
+}
+
+func TestEndToEnd_SyncFailedFiles(t *testing.T) {
+	// Setup
+	tmpDir := t.TempDir()
+	testDir := tmpDir + "/test_documents"
+	dbPath := tmpDir + "/test_vectors.db"
+
+	if err := os.MkdirAll(testDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	goodFile := testDir + "/good.md"
+	badFile := testDir + "/bad.md"
+	if err := os.WriteFile(goodFile, []byte("# Good\n\nIndexed."), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// A line longer than the markdown scanner accepts fails to parse
+	if err := os.WriteFile(badFile, []byte(strings.Repeat("a", 128*1024)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.DocumentsDir = testDir
+	cfg.DBPath = dbPath
+
+	db, err := vectordb.Init(dbPath, embedder.DefaultDimensions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	idx := indexer.NewIndexer(db, &embedder.MockEmbedder{}, cfg)
+
+	result, err := idx.Sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Added) != 1 || result.Added[0] != goodFile {
+		t.Errorf("Expected only %s to be reported as added, got %v", goodFile, result.Added)
+	}
+	if len(result.Failed) != 1 || result.Failed[0].File != badFile || result.Failed[0].Error == "" {
+		t.Errorf("Expected %s to be reported as failed with its error, got %+v", badFile, result.Failed)
+	}
+
+	// The failed file is retried, and still not reported as added
+	result, err = idx.Sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Added) != 0 || len(result.Failed) != 1 {
+		t.Errorf("Expected the failed file to fail again, got %+v", result)
+	}
+}
+
+func TestEndToEnd_ChunkLevelReembedding(t *testing.T) {
+	// Setup
+	tmpDir := t.TempDir()
+	testDir := tmpDir + "/test_documents"
+	dbPath := tmpDir + "/test_vectors.db"
+
+	err := os.MkdirAll(testDir, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sections := []string{
+		"## Install\n\nRun the installer.",
+		"## Configure\n\nEdit config.json.",
+		"## Run\n\nStart the server.",
+		"## Troubleshoot\n\nCheck the logs.",
+	}
+	testFile := testDir + "/guide.md"
+	if err := os.WriteFile(testFile, []byte(strings.Join(sections, "\n\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.DocumentsDir = testDir
+	cfg.DBPath = dbPath
+
+	db, err := vectordb.Init(dbPath, embedder.DefaultDimensions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	idx := indexer.NewIndexer(db, &embedder.MockEmbedder{}, cfg)
+
+	result, err := idx.Sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.EmbeddedChunks != len(sections) || result.ReusedChunks != 0 {
+		t.Errorf("Expected %d embedded and 0 reused chunks, got %d embedded and %d reused",
+			len(sections), result.EmbeddedChunks, result.ReusedChunks)
+	}
+
+	// Change one section
+	sections[2] = "## Run\n\nStart the server with --verbose."
+	if err := os.WriteFile(testFile, []byte(strings.Join(sections, "\n\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(testFile, time.Now(), time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err = idx.Sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Updated) != 1 {
+		t.Fatalf("Expected 1 updated file, got %v", result.Updated)
+	}
+	if result.EmbeddedChunks != 1 || result.ReusedChunks != len(sections)-1 {
+		t.Errorf("Expected 1 embedded and %d reused chunks, got %d embedded and %d reused",
+			len(sections)-1, result.EmbeddedChunks, result.ReusedChunks)
+	}
+
+	// Reused vectors still find their chunks
+	emb := &embedder.MockEmbedder{}
+	for _, section := range sections {
+		vec, err := emb.Embed(section)
+		if err != nil {
+			t.Fatal(err)
+		}
+		results, err := db.Search(vectordb.SearchQuery{Vector: vec, TopK: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || results[0].ChunkContent != section {
+			t.Errorf("Expected %q as top result, got %+v", section, results)
+		}
+	}
+}
+
+func TestEndToEnd_RepairIndex(t *testing.T) {
+	// Setup
+	tmpDir := t.TempDir()
+	testDir := tmpDir + "/test_documents"
+	dbPath := tmpDir + "/test_vectors.db"
+
+	err := os.MkdirAll(testDir, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testFile := testDir + "/guide.md"
+	if err := os.WriteFile(testFile, []byte("# Guide\n\nRun the installer."), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.DocumentsDir = testDir
+	cfg.DBPath = dbPath
+
+	db, err := vectordb.Init(dbPath, embedder.DefaultDimensions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	idx := indexer.NewIndexer(db, &embedder.MockEmbedder{}, cfg)
+	if _, err := idx.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Lose the chunks of an existing file and record a file that no longer exists
+	if err := db.InsertDocument(testFile, time.Now(), "", nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	goneFile := testDir + "/gone.md"
+	if err := db.InsertDocument(goneFile, time.Now(), "", nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := db.CheckIntegrity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.DocumentsWithoutChunks) != 2 {
+		t.Fatalf("Expected 2 documents without chunks, got %v", report.DocumentsWithoutChunks)
+	}
+
+	_, reindexed, err := idx.RepairIndex()
+	if err != nil {
+		t.Fatalf("RepairIndex failed: %v", err)
+	}
+	if len(reindexed) != 1 || reindexed[0] != testFile {
+		t.Errorf("Expected only %s to be re-indexed, got %v", testFile, reindexed)
+	}
+
+	report, err = db.CheckIntegrity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() {
+		t.Errorf("Expected a consistent index after repair, got %+v", report)
+	}
+
+	docs, err := db.ListDocuments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := docs[goneFile]; ok {
+		t.Error("Expected the missing file to be removed from the index")
+	}
+	if _, ok := docs[testFile]; !ok {
+		t.Error("Expected the existing file to be re-indexed")
+	}
+}
+
+func TestEndToEnd_NetworkTransports(t *testing.T) {
+	transports := []struct {
+		transport string
+		newClient func(addr string) (*client.Client, error)
+	}{
+		{config.TransportHTTP, func(addr string) (*client.Client, error) {
+			return client.NewStreamableHttpClient("http://" + addr + "/mcp")
+		}},
+		{config.TransportSSE, func(addr string) (*client.Client, error) {
+			return client.NewSSEMCPClient("http://" + addr + "/sse")
+		}},
+	}
+
+	for _, tc := range transports {
+		t.Run(tc.transport, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			testDir := tmpDir + "/test_documents"
+			dbPath := tmpDir + "/test_vectors.db"
+
+			if err := os.MkdirAll(testDir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			testFile := testDir + "/shared.md"
+			if err := os.WriteFile(testFile, []byte("# Shared\n\nServed to several clients."), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			addr := freeAddress(t)
+
+			cfg := config.DefaultConfig()
+			cfg.DocumentsDir = testDir
+			cfg.DBPath = dbPath
+			cfg.Server.Transport = tc.transport
+			cfg.Server.Address = addr
+
+			db, err := vectordb.Init(dbPath, embedder.DefaultDimensions)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			emb := &embedder.MockEmbedder{}
+			idx := indexer.NewIndexer(db, emb, cfg)
+			if _, err := idx.Sync(); err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			serverErr := make(chan error, 1)
+			go func() {
+				serverErr <- mcp.NewMCPServer(idx, db, emb, cfg).Start(ctx)
+			}()
+
+			// Two clients share the same running server
+			for i := 0; i < 2; i++ {
+				c, err := tc.newClient(addr)
+				if err != nil {
+					t.Fatal(err)
+				}
+				connectClient(t, ctx, c)
+
+				text := callTool(t, ctx, c, "list_documents", nil)
+				if !strings.Contains(text, "shared.md") {
+					t.Errorf("Expected shared.md in the document list, got %s", text)
+				}
+				c.Close()
+			}
+
+			cancel()
+			select {
+			case err := <-serverErr:
+				if err != nil {
+					t.Errorf("Expected a clean shutdown, got %v", err)
+				}
+			case <-time.After(10 * time.Second):
+				t.Error("Server did not shut down")
+			}
+		})
+	}
+}
+
+func TestEndToEnd_AuditLog(t *testing.T) {
+	tmpDir := t.TempDir()
+	testDir := tmpDir + "/test_documents"
+	dbPath := tmpDir + "/test_vectors.db"
+
+	if err := os.MkdirAll(testDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	guideFile := testDir + "/guide.md"
+	guideContent := "---\ndomain: frontend\n---\n# Guide\n\nRun the installer."
+	if err := os.WriteFile(guideFile, []byte(guideContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+	oldContent := "# Old\n\nNo longer needed."
+	if err := os.WriteFile(testDir+"/old.md", []byte(oldContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	addr := freeAddress(t)
+
+	cfg := config.DefaultConfig()
+	cfg.DocumentsDir = testDir
+	cfg.DBPath = dbPath
+	cfg.Server.Transport = config.TransportHTTP
+	cfg.Server.Address = addr
+
+	db, err := vectordb.Init(dbPath, embedder.DefaultDimensions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	emb := &embedder.MockEmbedder{}
+	idx := indexer.NewIndexer(db, emb, cfg)
+	if _, err := idx.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go mcp.NewMCPServer(idx, db, emb, cfg).Start(ctx)
+
+	c, err := client.NewStreamableHttpClient("http://" + addr + "/mcp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	connectClient(t, ctx, c)
+
+	callTool(t, ctx, c, "update_frontmatter", map[string]any{"filepath": guideFile, "domain": "backend"})
+	callTool(t, ctx, c, "delete_document", map[string]any{"filename": "old.md"})
+	callTool(t, ctx, c, "reindex_document", map[string]any{"filename": "guide.md"})
+	callTool(t, ctx, c, "search", map[string]any{"query": "installer"})
+
+	entries, err := db.QueryAudit(vectordb.AuditQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("Expected only the 3 mutating calls to be audited, got %+v", entries)
+	}
+
+	update := entries[0]
+	if update.Tool != "update_frontmatter" || update.Document != guideFile {
+		t.Errorf("Expected update_frontmatter of %s, got %+v", guideFile, update)
+	}
+	if update.Session == "" {
+		t.Error("Expected the caller session to be recorded")
+	}
+	if !strings.Contains(update.Arguments, `"domain":"backend"`) {
+		t.Errorf("Expected the tool arguments to be recorded, got %s", update.Arguments)
+	}
+	if !strings.Contains(update.Before, `"domain":"frontend"`) || !strings.Contains(update.After, `"domain":"backend"`) {
+		t.Errorf("Expected frontmatter before and after the call, got %q -> %q", update.Before, update.After)
+	}
+
+	sum := sha256.Sum256([]byte(oldContent))
+	deleted := entries[1]
+	if deleted.Tool != "delete_document" || deleted.ContentHash != hex.EncodeToString(sum[:]) {
+		t.Errorf("Expected the hash of the deleted file to be recorded, got %+v", deleted)
+	}
+	// Documents are recorded as indexed, not as passed to the tool
+	if deleted.Document != testDir+"/old.md" {
+		t.Errorf("Expected the indexed path of the deleted file, got %q", deleted.Document)
+	}
+
+	guideData, err := os.ReadFile(guideFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum = sha256.Sum256(guideData)
+	reindexed := entries[2]
+	if reindexed.Tool != "reindex_document" || reindexed.Document != guideFile || reindexed.ContentHash != hex.EncodeToString(sum[:]) {
+		t.Errorf("Expected the path and hash of the re-indexed file to be recorded, got %+v", reindexed)
+	}
+
+	// Entries of a document can be found by its indexed path
+	byDocument, err := db.QueryAudit(vectordb.AuditQuery{Document: guideFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byDocument) != 2 {
+		t.Errorf("Expected 2 entries for %s, got %+v", guideFile, byDocument)
+	}
+
+	// The audit log can be queried through its own tool
+	text := callTool(t, ctx, c, "query_audit_log", map[string]any{"tool": "delete_document"})
+	if !strings.Contains(text, "old.md") || strings.Contains(text, "update_frontmatter") {
+		t.Errorf("Expected only the delete_document entry, got %s", text)
+	}
+}
+
+func TestEndToEnd_ListedDocumentNames(t *testing.T) {
+	tmpDir := t.TempDir()
+	testDir := tmpDir + "/test_documents"
+	dbPath := tmpDir + "/test_vectors.db"
+
+	if err := os.MkdirAll(testDir+"/guides", 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"guides/setup.md", "old.md"} {
+		if err := os.WriteFile(testDir+"/"+name, []byte("# "+name+"\n\nSome content."), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	addr := freeAddress(t)
+
+	cfg := config.DefaultConfig()
+	cfg.DocumentsDir = testDir
+	cfg.DBPath = dbPath
+	cfg.Server.Transport = config.TransportHTTP
+	cfg.Server.Address = addr
+
+	db, err := vectordb.Init(dbPath, embedder.DefaultDimensions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	emb := &embedder.MockEmbedder{}
+	idx := indexer.NewIndexer(db, emb, cfg)
+	if _, err := idx.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go mcp.NewMCPServer(idx, db, emb, cfg).Start(ctx)
+
+	c, err := client.NewStreamableHttpClient("http://" + addr + "/mcp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	connectClient(t, ctx, c)
+
+	var listed struct {
+		Documents []struct {
+			Filename string `json:"filename"`
+		} `json:"documents"`
+	}
+	if err := json.Unmarshal([]byte(callTool(t, ctx, c, "list_documents", map[string]any{})), &listed); err != nil {
+		t.Fatal(err)
+	}
+	names := map[string]bool{}
+	for _, doc := range listed.Documents {
+		names[doc.Filename] = true
+	}
+	oldName := testDir + "/old.md"
+	if !names[oldName] {
+		t.Fatalf("Expected %s to be listed, got %v", oldName, names)
+	}
+
+	// Names returned by list_documents are accepted as they are
+	setupName := testDir + "/guides/setup.md"
+	if !names[setupName] {
+		t.Fatalf("Expected %s to be listed, got %v", setupName, names)
+	}
+	var reindexed struct {
+		Success bool `json:"success"`
+		Chunks  int  `json:"chunks"`
+	}
+	if err := json.Unmarshal([]byte(callTool(t, ctx, c, "reindex_document", map[string]any{"filename": setupName})), &reindexed); err != nil {
+		t.Fatal(err)
+	}
+	if !reindexed.Success || reindexed.Chunks == 0 {
+		t.Errorf("Expected %s to be re-indexed, got %+v", setupName, reindexed)
+	}
+
+	// Paths relative to the documents directory still work
+	callTool(t, ctx, c, "reindex_document", map[string]any{"filename": "guides/setup.md"})
+
+	callTool(t, ctx, c, "delete_document", map[string]any{"filename": oldName})
+	if _, err := os.Stat(oldName); !os.IsNotExist(err) {
+		t.Errorf("Expected %s to be deleted, got %v", oldName, err)
+	}
+	if _, err := db.GetDocument(oldName); !errors.Is(err, vectordb.ErrDocumentNotFound) {
+		t.Errorf("Expected %s to be removed from the index, got %v", oldName, err)
+	}
+}
+
+func TestEndToEnd_ReadOnly(t *testing.T) {
+	tmpDir := t.TempDir()
+	testDir := tmpDir + "/test_documents"
+	dbPath := tmpDir + "/test_vectors.db"
+
+	if err := os.MkdirAll(testDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	testFile := testDir + "/guide.md"
+	if err := os.WriteFile(testFile, []byte("# Guide\n\nRun the installer."), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.DocumentsDir = testDir
+	cfg.DBPath = dbPath
+
+	// Build the index as a writable server would
+	emb := &embedder.MockEmbedder{}
+	settings := embedder.Settings{Model: embedder.MockModel, Dimensions: embedder.DefaultDimensions}
+	db, err := vectordb.Init(dbPath, embedder.DefaultDimensions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx := indexer.NewIndexer(db, emb, cfg)
+	if _, err := idx.EnsureEmbeddingSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := idx.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	addr := freeAddress(t)
+	cfg.ReadOnly = true
+	cfg.Server.Transport = config.TransportHTTP
+	cfg.Server.Address = addr
+
+	db, err = vectordb.OpenReadOnly(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	idx = indexer.NewIndexer(db, emb, cfg)
+
+	if err := idx.CheckEmbeddingSettings(settings); err != nil {
+		t.Errorf("Expected matching embedding settings, got %v", err)
+	}
+	other := settings
+	other.Model = "other-model"
+	if err := idx.CheckEmbeddingSettings(other); !errors.Is(err, indexer.ErrEmbeddingMismatch) {
+		t.Errorf("Expected ErrEmbeddingMismatch for other settings, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go mcp.NewMCPServer(idx, db, emb, cfg).Start(ctx)
+
+	c, err := client.NewStreamableHttpClient("http://" + addr + "/mcp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	connectClient(t, ctx, c)
+
+	tools, err := c.ListTools(ctx, mcpgo.ListToolsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tool := range tools.Tools {
+		switch tool.Name {
+		case "search", "list_documents", "check_index", "query_audit_log", "get_document", "get_chunk":
+		default:
+			t.Errorf("Expected only read-only tools, got %s", tool.Name)
+		}
+	}
+
+	text := callTool(t, ctx, c, "search", map[string]any{"query": "installer"})
+	if !strings.Contains(text, "guide.md") {
+		t.Errorf("Expected search to find guide.md, got %s", text)
+	}
+
+	request := mcpgo.CallToolRequest{}
+	request.Params.Name = "delete_document"
+	request.Params.Arguments = map[string]any{"filename": "guide.md"}
+	if result, err := c.CallTool(ctx, request); err == nil && !result.IsError {
+		t.Error("Expected delete_document to be unavailable")
+	}
+	if _, err := os.Stat(testFile); err != nil {
+		t.Errorf("Expected the document to be left on disk: %v", err)
+	}
+}
+
+func TestEndToEnd_GetDocumentAndChunk(t *testing.T) {
+	tmpDir := t.TempDir()
+	testDir := tmpDir + "/test_documents"
+	dbPath := tmpDir + "/test_vectors.db"
+
+	if err := os.MkdirAll(testDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	testFile := testDir + "/guide.md"
+	content := "---\ndomain: backend\ntags: [setup]\n---\n# Install\n\nRun the installer.\n\n# Configure\n\nEdit config.json.\n\n# Deploy\n\nPush to production."
+	if err := os.WriteFile(testFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	addr := freeAddress(t)
+
+	cfg := config.DefaultConfig()
+	cfg.DocumentsDir = testDir
+	cfg.DBPath = dbPath
+	cfg.Server.Transport = config.TransportHTTP
+	cfg.Server.Address = addr
+
+	db, err := vectordb.Init(dbPath, embedder.DefaultDimensions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	emb := &embedder.MockEmbedder{}
+	idx := indexer.NewIndexer(db, emb, cfg)
+	if _, err := idx.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go mcp.NewMCPServer(idx, db, emb, cfg).Start(ctx)
+
+	c, err := client.NewStreamableHttpClient("http://" + addr + "/mcp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	connectClient(t, ctx, c)
+
+	var document struct {
+		Filename   string `json:"filename"`
+		ChunkCount int    `json:"chunk_count"`
+		Metadata   struct {
+			Domain string   `json:"domain"`
+			Tags   []string `json:"tags"`
+		} `json:"metadata"`
+		Chunks []struct {
+			ChunkID     int64  `json:"chunk_id"`
+			Position    int    `json:"position"`
+			HeadingPath string `json:"heading_path"`
+			Content     string `json:"content"`
+		} `json:"chunks"`
+	}
+	text := callTool(t, ctx, c, "get_document", map[string]any{"filename": testFile})
+	if err := json.Unmarshal([]byte(text), &document); err != nil {
+		t.Fatalf("Failed to parse get_document result: %v", err)
+	}
+	if document.ChunkCount != 3 || len(document.Chunks) != 3 {
+		t.Fatalf("Expected all 3 chunks, got %s", text)
+	}
+	if document.Metadata.Domain != "backend" || len(document.Metadata.Tags) != 1 {
+		t.Errorf("Expected frontmatter metadata, got %+v", document.Metadata)
+	}
+
+	// A position range returns only part of the document
+	text = callTool(t, ctx, c, "get_document", map[string]any{"filename": testFile, "start_position": 1, "end_position": 1})
+	if err := json.Unmarshal([]byte(text), &document); err != nil {
+		t.Fatal(err)
+	}
+	if len(document.Chunks) != 1 || !strings.Contains(document.Chunks[0].Content, "config.json") {
+		t.Errorf("Expected only the Configure chunk, got %s", text)
+	}
+
+	// Chunk IDs from search results can be fetched directly
+	var search struct {
+		Results []struct {
+			ChunkID  int64
+			Position int
+		} `json:"results"`
+	}
+	text = callTool(t, ctx, c, "search", map[string]any{"query": "production", "top_k": 1})
+	if err := json.Unmarshal([]byte(text), &search); err != nil {
+		t.Fatal(err)
+	}
+	if len(search.Results) != 1 || search.Results[0].ChunkID == 0 {
+		t.Fatalf("Expected a search result with a chunk ID, got %s", text)
+	}
+
+	var chunk struct {
+		ChunkID  int64  `json:"chunk_id"`
+		Document string `json:"document"`
+		Position int    `json:"position"`
+	}
+	text = callTool(t, ctx, c, "get_chunk", map[string]any{"chunk_id": search.Results[0].ChunkID})
+	if err := json.Unmarshal([]byte(text), &chunk); err != nil {
+		t.Fatal(err)
+	}
+	if chunk.ChunkID != search.Results[0].ChunkID || chunk.Document != testFile || chunk.Position != search.Results[0].Position {
+		t.Errorf("Expected the chunk of the search result, got %s", text)
+	}
+
+	text = callTool(t, ctx, c, "get_chunk", map[string]any{"filename": testFile, "position": 2})
+	if !strings.Contains(text, "production") {
+		t.Errorf("Expected the Deploy chunk, got %s", text)
+	}
+}
+
+func TestEndToEnd_DocumentResources(t *testing.T) {
+	tmpDir := t.TempDir()
+	testDir := tmpDir + "/test_documents"
+	dbPath := tmpDir + "/test_vectors.db"
+
+	if err := os.MkdirAll(testDir+"/guides", 0755); err != nil {
+		t.Fatal(err)
+	}
+	guideFile := testDir + "/guides/setup.md"
+	if err := os.WriteFile(guideFile, []byte("# Setup\n\nRun the installer."), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	addr := freeAddress(t)
+
+	cfg := config.DefaultConfig()
+	cfg.DocumentsDir = testDir
+	cfg.DBPath = dbPath
+	cfg.Server.Transport = config.TransportSSE
+	cfg.Server.Address = addr
+
+	db, err := vectordb.Init(dbPath, embedder.DefaultDimensions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	emb := &embedder.MockEmbedder{}
+	idx := indexer.NewIndexer(db, emb, cfg)
+	if _, err := idx.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go mcp.NewMCPServer(idx, db, emb, cfg).Start(ctx)
+
+	c, err := client.NewSSEMCPClient("http://" + addr + "/sse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	notifications := make(chan mcpgo.JSONRPCNotification, 16)
+	c.OnNotification(func(n mcpgo.JSONRPCNotification) {
+		notifications <- n
+	})
+	connectClient(t, ctx, c)
+
+	const guideURI = "devrag://doc/guides/setup.md"
+	assertResources := func(want ...string) {
+		t.Helper()
+		result, err := c.ListResources(ctx, mcpgo.ListResourcesRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, r := range result.Resources {
+			got = append(got, r.URI)
+		}
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("Expected resources %v, got %v", want, got)
+		}
+	}
+	// waitForUpdate waits for a resources/updated notification of uri
+	waitForUpdate := func(uri string) {
+		t.Helper()
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case n := <-notifications:
+				if n.Method == "notifications/resources/updated" && n.Params.AdditionalFields["uri"] == uri {
+					return
+				}
+			case <-timeout:
+				t.Fatalf("Expected an update notification for %s", uri)
+			}
+		}
+	}
+
+	assertResources(guideURI)
+
+	readRequest := mcpgo.ReadResourceRequest{}
+	readRequest.Params.URI = guideURI
+	read, err := c.ReadResource(ctx, readRequest)
+	if err != nil {
+		t.Fatalf("ReadResource failed: %v", err)
+	}
+	if len(read.Contents) != 1 {
+		t.Fatalf("Expected one content, got %+v", read.Contents)
+	}
+	if text, ok := read.Contents[0].(mcpgo.TextResourceContents); !ok || !strings.Contains(text.Text, "Run the installer.") {
+		t.Errorf("Expected the indexed content, got %+v", read.Contents[0])
+	}
+
+	readRequest.Params.URI = "devrag://doc/../outside.md"
+	if _, err := c.ReadResource(ctx, readRequest); err == nil {
+		t.Error("Expected error for a path outside documents_dir")
+	}
+
+	// Re-indexing a changed file notifies clients
+	if err := os.WriteFile(guideFile, []byte("# Setup\n\nRun the new installer."), 0644); err != nil {
+		t.Fatal(err)
+	}
+	callTool(t, ctx, c, "reindex_document", map[string]any{"filename": "guides/setup.md"})
+	waitForUpdate(guideURI)
+
+	// New documents are added to the list, deleted ones are removed
+	newFile := testDir + "/notes.md"
+	if err := os.WriteFile(newFile, []byte("# Notes\n\nSome notes."), 0644); err != nil {
+		t.Fatal(err)
+	}
+	callTool(t, ctx, c, "index_markdown", map[string]any{"filepath": newFile})
+	waitForUpdate("devrag://doc/notes.md")
+	assertResources(guideURI, "devrag://doc/notes.md")
+
+	callTool(t, ctx, c, "delete_document", map[string]any{"filename": "notes.md"})
+	waitForUpdate("devrag://doc/notes.md")
+	assertResources(guideURI)
+}
+
+func TestEndToEnd_SyncChangeNotifications(t *testing.T) {
+	tmpDir := t.TempDir()
+	testDir := tmpDir + "/test_documents"
+	dbPath := tmpDir + "/test_vectors.db"
+
+	if err := os.MkdirAll(testDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	keepFile := testDir + "/keep.md"
+	goneFile := testDir + "/gone.md"
+	for _, f := range []string{keepFile, goneFile} {
+		if err := os.WriteFile(f, []byte("# "+filepath.Base(f)+"\n\nContent."), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.DocumentsDir = testDir
+	cfg.DBPath = dbPath
+
+	db, err := vectordb.Init(dbPath, embedder.DefaultDimensions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	idx := indexer.NewIndexer(db, &embedder.MockEmbedder{}, cfg)
+	if _, err := idx.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	changes := map[string]bool{} // filename -> removed
+	idx.OnChange(func(filename string, removed bool) {
+		changes[filename] = removed
+	})
+
+	if err := os.Remove(goneFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keepFile, []byte("# keep.md\n\nChanged content."), 0644); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(keepFile, future, future); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := idx.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	if removed, ok := changes[keepFile]; !ok || removed {
+		t.Errorf("Expected a change for the updated file, got %v", changes)
+	}
+	if removed, ok := changes[goneFile]; !ok || !removed {
+		t.Errorf("Expected a removal for the deleted file, got %v", changes)
+	}
+}
+
+func TestEndToEnd_Prompts(t *testing.T) {
+	tmpDir := t.TempDir()
+	testDir := tmpDir + "/test_documents"
+	dbPath := tmpDir + "/test_vectors.db"
+
+	if err := os.MkdirAll(testDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"auth.md":  "---\ndocType: spec\n---\n# Authentication\n\nTokens expire after one hour.\n\n# Refresh\n\nRefresh tokens rotate on use.",
+		"notes.md": "# Notes\n\nMeeting notes about lunch.",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(testDir+"/"+name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	addr := freeAddress(t)
+
+	cfg := config.DefaultConfig()
+	cfg.DocumentsDir = testDir
+	cfg.DBPath = dbPath
+	cfg.Server.Transport = config.TransportHTTP
+	cfg.Server.Address = addr
+
+	db, err := vectordb.Init(dbPath, embedder.DefaultDimensions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	emb := &embedder.MockEmbedder{}
+	idx := indexer.NewIndexer(db, emb, cfg)
+	if _, err := idx.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go mcp.NewMCPServer(idx, db, emb, cfg).Start(ctx)
+
+	c, err := client.NewStreamableHttpClient("http://" + addr + "/mcp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	connectClient(t, ctx, c)
+
+	list, err := c.ListPrompts(ctx, mcpgo.ListPromptsRequest{})
+	if err != nil {
+		t.Fatalf("ListPrompts failed: %v", err)
+	}
+	names := map[string]bool{}
+	for _, p := range list.Prompts {
+		names[p.Name] = true
+	}
+	for _, name := range []string{"answer_from_docs", "summarize_document", "find_related_specs"} {
+		if !names[name] {
+			t.Errorf("Expected prompt %s, got %+v", name, list.Prompts)
+		}
+	}
+
+	getPrompt := func(name string, args map[string]string) string {
+		t.Helper()
+		req := mcpgo.GetPromptRequest{}
+		req.Params.Name = name
+		req.Params.Arguments = args
+		result, err := c.GetPrompt(ctx, req)
+		if err != nil {
+			t.Fatalf("GetPrompt %s failed: %v", name, err)
+		}
+		if len(result.Messages) != 1 {
+			t.Fatalf("Expected one message from %s, got %d", name, len(result.Messages))
+		}
+		text, ok := result.Messages[0].Content.(mcpgo.TextContent)
+		if !ok {
+			t.Fatalf("Expected text content from %s, got %T", name, result.Messages[0].Content)
+		}
+		return text.Text
+	}
+
+	// answer_from_docs includes retrieved chunks with citations
+	text := getPrompt("answer_from_docs", map[string]string{"question": "When do tokens expire?", "top_k": "2"})
+	if !strings.Contains(text, "When do tokens expire?") || !strings.Contains(text, "[1] ") || !strings.Contains(text, "(chunk ") {
+		t.Errorf("Expected question and cited excerpts, got:\n%s", text)
+	}
+	if strings.Contains(text, "[3] ") {
+		t.Errorf("Expected at most top_k excerpts, got:\n%s", text)
+	}
+
+	// Filters restrict the excerpts
+	text = getPrompt("answer_from_docs", map[string]string{"question": "tokens", "filters": "docType=spec"})
+	if strings.Contains(text, "notes.md") {
+		t.Errorf("Expected filter to exclude notes.md, got:\n%s", text)
+	}
+
+	// summarize_document includes every chunk of the document
+	text = getPrompt("summarize_document", map[string]string{"filename": testDir + "/auth.md"})
+	if !strings.Contains(text, "Tokens expire after one hour.") || !strings.Contains(text, "Refresh tokens rotate on use.") {
+		t.Errorf("Expected all chunks of auth.md, got:\n%s", text)
+	}
+
+	// find_related_specs only includes spec documents, grouped by document
+	text = getPrompt("find_related_specs", map[string]string{"topic": "token refresh"})
+	if !strings.Contains(text, "## "+testDir+"/auth.md") || strings.Contains(text, "notes.md") {
+		t.Errorf("Expected only auth.md, got:\n%s", text)
+	}
+
+	// Invalid arguments are rejected
+	req := mcpgo.GetPromptRequest{}
+	req.Params.Name = "summarize_document"
+	req.Params.Arguments = map[string]string{"filename": testDir + "/missing.md"}
+	if _, err := c.GetPrompt(ctx, req); err == nil {
+		t.Error("Expected error for a document that is not indexed")
+	}
+	req.Params.Name = "answer_from_docs"
+	req.Params.Arguments = map[string]string{"question": "tokens", "top_k": "zero"}
+	if _, err := c.GetPrompt(ctx, req); err == nil {
+		t.Error("Expected error for an invalid top_k")
+	}
+}
+
+// freeAddress returns a localhost address with a port that is currently unused
+func freeAddress(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return listener.Addr().String()
+}
+
+// connectClient starts and initializes an MCP client, retrying while the server starts
+func connectClient(t *testing.T, ctx context.Context, c *client.Client) {
+	t.Helper()
+	var err error
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if err = c.Start(ctx); err == nil {
+			initRequest := mcpgo.InitializeRequest{}
+			initRequest.Params.ProtocolVersion = mcpgo.LATEST_PROTOCOL_VERSION
+			initRequest.Params.ClientInfo = mcpgo.Implementation{Name: "test", Version: "1.0.0"}
+			if _, err = c.Initialize(ctx, initRequest); err == nil {
+				return
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("Client failed to connect: %v", err)
+}
+
+// callTool calls a tool that is expected to succeed and returns its text result
+func callTool(t *testing.T, ctx context.Context, c *client.Client, name string, args map[string]any) string {
+	t.Helper()
+	request := mcpgo.CallToolRequest{}
+	request.Params.Name = name
+	request.Params.Arguments = args
+	result, err := c.CallTool(ctx, request)
+	if err != nil {
+		t.Fatalf("%s failed: %v", name, err)
+	}
+	if result.IsError || len(result.Content) == 0 {
+		t.Fatalf("Expected %s to succeed, got %+v", name, result)
+	}
+	text, ok := result.Content[0].(mcpgo.TextContent)
+	if !ok {
+		t.Fatalf("Expected text content from %s, got %+v", name, result.Content)
+	}
+	return text.Text
+}
@@ -1,24 +1,72 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+
+	"github.com/tomohiro-owada/devrag/internal/sources"
+)
+
+// Actions taken at startup when the index was built with different embedding settings
+const (
+	OnMismatchRebuild = "rebuild" // clear the index and re-embed every document
+	OnMismatchRefuse  = "refuse"  // exit with an error and leave the index untouched
+)
+
+// MCP transports
+const (
+	TransportStdio = "stdio" // one client, spawned by the editor
+	TransportHTTP  = "http"  // streamable HTTP, shared by many clients
+	TransportSSE   = "sse"   // HTTP with server-sent events, for older clients
 )
 
 type Config struct {
-	DocumentsDir string `json:"documents_dir"`
-	DBPath       string `json:"db_path"`
-	ChunkSize    int    `json:"chunk_size"`
-	SearchTopK   int    `json:"search_top_k"`
+	DocumentsDir string   `json:"documents_dir"`
+	Sources      []Source `json:"sources,omitempty"` // replaces documents_dir when set
+	DBPath       string   `json:"db_path"`
+	ModelsDir    string   `json:"models_dir"` // downloaded models, one directory per model
+	ChunkSize    int      `json:"chunk_size"`
+	SearchTopK   int      `json:"search_top_k"`
+	ReadOnly     bool     `json:"read_only"` // never modify documents or the index
 	Compute      struct {
 		Device        string `json:"device"`
 		FallbackToCPU bool   `json:"fallback_to_cpu"`
+		BatchSize     int    `json:"batch_size"`
 	} `json:"compute"`
 	Model struct {
-		Name       string `json:"name"`
-		Dimensions int    `json:"dimensions"`
+		Name        string `json:"name"`
+		Dimensions  int    `json:"dimensions"`
+		UsePrefixes bool   `json:"use_prefixes"`
+		OnMismatch  string `json:"on_mismatch"` // rebuild or refuse when the index was built with other settings
 	} `json:"model"`
+	Indexing struct {
+		Workers        int `json:"workers"`          // files read and parsed in parallel; 0 uses one per CPU
+		WriteBatchSize int `json:"write_batch_size"` // documents stored per database transaction
+	} `json:"indexing"`
+	Watch struct {
+		Enabled    bool `json:"enabled"`
+		DebounceMs int  `json:"debounce_ms"`
+	} `json:"watch"`
+	Server struct {
+		Transport string `json:"transport"`
+		Address   string `json:"address"` // listen address for the http and sse transports
+	} `json:"server"`
+}
+
+// Source is a directory tree of documents to index
+type Source = sources.Source
+
+// DocumentSources returns the configured sources, or documents_dir as the only
+// source if none are configured
+func (c *Config) DocumentSources() []Source {
+	if len(c.Sources) > 0 {
+		return c.Sources
+	}
+	return []Source{{Path: c.DocumentsDir}}
 }
 
 // DefaultConfig returns default configuration
@@ -26,50 +74,229 @@ func DefaultConfig() *Config {
 	cfg := &Config{
 		DocumentsDir: "./documents",
 		DBPath:       "./vectors.db",
+		ModelsDir:    "./models",
 		ChunkSize:    500,
 		SearchTopK:   5,
 	}
 	cfg.Compute.Device = "auto"
 	cfg.Compute.FallbackToCPU = true
+	cfg.Compute.BatchSize = 16
 	cfg.Model.Name = "multilingual-e5-small"
 	cfg.Model.Dimensions = 384
+	cfg.Model.UsePrefixes = true
+	cfg.Model.OnMismatch = OnMismatchRebuild
+	cfg.Indexing.WriteBatchSize = 32
+	cfg.Watch.Enabled = true
+	cfg.Watch.DebounceMs = 500
+	cfg.Server.Transport = TransportStdio
+	cfg.Server.Address = "127.0.0.1:8765"
 	return cfg
 }
 
-// Load reads config from file or returns default
+// Config file locations
+const (
+	// ConfigEnv names the environment variable that selects the config file
+	ConfigEnv = "DEVRAG_CONFIG"
+
+	configFileName   = "config.json"
+	projectConfigDir = ".devrag" // searched for in the working directory and its parents
+)
+
+// Load reads the configuration from the config files found by FindConfigFiles
+// and applies DEVRAG_* environment overrides
 func Load() (*Config, error) {
-	const configFile = "config.json"
-
-	// Check if config.json exists
-	if _, err := os.Stat(configFile); os.IsNotExist(err) {
-		fmt.Fprintf(os.Stderr, "[INFO] config.json not found, using defaults\n")
-		cfg := DefaultConfig()
-
-		// Generate template
-		if err := cfg.Save(configFile); err != nil {
-			fmt.Fprintf(os.Stderr, "[WARN] Failed to generate config template: %v\n", err)
-		} else {
-			fmt.Fprintf(os.Stderr, "[INFO] Generated config template: %s\n", configFile)
+	return LoadFrom("")
+}
+
+// LoadFrom reads the configuration like Load, using the config file at path
+// instead of searching for the project config if path is not empty
+// Relative paths in a config file are resolved against the file's directory.
+// Default paths are resolved against the directory of the last file loaded.
+func LoadFrom(path string) (*Config, error) {
+	files, err := FindConfigFiles(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := DefaultConfig()
+	documentsDirSet, dbPathSet, modelsDirSet := false, false, false
+	for _, file := range files {
+		set, err := cfg.merge(file)
+		if err != nil {
+			return nil, err
+		}
+		documentsDirSet = documentsDirSet || set.DocumentsDir != nil
+		dbPathSet = dbPathSet || set.DBPath != nil
+		modelsDirSet = modelsDirSet || set.ModelsDir != nil
+		fmt.Fprintf(os.Stderr, "[INFO] Loaded configuration from %s\n", file)
+	}
+
+	if len(files) == 0 {
+		fmt.Fprintf(os.Stderr, "[INFO] No config file found, using defaults\n")
+	} else {
+		dir := filepath.Dir(files[len(files)-1])
+		if !documentsDirSet {
+			cfg.DocumentsDir = resolvePath(dir, cfg.DocumentsDir)
+		}
+		if !dbPathSet {
+			cfg.DBPath = resolvePath(dir, cfg.DBPath)
+		}
+		if !modelsDirSet {
+			cfg.ModelsDir = resolvePath(dir, cfg.ModelsDir)
+		}
+	}
+
+	if err := cfg.ApplyEnv(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// FindConfigFiles returns the config files to load, lowest precedence first:
+// the user config ($XDG_CONFIG_HOME/devrag/config.json or the platform
+// equivalent) followed by the project config. The project config is path if it
+// is not empty, else the file named by DEVRAG_CONFIG, else config.json in the
+// working directory, else the nearest .devrag/config.json in the working
+// directory or one of its parents.
+func FindConfigFiles(path string) ([]string, error) {
+	var files []string
+	if dir, err := os.UserConfigDir(); err == nil {
+		user := filepath.Join(dir, "devrag", configFileName)
+		if fileExists(user) {
+			files = append(files, user)
+		}
+	}
+
+	if path == "" {
+		path = os.Getenv(ConfigEnv)
+	}
+	if path != "" {
+		if !fileExists(path) {
+			return nil, fmt.Errorf("config file not found: %s", path)
+		}
+		return appendConfigFile(files, path), nil
+	}
+
+	if fileExists(configFileName) {
+		return appendConfigFile(files, configFileName), nil
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return files, nil
+	}
+	for dir := wd; ; dir = filepath.Dir(dir) {
+		project := filepath.Join(dir, projectConfigDir, configFileName)
+		if fileExists(project) {
+			return appendConfigFile(files, project), nil
 		}
+		if filepath.Dir(dir) == dir {
+			return files, nil
+		}
+	}
+}
 
-		return cfg, nil
+// appendConfigFile adds a config file unless it is the user config loaded already
+func appendConfigFile(files []string, path string) []string {
+	for _, f := range files {
+		if sameFile(f, path) {
+			return files
+		}
 	}
+	return append(files, path)
+}
+
+// configPaths records which path fields a config file sets
+type configPaths struct {
+	DocumentsDir *string          `json:"documents_dir"`
+	DBPath       *string          `json:"db_path"`
+	ModelsDir    *string          `json:"models_dir"`
+	Sources      *json.RawMessage `json:"sources"`
+}
 
-	// Read existing config
-	data, err := os.ReadFile(configFile)
+// merge overrides the fields set in a config file and resolves the paths it
+// sets against its directory. Invalid JSON, unknown keys and values of the
+// wrong type are rejected.
+func (c *Config) merge(path string) (*configPaths, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config: %w", err)
 	}
 
-	cfg := DefaultConfig()
-	if err := json.Unmarshal(data, cfg); err != nil {
-		fmt.Fprintf(os.Stderr, "[WARN] Invalid JSON in config.json: %v\n", err)
-		fmt.Fprintf(os.Stderr, "[WARN] Using default configuration\n")
-		return cfg, nil
+	if err := checkKeys(data); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, describeJSONError(data, err))
+	}
+	merged := *c
+	if err := json.Unmarshal(data, &merged); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, describeJSONError(data, err))
 	}
 
-	fmt.Fprintf(os.Stderr, "[INFO] Loaded configuration from %s\n", configFile)
-	return cfg, nil
+	set := &configPaths{}
+	if err := json.Unmarshal(data, set); err != nil {
+		return nil, fmt.Errorf("failed to parse config paths: %w", err)
+	}
+
+	dir := filepath.Dir(path)
+	if set.DocumentsDir != nil {
+		merged.DocumentsDir = resolvePath(dir, merged.DocumentsDir)
+	}
+	if set.DBPath != nil {
+		merged.DBPath = resolvePath(dir, merged.DBPath)
+	}
+	if set.ModelsDir != nil {
+		merged.ModelsDir = resolvePath(dir, merged.ModelsDir)
+	}
+	if set.Sources != nil {
+		for i := range merged.Sources {
+			merged.Sources[i].Path = resolvePath(dir, merged.Sources[i].Path)
+		}
+	}
+	*c = merged
+	return set, nil
+}
+
+// describeJSONError adds the position of a syntax error and the field of a type error
+func describeJSONError(data []byte, err error) error {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		// Offset counts the bytes read up to and including the offending one
+		offset := syntaxErr.Offset
+		if offset > 0 {
+			offset--
+		}
+		before := data[:offset]
+		line := bytes.Count(before, []byte("\n")) + 1
+		column := len(before) - bytes.LastIndexByte(before, '\n')
+		return fmt.Errorf("line %d, column %d: %w", line, column, err)
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return FieldError{Field: typeErr.Field, Message: fmt.Sprintf("must be %s, got %s", typeErr.Type, typeErr.Value)}
+	}
+	return err
+}
+
+// resolvePath resolves a relative path against dir
+// config.json in the working directory keeps its paths relative, so that
+// documents stay indexed under the same names as before config discovery
+func resolvePath(dir, path string) string {
+	if path == "" || filepath.IsAbs(path) || dir == "." {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// fileExists reports whether path exists and is not a directory
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// sameFile reports whether two paths name the same existing file
+func sameFile(a, b string) bool {
+	infoA, errA := os.Stat(a)
+	infoB, errB := os.Stat(b)
+	return errA == nil && errB == nil && os.SameFile(infoA, infoB)
 }
 
 // Save writes config to file
@@ -85,17 +312,3 @@ func (c *Config) Save(path string) error {
 
 	return nil
 }
-
-// Validate checks config values
-func (c *Config) Validate() error {
-	if c.ChunkSize <= 0 {
-		return fmt.Errorf("chunk_size must be positive")
-	}
-	if c.SearchTopK <= 0 {
-		return fmt.Errorf("search_top_k must be positive")
-	}
-	if c.Model.Dimensions <= 0 {
-		return fmt.Errorf("model.dimensions must be positive")
-	}
-	return nil
-}
@@ -1,7 +1,11 @@
 package config
 
 import (
+	"errors"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -12,12 +16,17 @@ func TestLoadConfig_NoFile(t *testing.T) {
 	defer os.Chdir(originalDir)
 
 	os.Chdir(tmpDir)
+	isolateUserConfig(t)
 
 	cfg, err := Load()
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
 
+	if _, err := os.Stat("config.json"); !os.IsNotExist(err) {
+		t.Error("Expected no config template to be written")
+	}
+
 	if cfg.DocumentsDir != "./documents" {
 		t.Errorf("Expected default documents_dir, got %s", cfg.DocumentsDir)
 	}
@@ -38,6 +47,18 @@ func TestLoadConfig_NoFile(t *testing.T) {
 		t.Error("Expected default fallback_to_cpu to be true")
 	}
 
+	if cfg.Compute.BatchSize != 16 {
+		t.Errorf("Expected default batch_size 16, got %d", cfg.Compute.BatchSize)
+	}
+
+	if !cfg.Watch.Enabled {
+		t.Error("Expected default watch.enabled to be true")
+	}
+
+	if cfg.Watch.DebounceMs != 500 {
+		t.Errorf("Expected default watch.debounce_ms 500, got %d", cfg.Watch.DebounceMs)
+	}
+
 	if cfg.Model.Name != "multilingual-e5-small" {
 		t.Errorf("Expected default model name, got %s", cfg.Model.Name)
 	}
@@ -45,6 +66,10 @@ func TestLoadConfig_NoFile(t *testing.T) {
 	if cfg.Model.Dimensions != 384 {
 		t.Errorf("Expected default dimensions 384, got %d", cfg.Model.Dimensions)
 	}
+
+	if !cfg.Model.UsePrefixes {
+		t.Error("Expected default use_prefixes to be true")
+	}
 }
 
 func TestLoadConfig_Valid(t *testing.T) {
@@ -54,6 +79,7 @@ func TestLoadConfig_Valid(t *testing.T) {
 	defer os.Chdir(originalDir)
 
 	os.Chdir(tmpDir)
+	isolateUserConfig(t)
 
 	// Create test config
 	testConfig := `{
@@ -152,6 +178,63 @@ func TestValidate(t *testing.T) {
 			},
 			wantError: true,
 		},
+		{
+			name: "zero batch_size",
+			modify: func(c *Config) {
+				c.Compute.BatchSize = 0
+			},
+			wantError: true,
+		},
+		{
+			name: "zero debounce_ms",
+			modify: func(c *Config) {
+				c.Watch.DebounceMs = 0
+			},
+			wantError: true,
+		},
+		{
+			name: "refuse on_mismatch",
+			modify: func(c *Config) {
+				c.Model.OnMismatch = OnMismatchRefuse
+			},
+			wantError: false,
+		},
+		{
+			name: "unknown on_mismatch",
+			modify: func(c *Config) {
+				c.Model.OnMismatch = "ignore"
+			},
+			wantError: true,
+		},
+		{
+			name: "http transport",
+			modify: func(c *Config) {
+				c.Server.Transport = TransportHTTP
+			},
+			wantError: false,
+		},
+		{
+			name: "unknown transport",
+			modify: func(c *Config) {
+				c.Server.Transport = "websocket"
+			},
+			wantError: true,
+		},
+		{
+			name: "sse transport without address",
+			modify: func(c *Config) {
+				c.Server.Transport = TransportSSE
+				c.Server.Address = ""
+			},
+			wantError: true,
+		},
+		{
+			name: "stdio transport without address",
+			modify: func(c *Config) {
+				c.Server.Address = ""
+			},
+			wantError: false,
+		},
 		{
 			name: "negative dimensions",
 			modify: func(c *Config) {
@@ -166,6 +249,48 @@ func TestValidate(t *testing.T) {
 			},
 			wantError: true,
 		},
+		{
+			name: "negative indexing workers",
+			modify: func(c *Config) {
+				c.Indexing.Workers = -1
+			},
+			wantError: true,
+		},
+		{
+			name: "zero write_batch_size",
+			modify: func(c *Config) {
+				c.Indexing.WriteBatchSize = 0
+			},
+			wantError: true,
+		},
+		{
+			name: "unknown device",
+			modify: func(c *Config) {
+				c.Compute.Device = "cuda"
+			},
+			wantError: true,
+		},
+		{
+			name: "unknown model",
+			modify: func(c *Config) {
+				c.Model.Name = "no-such-model"
+			},
+			wantError: true,
+		},
+		{
+			name: "chunk_size above the model's estimated token limit is clamped later",
+			modify: func(c *Config) {
+				c.ChunkSize = 2000
+			},
+			wantError: false,
+		},
+		{
+			name: "dimensions not matching the model",
+			modify: func(c *Config) {
+				c.Model.Dimensions = 768
+			},
+			wantError: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -184,6 +309,191 @@ func TestValidate(t *testing.T) {
 	}
 }
 
+func TestValidate_ReportsAllProblems(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ChunkSize = 0
+	cfg.Compute.Device = "cuda"
+	cfg.Model.OnMismatch = "ignore"
+
+	err := cfg.Validate()
+	var problems ValidationError
+	if !errors.As(err, &problems) {
+		t.Fatalf("Expected a ValidationError, got %v", err)
+	}
+
+	var fields []string
+	for _, p := range problems {
+		fields = append(fields, p.Field)
+	}
+	want := []string{"chunk_size", "compute.device", "model.on_mismatch"}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("Expected problems with %v, got %v", want, err)
+	}
+}
+
+func TestClamp(t *testing.T) {
+	cfg := DefaultConfig()
+	if adjustments := cfg.Clamp(); len(adjustments) != 0 {
+		t.Errorf("Expected no adjustments for the defaults, got %v", adjustments)
+	}
+
+	// 1024 characters are estimated at 512 tokens, the limit of the default model
+	cfg.ChunkSize = 1024
+	if adjustments := cfg.Clamp(); len(adjustments) != 0 || cfg.ChunkSize != 1024 {
+		t.Errorf("Expected no adjustments at the estimated limit, got %v (chunk_size %d)", adjustments, cfg.ChunkSize)
+	}
+
+	cfg.ChunkSize = 2000
+	adjustments := cfg.Clamp()
+	if len(adjustments) != 1 || adjustments[0].Field != "chunk_size" {
+		t.Errorf("Expected a chunk_size adjustment, got %v", adjustments)
+	}
+	if cfg.ChunkSize != 1024 {
+		t.Errorf("Expected chunk_size to be clamped to 1024, got %d", cfg.ChunkSize)
+	}
+}
+
+func TestValidate_Paths(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file.md")
+	writeConfig(t, file, "# File")
+
+	tests := []struct {
+		name      string
+		modify    func(*Config)
+		wantField string
+	}{
+		{
+			name:   "missing documents_dir is created at startup",
+			modify: func(c *Config) { c.DocumentsDir = filepath.Join(dir, "missing") },
+		},
+		{
+			name:      "documents_dir is a file",
+			modify:    func(c *Config) { c.DocumentsDir = file },
+			wantField: "documents_dir",
+		},
+		{
+			name: "missing documents_dir in read-only mode",
+			modify: func(c *Config) {
+				c.ReadOnly = true
+				c.DBPath = file
+				c.DocumentsDir = filepath.Join(dir, "missing")
+			},
+			wantField: "documents_dir",
+		},
+		{
+			name:      "db_path is a directory",
+			modify:    func(c *Config) { c.DBPath = dir },
+			wantField: "db_path",
+		},
+		{
+			name:      "db_path in a missing directory",
+			modify:    func(c *Config) { c.DBPath = filepath.Join(dir, "missing", "vectors.db") },
+			wantField: "db_path",
+		},
+		{
+			name:   "sources replace documents_dir",
+			modify: func(c *Config) { c.DocumentsDir = file; c.Sources = []Source{{Path: dir}} },
+		},
+		{
+			name:      "missing source root",
+			modify:    func(c *Config) { c.Sources = []Source{{Path: dir}, {Path: filepath.Join(dir, "missing")}} },
+			wantField: "sources[1].path",
+		},
+		{
+			name:      "invalid exclude pattern",
+			modify:    func(c *Config) { c.Sources = []Source{{Path: dir, Exclude: []string{"docs/[a-"}}} },
+			wantField: "sources[0].exclude[0]",
+		},
+		{
+			name: "missing db_path in read-only mode",
+			modify: func(c *Config) {
+				c.ReadOnly = true
+				c.DBPath = filepath.Join(dir, "vectors.db")
+			},
+			wantField: "db_path",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.DocumentsDir = dir
+			cfg.DBPath = filepath.Join(dir, "vectors.db")
+			tt.modify(cfg)
+
+			err := cfg.Validate()
+			if tt.wantField == "" {
+				if err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+				return
+			}
+			var problems ValidationError
+			if !errors.As(err, &problems) || len(problems) != 1 || problems[0].Field != tt.wantField {
+				t.Errorf("Expected a single problem with %s, got %v", tt.wantField, err)
+			}
+		})
+	}
+}
+
+func TestCheckWritable(t *testing.T) {
+	dir := t.TempDir()
+	cfg := DefaultConfig()
+	cfg.DocumentsDir = dir
+	cfg.DBPath = filepath.Join(dir, "vectors.db")
+
+	// Validate does not touch the file system
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.CheckWritable(); err != nil {
+		t.Errorf("Expected %s to be writable, got %v", dir, err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected no files to be left behind, got %v", entries)
+	}
+
+	cfg.DBPath = filepath.Join(dir, "missing", "vectors.db")
+	var field FieldError
+	if err := cfg.CheckWritable(); !errors.As(err, &field) || field.Field != "db_path" {
+		t.Errorf("Expected a db_path error for a missing directory, got %v", err)
+	}
+}
+
+func TestLoadFrom_Strict(t *testing.T) {
+	isolateUserConfig(t)
+	dir := t.TempDir()
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"invalid JSON", "{\n  \"chunk_size\": 500,\n}", "line 3, column 1"},
+		{"unknown key", `{"chunk_sise": 500}`, "chunk_sise: unknown field"},
+		{"unknown nested key", `{"compute": {"devise": "cpu"}}`, "compute.devise: unknown field"},
+		{"wrong type", `{"model": {"dimensions": "384"}}`, "model.dimensions: must be int"},
+		{"unknown source key", `{"sources": [{"path": "docs"}, {"path": "adr", "exclud": []}]}`, "sources[1].exclud: unknown field"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "config.json")
+			writeConfig(t, path, tt.content)
+
+			_, err := LoadFrom(path)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected an error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
 func TestSave(t *testing.T) {
 	tmpDir := t.TempDir()
 	configPath := tmpDir + "/config.json"
@@ -237,3 +547,174 @@ func TestDefaultConfig(t *testing.T) {
 		t.Errorf("Wrong default search_top_k: %d", cfg.SearchTopK)
 	}
 }
+
+// isolateUserConfig points the user config directory at an empty directory
+// and clears DEVRAG_* overrides that could leak in from the environment
+func isolateUserConfig(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+	t.Setenv("AppData", dir)
+	t.Setenv(ConfigEnv, "")
+	return dir
+}
+
+// writeConfig writes a config file, creating its directory
+func writeConfig(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadFrom_ResolvesRelativePaths(t *testing.T) {
+	isolateUserConfig(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "conf", "devrag.json")
+	writeConfig(t, path, `{"documents_dir": "../docs", "db_path": "/var/lib/devrag/vectors.db", "models_dir": "models", "chunk_size": 300,
+		"sources": [{"path": "../adr"}, {"path": "/srv/docs", "exclude": ["drafts/**"]}]}`)
+
+	cfg, err := LoadFrom(path)
+	if err != nil {
+		t.Fatalf("LoadFrom failed: %v", err)
+	}
+	if want := filepath.Join(dir, "docs"); cfg.DocumentsDir != want {
+		t.Errorf("Expected documents_dir %s, got %s", want, cfg.DocumentsDir)
+	}
+	if cfg.DBPath != "/var/lib/devrag/vectors.db" {
+		t.Errorf("Expected absolute db_path to be kept, got %s", cfg.DBPath)
+	}
+	if want := filepath.Join(dir, "conf", "models"); cfg.ModelsDir != want {
+		t.Errorf("Expected models_dir %s, got %s", want, cfg.ModelsDir)
+	}
+	if cfg.ChunkSize != 300 {
+		t.Errorf("Expected chunk_size 300, got %d", cfg.ChunkSize)
+	}
+	want := []Source{{Path: filepath.Join(dir, "adr")}, {Path: "/srv/docs", Exclude: []string{"drafts/**"}}}
+	if !reflect.DeepEqual(cfg.DocumentSources(), want) {
+		t.Errorf("Expected sources %+v, got %+v", want, cfg.DocumentSources())
+	}
+
+	if _, err := LoadFrom(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("Expected error for a missing config file")
+	}
+}
+
+func TestLoad_ConfigEnv(t *testing.T) {
+	isolateUserConfig(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "devrag.json")
+	writeConfig(t, path, `{"search_top_k": 9}`)
+	t.Setenv(ConfigEnv, path)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.SearchTopK != 9 {
+		t.Errorf("Expected search_top_k from %s, got %d", ConfigEnv, cfg.SearchTopK)
+	}
+	// Default paths are resolved against the config file's directory
+	if want := filepath.Join(dir, "documents"); cfg.DocumentsDir != want {
+		t.Errorf("Expected documents_dir %s, got %s", want, cfg.DocumentsDir)
+	}
+}
+
+func TestLoad_ProjectDiscovery(t *testing.T) {
+	isolateUserConfig(t)
+	root := t.TempDir()
+	writeConfig(t, filepath.Join(root, ".devrag", "config.json"), `{"documents_dir": "../docs"}`)
+	sub := filepath.Join(root, "src", "pkg")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	originalDir, _ := os.Getwd()
+	defer os.Chdir(originalDir)
+	os.Chdir(sub)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	// Compare resolved paths, since the temporary directory may be a symlink
+	wantRoot, _ := filepath.EvalSymlinks(root)
+	gotRoot, _ := filepath.EvalSymlinks(filepath.Dir(cfg.DocumentsDir))
+	if gotRoot != wantRoot || filepath.Base(cfg.DocumentsDir) != "docs" {
+		t.Errorf("Expected documents_dir %s, got %s", filepath.Join(root, "docs"), cfg.DocumentsDir)
+	}
+	if !filepath.IsAbs(cfg.DBPath) || filepath.Base(filepath.Dir(cfg.DBPath)) != ".devrag" {
+		t.Errorf("Expected db_path inside .devrag, got %s", cfg.DBPath)
+	}
+	// Models are shared by every working directory below the project
+	if !filepath.IsAbs(cfg.ModelsDir) || filepath.Base(filepath.Dir(cfg.ModelsDir)) != ".devrag" {
+		t.Errorf("Expected models_dir inside .devrag, got %s", cfg.ModelsDir)
+	}
+}
+
+func TestLoad_UserConfigLayering(t *testing.T) {
+	userDir := isolateUserConfig(t)
+	writeConfig(t, filepath.Join(userDir, "devrag", "config.json"), `{"chunk_size": 700, "search_top_k": 8}`)
+
+	tmpDir := t.TempDir()
+	originalDir, _ := os.Getwd()
+	defer os.Chdir(originalDir)
+	os.Chdir(tmpDir)
+
+	// The user config alone provides the settings
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.ChunkSize != 700 || cfg.SearchTopK != 8 {
+		t.Errorf("Expected settings from the user config, got chunk_size %d, search_top_k %d", cfg.ChunkSize, cfg.SearchTopK)
+	}
+
+	// The project config overrides only the fields it sets
+	writeConfig(t, "config.json", `{"search_top_k": 3}`)
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.ChunkSize != 700 || cfg.SearchTopK != 3 {
+		t.Errorf("Expected project config over user config, got chunk_size %d, search_top_k %d", cfg.ChunkSize, cfg.SearchTopK)
+	}
+	// config.json in the working directory keeps relative paths
+	if cfg.DocumentsDir != "./documents" {
+		t.Errorf("Expected relative documents_dir, got %s", cfg.DocumentsDir)
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	isolateUserConfig(t)
+	t.Setenv("DEVRAG_DOCUMENTS_DIR", "/srv/docs")
+	t.Setenv("DEVRAG_CHUNK_SIZE", "800")
+	t.Setenv("DEVRAG_READ_ONLY", "true")
+	t.Setenv("DEVRAG_COMPUTE_DEVICE", "cpu")
+	t.Setenv("DEVRAG_MODEL_ON_MISMATCH", "refuse")
+	t.Setenv("DEVRAG_WATCH_ENABLED", "false")
+	t.Setenv("DEVRAG_SERVER_ADDRESS", "0.0.0.0:9000")
+
+	cfg := DefaultConfig()
+	if err := cfg.ApplyEnv(); err != nil {
+		t.Fatalf("ApplyEnv failed: %v", err)
+	}
+	if cfg.DocumentsDir != "/srv/docs" || cfg.ChunkSize != 800 || !cfg.ReadOnly {
+		t.Errorf("Expected top-level overrides, got %+v", cfg)
+	}
+	if cfg.Compute.Device != "cpu" || cfg.Model.OnMismatch != OnMismatchRefuse || cfg.Watch.Enabled || cfg.Server.Address != "0.0.0.0:9000" {
+		t.Errorf("Expected nested overrides, got %+v", cfg)
+	}
+	if cfg.SearchTopK != 5 {
+		t.Errorf("Expected unset fields to keep their value, got search_top_k %d", cfg.SearchTopK)
+	}
+
+	t.Setenv("DEVRAG_CHUNK_SIZE", "large")
+	if err := DefaultConfig().ApplyEnv(); err == nil {
+		t.Error("Expected error for an invalid DEVRAG_CHUNK_SIZE")
+	}
+}
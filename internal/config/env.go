@@ -0,0 +1,57 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// EnvPrefix starts the environment variables that override config fields
+// The rest of the name is the field's JSON path in upper case joined by
+// underscores, e.g. DEVRAG_DOCUMENTS_DIR or DEVRAG_MODEL_ON_MISMATCH
+const EnvPrefix = "DEVRAG_"
+
+// ApplyEnv overrides config fields with the DEVRAG_* environment variables that are set
+// String fields take the value as it is; other fields parse it as JSON,
+// e.g. DEVRAG_WATCH_ENABLED=false or DEVRAG_CHUNK_SIZE=800
+func (c *Config) ApplyEnv() error {
+	return applyEnv(reflect.ValueOf(c).Elem(), EnvPrefix)
+}
+
+// applyEnv sets the fields of a struct from environment variables named after
+// their JSON tags, descending into nested structs
+func applyEnv(v reflect.Value, prefix string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if tag == "" || tag == "-" {
+			continue
+		}
+		name := prefix + strings.ToUpper(tag)
+
+		if field.Type.Kind() == reflect.Struct {
+			if err := applyEnv(v.Field(i), name+"_"); err != nil {
+				return err
+			}
+			continue
+		}
+
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if field.Type.Kind() == reflect.String {
+			v.Field(i).SetString(value)
+			continue
+		}
+		target := reflect.New(field.Type)
+		if err := json.Unmarshal([]byte(value), target.Interface()); err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+		v.Field(i).Set(target.Elem())
+	}
+	return nil
+}
@@ -0,0 +1,316 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/tomohiro-owada/devrag/internal/embedder"
+	"github.com/tomohiro-owada/devrag/internal/sources"
+)
+
+// Compute devices accepted by compute.device
+var devices = []string{"auto", "cpu", "gpu"}
+
+// charsPerToken estimates how many characters of a chunk make up one model token
+// chunk_size counts characters, while models limit their input in tokens. The
+// multilingual tokenizers average about 4 characters per token for English and
+// 1-2 for Japanese; 2 keeps the estimate on the safe side for mixed documents.
+const charsPerToken = 2
+
+// FieldError is a problem with a single config field
+type FieldError struct {
+	Field   string // JSON path of the field, e.g. "compute.device"
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// ValidationError lists every problem found in a configuration
+type ValidationError []FieldError
+
+func (e ValidationError) Error() string {
+	problems := make([]string, len(e))
+	for i, p := range e {
+		problems[i] = p.Error()
+	}
+	return strings.Join(problems, "; ")
+}
+
+// add records a problem with a field
+func (e *ValidationError) add(field, format string, args ...interface{}) {
+	*e = append(*e, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// err returns the problems as an error, or nil if there are none
+func (e ValidationError) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Validate checks config values and paths and reports all problems at once
+// documents_dir may be missing unless read_only is set, since it is created at startup
+func (c *Config) Validate() error {
+	var problems ValidationError
+
+	if c.ChunkSize <= 0 {
+		problems.add("chunk_size", "must be positive")
+	}
+	if c.SearchTopK <= 0 {
+		problems.add("search_top_k", "must be positive")
+	}
+	if !contains(devices, c.Compute.Device) {
+		problems.add("compute.device", "must be one of %s, got %q", strings.Join(devices, ", "), c.Compute.Device)
+	}
+	if c.Compute.BatchSize <= 0 {
+		problems.add("compute.batch_size", "must be positive")
+	}
+
+	if c.Model.Dimensions <= 0 {
+		problems.add("model.dimensions", "must be positive")
+	}
+	if spec, err := embedder.LookupModel(c.Model.Name); err != nil {
+		problems.add("model.name", "%v", err)
+	} else {
+		if c.Model.Dimensions > 0 && c.Model.Dimensions != spec.Dimensions {
+			problems.add("model.dimensions", "is %d, but %s produces %d dimensions", c.Model.Dimensions, spec.Name, spec.Dimensions)
+		}
+	}
+	if c.Model.OnMismatch != OnMismatchRebuild && c.Model.OnMismatch != OnMismatchRefuse {
+		problems.add("model.on_mismatch", "must be %q or %q, got %q", OnMismatchRebuild, OnMismatchRefuse, c.Model.OnMismatch)
+	}
+
+	if c.Indexing.Workers < 0 {
+		problems.add("indexing.workers", "must not be negative")
+	}
+	if c.Indexing.WriteBatchSize <= 0 {
+		problems.add("indexing.write_batch_size", "must be positive")
+	}
+
+	if c.Watch.DebounceMs <= 0 {
+		problems.add("watch.debounce_ms", "must be positive")
+	}
+
+	switch c.Server.Transport {
+	case TransportStdio:
+	case TransportHTTP, TransportSSE:
+		if c.Server.Address == "" {
+			problems.add("server.address", "is required for the %s transport", c.Server.Transport)
+		}
+	default:
+		problems.add("server.transport", "must be %q, %q or %q, got %q", TransportStdio, TransportHTTP, TransportSSE, c.Server.Transport)
+	}
+
+	c.validatePaths(&problems)
+	return problems.err()
+}
+
+// maxChunkSize returns the longest chunk_size, in characters, that the model
+// is estimated to embed without truncation, or 0 if the model is unknown
+func (c *Config) maxChunkSize() int {
+	spec, err := embedder.LookupModel(c.Model.Name)
+	if err != nil {
+		return 0
+	}
+	return spec.MaxTokens * charsPerToken
+}
+
+// Clamp bounds settings that are valid but exceed what the model supports and
+// reports every adjustment. The configuration should have passed Validate.
+func (c *Config) Clamp() []FieldError {
+	var adjustments ValidationError
+
+	// Chunks are measured in characters, so the token limit can only be estimated
+	if limit := c.maxChunkSize(); limit > 0 && c.ChunkSize > limit {
+		adjustments.add("chunk_size", "%d characters are about %d tokens, but %s embeds at most %d; clamped to %d characters",
+			c.ChunkSize, c.ChunkSize/charsPerToken, c.Model.Name, limit/charsPerToken, limit)
+		c.ChunkSize = limit
+	}
+
+	return adjustments
+}
+
+// validatePaths checks that the documents can be read and db_path can be opened
+// for reading, or for writing unless read_only is set
+// The directory of a new database is only checked to exist; see CheckWritable
+func (c *Config) validatePaths(problems *ValidationError) {
+	if len(c.Sources) > 0 {
+		c.validateSources(problems)
+	} else if c.DocumentsDir == "" {
+		problems.add("documents_dir", "is required")
+	} else {
+		// documents_dir is created at startup unless read_only is set
+		validateDir(problems, "documents_dir", c.DocumentsDir, !c.ReadOnly)
+	}
+
+	// models_dir is created when the model is downloaded
+	if c.ModelsDir == "" {
+		problems.add("models_dir", "is required")
+	} else {
+		validateDir(problems, "models_dir", c.ModelsDir, true)
+	}
+
+	if c.DBPath == "" {
+		problems.add("db_path", "is required")
+		return
+	}
+
+	info, err := os.Stat(c.DBPath)
+	switch {
+	case err == nil && info.IsDir():
+		problems.add("db_path", "%s is a directory", c.DBPath)
+		return
+	case err == nil:
+		flag := os.O_RDWR
+		if c.ReadOnly {
+			flag = os.O_RDONLY
+		}
+		f, err := os.OpenFile(c.DBPath, flag, 0)
+		if err != nil {
+			problems.add("db_path", "%s cannot be opened: %v", c.DBPath, err)
+			return
+		}
+		f.Close()
+	case !errors.Is(err, os.ErrNotExist):
+		problems.add("db_path", "%v", err)
+		return
+	case c.ReadOnly:
+		problems.add("db_path", "%s does not exist; build the index once without read_only", c.DBPath)
+		return
+	}
+
+	// SQLite creates the database and its journal next to db_path
+	if !c.ReadOnly {
+		dir := filepath.Dir(c.DBPath)
+		if info, err := os.Stat(dir); err != nil {
+			problems.add("db_path", "%v", err)
+		} else if !info.IsDir() {
+			problems.add("db_path", "%s is not a directory", dir)
+		}
+	}
+}
+
+// CheckWritable checks that SQLite can create files next to db_path by creating
+// and removing a temporary file. Validate does not modify the file system, so
+// commands that write to the index call this before opening it.
+func (c *Config) CheckWritable() error {
+	dir := filepath.Dir(c.DBPath)
+	f, err := os.CreateTemp(dir, ".devrag-write-test-*")
+	if err != nil {
+		return FieldError{Field: "db_path", Message: fmt.Sprintf("directory %s is not writable: %v", dir, err)}
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return nil
+}
+
+// validateSources checks the roots and patterns of the sources
+func (c *Config) validateSources(problems *ValidationError) {
+	for i, source := range c.Sources {
+		field := fmt.Sprintf("sources[%d]", i)
+		if source.Path == "" {
+			problems.add(field+".path", "is required")
+		} else {
+			validateDir(problems, field+".path", source.Path, false)
+		}
+		for j, pattern := range source.Include {
+			if !sources.ValidPattern(pattern) {
+				problems.add(fmt.Sprintf("%s.include[%d]", field, j), "%q is not a valid glob pattern", pattern)
+			}
+		}
+		for j, pattern := range source.Exclude {
+			if !sources.ValidPattern(pattern) {
+				problems.add(fmt.Sprintf("%s.exclude[%d]", field, j), "%q is not a valid glob pattern", pattern)
+			}
+		}
+	}
+}
+
+// validateDir checks that dir is a readable directory, which may be missing if
+// it is created at startup
+func validateDir(problems *ValidationError, field, dir string, created bool) {
+	info, err := os.Stat(dir)
+	switch {
+	case err == nil && !info.IsDir():
+		problems.add(field, "%s is not a directory", dir)
+	case err == nil:
+		f, err := os.Open(dir)
+		if err != nil {
+			problems.add(field, "%s is not readable: %v", dir, err)
+			return
+		}
+		f.Close()
+	case !errors.Is(err, os.ErrNotExist) || !created:
+		problems.add(field, "%v", err)
+	}
+}
+
+// checkKeys reports keys of a config file that do not name a config field,
+// with their JSON paths
+func checkKeys(data []byte) error {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	var problems ValidationError
+	unknownKeys(doc, reflect.TypeOf(Config{}), "", &problems)
+	return problems.err()
+}
+
+// unknownKeys compares the keys of a JSON object with the JSON tags of a struct
+func unknownKeys(doc map[string]interface{}, t reflect.Type, prefix string, problems *ValidationError) {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		tag, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if tag != "" && tag != "-" {
+			fields[tag] = t.Field(i).Type
+		}
+	}
+
+	keys := make([]string, 0, len(doc))
+	for key := range doc {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fieldType, ok := fields[key]
+		if !ok {
+			problems.add(prefix+key, "unknown field")
+			continue
+		}
+		switch value := doc[key].(type) {
+		case map[string]interface{}:
+			if fieldType.Kind() == reflect.Struct {
+				unknownKeys(value, fieldType, prefix+key+".", problems)
+			}
+		case []interface{}:
+			if fieldType.Kind() == reflect.Slice && fieldType.Elem().Kind() == reflect.Struct {
+				for i, elem := range value {
+					if nested, ok := elem.(map[string]interface{}); ok {
+						unknownKeys(nested, fieldType.Elem(), fmt.Sprintf("%s%s[%d].", prefix, key, i), problems)
+					}
+				}
+			}
+		}
+	}
+}
+
+// contains reports whether values includes value
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
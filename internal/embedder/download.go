@@ -8,16 +8,8 @@ import (
 	"path/filepath"
 )
 
-const (
-	modelURL      = "https://huggingface.co/intfloat/multilingual-e5-small/resolve/main/onnx/model.onnx"
-	tokenizerURL  = "https://huggingface.co/intfloat/multilingual-e5-small/resolve/main/tokenizer.json"
-	configURL     = "https://huggingface.co/intfloat/multilingual-e5-small/resolve/main/config.json"
-	specialTokensURL = "https://huggingface.co/intfloat/multilingual-e5-small/resolve/main/special_tokens_map.json"
-	tokenizerConfigURL = "https://huggingface.co/intfloat/multilingual-e5-small/resolve/main/tokenizer_config.json"
-)
-
-// DownloadModelFiles downloads model files from Hugging Face if they don't exist
-func DownloadModelFiles(modelDir string) error {
+// DownloadModelFiles downloads the files of a model from Hugging Face if they don't exist
+func DownloadModelFiles(modelDir string, spec ModelSpec) error {
 	fmt.Fprintf(os.Stderr, "[INFO] Checking model files in %s...\n", modelDir)
 
 	// Create models directory if it doesn't exist
@@ -25,12 +17,9 @@ func DownloadModelFiles(modelDir string) error {
 		return fmt.Errorf("failed to create models directory: %w", err)
 	}
 
-	files := map[string]string{
-		"model.onnx":               modelURL,
-		"tokenizer.json":           tokenizerURL,
-		"config.json":              configURL,
-		"special_tokens_map.json":  specialTokensURL,
-		"tokenizer_config.json":    tokenizerConfigURL,
+	files := make(map[string]string, len(spec.Files))
+	for filename, repoPath := range spec.Files {
+		files[filename] = spec.FileURL(repoPath)
 	}
 
 	needsDownload := false
@@ -47,8 +36,8 @@ func DownloadModelFiles(modelDir string) error {
 		return nil
 	}
 
-	fmt.Fprintf(os.Stderr, "[INFO] Downloading model files from Hugging Face...\n")
-	fmt.Fprintf(os.Stderr, "[INFO] This is a one-time download (~450MB), please wait...\n")
+	fmt.Fprintf(os.Stderr, "[INFO] Downloading %s from Hugging Face (%s)...\n", spec.Name, spec.Repo)
+	fmt.Fprintf(os.Stderr, "[INFO] This is a one-time download, please wait...\n")
 
 	for filename, url := range files {
 		path := filepath.Join(modelDir, filename)
@@ -10,22 +10,37 @@ type Embedder interface {
 	// EmbedBatch embeds multiple text strings into vectors
 	EmbedBatch(texts []string) ([][]float32, error)
 
+	// EmbedQuery embeds a search query, applying the model's query prefix if any
+	EmbedQuery(text string) ([]float32, error)
+
+	// EmbedDocuments embeds document chunks, applying the model's passage prefix if any
+	EmbedDocuments(texts []string) ([][]float32, error)
+
 	// Close releases resources used by the embedder
 	Close() error
 }
 
+// DefaultDimensions is the embedding dimension of the default model
+const DefaultDimensions = 384
+
 // MockEmbedder is a simple embedder for testing purposes
 // It generates deterministic embeddings based on text hash
-type MockEmbedder struct{}
+type MockEmbedder struct {
+	Dimensions int // DefaultDimensions if zero
+}
 
-// Embed generates a simple mock embedding (384 dimensions)
+// Embed generates a simple mock embedding
 func (m *MockEmbedder) Embed(text string) ([]float32, error) {
 	// Generate a deterministic embedding based on text hash
 	hash := sha256.Sum256([]byte(text))
 
-	// Create 384-dimensional vector
-	embedding := make([]float32, 384)
-	for i := 0; i < 384; i++ {
+	dimensions := m.Dimensions
+	if dimensions <= 0 {
+		dimensions = DefaultDimensions
+	}
+
+	embedding := make([]float32, dimensions)
+	for i := 0; i < dimensions; i++ {
 		// Use hash bytes to generate pseudo-random values
 		embedding[i] = float32(hash[i%32]) / 255.0
 	}
@@ -58,6 +73,16 @@ func (m *MockEmbedder) EmbedBatch(texts []string) ([][]float32, error) {
 	return results, nil
 }
 
+// EmbedQuery embeds a query (the mock embedder uses no prefixes)
+func (m *MockEmbedder) EmbedQuery(text string) ([]float32, error) {
+	return m.Embed(text)
+}
+
+// EmbedDocuments embeds document chunks (the mock embedder uses no prefixes)
+func (m *MockEmbedder) EmbedDocuments(texts []string) ([][]float32, error) {
+	return m.EmbedBatch(texts)
+}
+
 // Close does nothing for mock embedder
 func (m *MockEmbedder) Close() error {
 	return nil
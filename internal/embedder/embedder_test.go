@@ -149,6 +149,19 @@ func TestMockEmbedder_EmbedEmptyString(t *testing.T) {
 	}
 }
 
+func TestMockEmbedder_CustomDimensions(t *testing.T) {
+	embedder := &MockEmbedder{Dimensions: 768}
+
+	embedding, err := embedder.Embed("Hello world")
+	if err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+
+	if len(embedding) != 768 {
+		t.Errorf("Expected 768 dimensions, got %d", len(embedding))
+	}
+}
+
 func TestDetectDevice(t *testing.T) {
 	tests := []struct {
 		name          string
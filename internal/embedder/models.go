@@ -0,0 +1,149 @@
+package embedder
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Pooling selects how token embeddings are combined into one sentence embedding
+type Pooling string
+
+const (
+	// PoolingMean averages the hidden states of all real tokens
+	PoolingMean Pooling = "mean"
+	// PoolingCLS uses the hidden state of the first token
+	PoolingCLS Pooling = "cls"
+)
+
+// DefaultModel is the model used when the configuration does not name one
+const DefaultModel = "multilingual-e5-small"
+
+// ModelSpec describes an embedding model and how to run it
+type ModelSpec struct {
+	Name string
+	Repo string // Hugging Face repository the files are downloaded from
+
+	// Files maps local file names to their paths in the repository
+	// The model itself is stored locally as model.onnx
+	Files map[string]string
+
+	InputNames []string // ONNX inputs, a subset of input_ids, attention_mask and token_type_ids
+	OutputName string   // ONNX output holding the token hidden states
+	Pooling    Pooling
+	Dimensions int
+	MaxTokens  int // input length limit; longer chunks are truncated when embedded
+
+	// Prefixes the model was trained with for asymmetric retrieval (empty if none)
+	QueryPrefix   string
+	PassagePrefix string
+}
+
+// tokenizerFiles are the tokenizer files shared by the XLM-RoBERTa based models
+var tokenizerFiles = map[string]string{
+	"tokenizer.json":          "tokenizer.json",
+	"config.json":             "config.json",
+	"special_tokens_map.json": "special_tokens_map.json",
+	"tokenizer_config.json":   "tokenizer_config.json",
+}
+
+// models is the registry of supported models keyed by name
+var models = map[string]ModelSpec{
+	"multilingual-e5-small": {
+		Name:          "multilingual-e5-small",
+		Repo:          "intfloat/multilingual-e5-small",
+		Files:         withTokenizerFiles(map[string]string{"model.onnx": "onnx/model.onnx"}),
+		InputNames:    []string{"input_ids", "attention_mask", "token_type_ids"},
+		OutputName:    "last_hidden_state",
+		Pooling:       PoolingMean,
+		Dimensions:    384,
+		MaxTokens:     512,
+		QueryPrefix:   E5QueryPrefix,
+		PassagePrefix: E5PassagePrefix,
+	},
+	"multilingual-e5-base": {
+		Name:          "multilingual-e5-base",
+		Repo:          "intfloat/multilingual-e5-base",
+		Files:         withTokenizerFiles(map[string]string{"model.onnx": "onnx/model.onnx"}),
+		InputNames:    []string{"input_ids", "attention_mask", "token_type_ids"},
+		OutputName:    "last_hidden_state",
+		Pooling:       PoolingMean,
+		Dimensions:    768,
+		MaxTokens:     512,
+		QueryPrefix:   E5QueryPrefix,
+		PassagePrefix: E5PassagePrefix,
+	},
+	"bge-m3": {
+		Name: "bge-m3",
+		Repo: "BAAI/bge-m3",
+		// The weights are stored as external data next to the graph
+		Files: withTokenizerFiles(map[string]string{
+			"model.onnx":      "onnx/model.onnx",
+			"model.onnx_data": "onnx/model.onnx_data",
+		}),
+		InputNames: []string{"input_ids", "attention_mask"},
+		OutputName: "last_hidden_state",
+		Pooling:    PoolingCLS,
+		Dimensions: 1024,
+		MaxTokens:  512,
+	},
+}
+
+// withTokenizerFiles adds the tokenizer files to a model's file list
+func withTokenizerFiles(files map[string]string) map[string]string {
+	for local, remote := range tokenizerFiles {
+		files[local] = remote
+	}
+	return files
+}
+
+// MockModel is the model name recorded for vectors produced by MockEmbedder
+const MockModel = "mock"
+
+// Settings identifies how an embedder turns text into vectors
+// Vectors produced under different settings are not comparable
+type Settings struct {
+	Model         string
+	Dimensions    int
+	Pooling       Pooling
+	QueryPrefix   string
+	PassagePrefix string
+}
+
+// Settings returns the embedding settings of the model with or without its prefixes
+func (s ModelSpec) Settings(usePrefixes bool) Settings {
+	settings := Settings{
+		Model:      s.Name,
+		Dimensions: s.Dimensions,
+		Pooling:    s.Pooling,
+	}
+	if usePrefixes {
+		settings.QueryPrefix = s.QueryPrefix
+		settings.PassagePrefix = s.PassagePrefix
+	}
+	return settings
+}
+
+// LookupModel returns the registered model with the given name
+func LookupModel(name string) (ModelSpec, error) {
+	spec, ok := models[name]
+	if !ok {
+		return ModelSpec{}, fmt.Errorf("unknown model: %s (available: %s)", name, strings.Join(ModelNames(), ", "))
+	}
+	return spec, nil
+}
+
+// ModelNames returns the names of all registered models in sorted order
+func ModelNames() []string {
+	names := make([]string, 0, len(models))
+	for name := range models {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FileURL returns the download URL of a file in the model's repository
+func (s ModelSpec) FileURL(repoPath string) string {
+	return fmt.Sprintf("https://huggingface.co/%s/resolve/main/%s", s.Repo, repoPath)
+}
@@ -0,0 +1,74 @@
+package embedder
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLookupModel(t *testing.T) {
+	tests := []struct {
+		name       string
+		dimensions int
+		pooling    Pooling
+	}{
+		{"multilingual-e5-small", 384, PoolingMean},
+		{"multilingual-e5-base", 768, PoolingMean},
+		{"bge-m3", 1024, PoolingCLS},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := LookupModel(tt.name)
+			if err != nil {
+				t.Fatalf("LookupModel failed: %v", err)
+			}
+			if spec.Dimensions != tt.dimensions {
+				t.Errorf("Expected %d dimensions, got %d", tt.dimensions, spec.Dimensions)
+			}
+			if spec.Pooling != tt.pooling {
+				t.Errorf("Expected %s pooling, got %s", tt.pooling, spec.Pooling)
+			}
+			if _, ok := spec.Files["model.onnx"]; !ok {
+				t.Error("Expected model.onnx in file list")
+			}
+			if _, ok := spec.Files["tokenizer.json"]; !ok {
+				t.Error("Expected tokenizer.json in file list")
+			}
+		})
+	}
+}
+
+func TestLookupModel_Unknown(t *testing.T) {
+	_, err := LookupModel("no-such-model")
+	if err == nil {
+		t.Fatal("Expected error for unknown model")
+	}
+	if !strings.Contains(err.Error(), DefaultModel) {
+		t.Errorf("Expected error to list available models, got %v", err)
+	}
+}
+
+func TestModelNames_Sorted(t *testing.T) {
+	names := ModelNames()
+	if len(names) != 3 {
+		t.Fatalf("Expected 3 models, got %v", names)
+	}
+	for i := 1; i < len(names); i++ {
+		if names[i-1] > names[i] {
+			t.Errorf("Names not sorted: %v", names)
+		}
+	}
+}
+
+func TestModelSpec_FileURL(t *testing.T) {
+	spec, err := LookupModel(DefaultModel)
+	if err != nil {
+		t.Fatalf("LookupModel failed: %v", err)
+	}
+
+	got := spec.FileURL("onnx/model.onnx")
+	want := "https://huggingface.co/intfloat/multilingual-e5-small/resolve/main/onnx/model.onnx"
+	if got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+}
@@ -4,21 +4,43 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
+
+	"sort"
 
 	ort "github.com/yalue/onnxruntime_go"
 )
 
+// DefaultBatchSize is the number of texts run through the model at once
+const DefaultBatchSize = 16
+
+// Prefixes expected by multilingual-e5 models for asymmetric retrieval
+const (
+	E5QueryPrefix   = "query: "
+	E5PassagePrefix = "passage: "
+)
+
 type ONNXEmbedder struct {
-	session    *ort.DynamicAdvancedSession
-	tokenizer  *Tokenizer
-	device     Device
-	modelDir   string
-	outputDim  int
-	maxLength  int
+	session   *ort.DynamicAdvancedSession
+	tokenizer *Tokenizer
+	device    Device
+	modelDir  string
+	spec      ModelSpec
+	outputDim int
+	maxLength int
+	batchSize int
+
+	queryPrefix   string
+	passagePrefix string
 }
 
-// NewONNXEmbedder creates a new ONNX embedder
-func NewONNXEmbedder(modelPath string, device Device) (*ONNXEmbedder, error) {
+// NewONNXEmbedder creates a new ONNX embedder for the model described by spec
+// batchSize is the maximum number of texts per inference call (DefaultBatchSize if <= 0)
+func NewONNXEmbedder(modelPath string, spec ModelSpec, device Device, batchSize int) (*ONNXEmbedder, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
 	fmt.Fprintf(os.Stderr, "[INFO] Initializing ONNX Runtime (%s)...\n", device)
 
 	// Initialize ONNX Runtime
@@ -44,7 +66,8 @@ func NewONNXEmbedder(modelPath string, device Device) (*ONNXEmbedder, error) {
 	}
 
 	// Configure session options for better performance
-	if err := options.SetIntraOpNumThreads(4); err != nil {
+	// The indexing pipeline runs one batch at a time, so a batch may use every core
+	if err := options.SetIntraOpNumThreads(runtime.NumCPU()); err != nil {
 		fmt.Fprintf(os.Stderr, "[WARN] Failed to set intra-op threads: %v\n", err)
 	}
 	if err := options.SetInterOpNumThreads(4); err != nil {
@@ -52,24 +75,25 @@ func NewONNXEmbedder(modelPath string, device Device) (*ONNXEmbedder, error) {
 	}
 
 	// Load model
-	// For multilingual-e5-small, the inputs are: input_ids, attention_mask, token_type_ids
-	// Output is: last_hidden_state
+	// Input and output names depend on the model, e.g. multilingual-e5-small takes
+	// input_ids, attention_mask and token_type_ids and outputs last_hidden_state
 	session, err := ort.NewDynamicAdvancedSession(modelPath,
-		[]string{"input_ids", "attention_mask", "token_type_ids"},
-		[]string{"last_hidden_state"},
+		spec.InputNames,
+		[]string{spec.OutputName},
 		options)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load model: %w", err)
 	}
 
-	fmt.Fprintf(os.Stderr, "[INFO] ONNX model loaded successfully\n")
+	fmt.Fprintf(os.Stderr, "[INFO] ONNX model loaded successfully (%s, %d dimensions, %s pooling)\n",
+		spec.Name, spec.Dimensions, spec.Pooling)
 
 	// Get model directory
 	modelDir := filepath.Dir(modelPath)
 
 	// Load tokenizer
 	fmt.Fprintf(os.Stderr, "[INFO] Loading tokenizer...\n")
-	tokenizer, err := NewTokenizerFromModelDir(modelDir)
+	tokenizer, err := NewTokenizerFromModelDir(modelDir, spec.MaxTokens)
 	if err != nil {
 		session.Destroy()
 		return nil, fmt.Errorf("failed to load tokenizer: %w", err)
@@ -78,69 +102,139 @@ func NewONNXEmbedder(modelPath string, device Device) (*ONNXEmbedder, error) {
 	fmt.Fprintf(os.Stderr, "[INFO] Tokenizer loaded successfully (vocab size: %d)\n", tokenizer.GetVocabSize())
 
 	return &ONNXEmbedder{
-		session:    session,
-		tokenizer:  tokenizer,
-		device:     device,
-		modelDir:   modelDir,
-		outputDim:  384, // multilingual-e5-small output dimension
-		maxLength:  512,
+		session:   session,
+		tokenizer: tokenizer,
+		device:    device,
+		modelDir:  modelDir,
+		spec:      spec,
+		outputDim: spec.Dimensions,
+		maxLength: spec.MaxTokens,
+		batchSize: batchSize,
 	}, nil
 }
 
-// Embed embeds a single text
-func (e *ONNXEmbedder) Embed(text string) ([]float32, error) {
-	// Add query prefix for e5 models (improves search quality)
-	// For documents, no prefix is needed
-	// text = "query: " + text
+// SetPrefixes sets the prefixes prepended by EmbedQuery and EmbedDocuments
+func (e *ONNXEmbedder) SetPrefixes(queryPrefix, passagePrefix string) {
+	e.queryPrefix = queryPrefix
+	e.passagePrefix = passagePrefix
+}
+
+// EmbedQuery embeds a search query with the query prefix
+func (e *ONNXEmbedder) EmbedQuery(text string) ([]float32, error) {
+	return e.Embed(e.queryPrefix + text)
+}
 
+// EmbedDocuments embeds document chunks with the passage prefix
+func (e *ONNXEmbedder) EmbedDocuments(texts []string) ([][]float32, error) {
+	if e.passagePrefix == "" {
+		return e.EmbedBatch(texts)
+	}
+
+	prefixed := make([]string, len(texts))
+	for i, text := range texts {
+		prefixed[i] = e.passagePrefix + text
+	}
+	return e.EmbedBatch(prefixed)
+}
+
+// Embed embeds a single text as-is, without any prefix
+func (e *ONNXEmbedder) Embed(text string) ([]float32, error) {
 	// Tokenize the text
-	inputIDs32, attentionMask32, err := e.tokenizer.TokenizeWithAttentionMask(text)
+	inputIDs, attentionMask, err := e.tokenizer.TokenizeWithAttentionMask(text)
 	if err != nil {
 		return nil, fmt.Errorf("tokenization failed: %w", err)
 	}
 
-	// Prepare input tensors
-	batchSize := 1
-	seqLength := len(inputIDs32)
+	embeddings, err := e.runBatch([][]int32{realTokens(inputIDs, attentionMask)})
+	if err != nil {
+		return nil, err
+	}
+
+	return embeddings[0], nil
+}
 
-	// Convert int32 to int64 for ONNX model
-	inputIDs := make([]int64, seqLength)
-	attentionMask := make([]int64, seqLength)
-	tokenTypeIDs := make([]int64, seqLength) // All zeros for single sequence
-	for i := 0; i < seqLength; i++ {
-		inputIDs[i] = int64(inputIDs32[i])
-		attentionMask[i] = int64(attentionMask32[i])
-		tokenTypeIDs[i] = 0 // Always 0 for single sequence
+// EmbedBatch embeds multiple texts
+// Texts are sorted by token length and grouped into batches of batchSize,
+// so each batch is padded only up to its own longest sequence
+func (e *ONNXEmbedder) EmbedBatch(texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return [][]float32{}, nil
 	}
 
-	// Create input_ids tensor [batch_size, seq_length]
-	inputIDsShape := []int64{int64(batchSize), int64(seqLength)}
-	inputIDsTensor, err := ort.NewTensor(inputIDsShape, inputIDs)
+	inputIDs, attentionMasks, err := e.tokenizer.TokenizeBatchWithAttentionMask(texts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create input_ids tensor: %w", err)
+		return nil, fmt.Errorf("tokenization failed: %w", err)
 	}
-	defer inputIDsTensor.Destroy()
 
-	// Create attention_mask tensor [batch_size, seq_length]
-	attentionMaskShape := []int64{int64(batchSize), int64(seqLength)}
-	attentionMaskTensor, err := ort.NewTensor(attentionMaskShape, attentionMask)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create attention_mask tensor: %w", err)
+	sequences := make([][]int32, len(texts))
+	lengths := make([]int, len(texts))
+	truncated := 0
+	for i := range texts {
+		sequences[i] = realTokens(inputIDs[i], attentionMasks[i])
+		lengths[i] = len(sequences[i])
+		if lengths[i] >= e.maxLength {
+			truncated++
+		}
+	}
+	// The tokenizer cuts texts at the model's input limit
+	if truncated > 0 {
+		fmt.Fprintf(os.Stderr, "[WARN] %d of %d texts reached the %d token limit of %s and may have been truncated; lower chunk_size to embed them completely\n",
+			truncated, len(texts), e.maxLength, e.spec.Name)
 	}
-	defer attentionMaskTensor.Destroy()
 
-	// Create token_type_ids tensor [batch_size, seq_length]
-	tokenTypeIDsShape := []int64{int64(batchSize), int64(seqLength)}
-	tokenTypeIDsTensor, err := ort.NewTensor(tokenTypeIDsShape, tokenTypeIDs)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create token_type_ids tensor: %w", err)
+	results := make([][]float32, len(texts))
+	for _, batch := range planBatches(lengths, e.batchSize) {
+		batchSequences := make([][]int32, len(batch))
+		for i, idx := range batch {
+			batchSequences[i] = sequences[idx]
+		}
+
+		embeddings, err := e.runBatch(batchSequences)
+		if err != nil {
+			return nil, fmt.Errorf("failed to embed batch starting at text %d: %w", batch[0], err)
+		}
+
+		for i, idx := range batch {
+			results[idx] = embeddings[i]
+		}
+	}
+
+	return results, nil
+}
+
+// runBatch runs inference on a batch of token sequences and returns one
+// normalized embedding per sequence
+func (e *ONNXEmbedder) runBatch(sequences [][]int32) ([][]float32, error) {
+	batchSize := len(sequences)
+	inputIDs, attentionMask, seqLength := padBatch(sequences, e.tokenizer.padTokenID)
+	tokenTypeIDs := make([]int64, len(inputIDs)) // All zeros for single sequences
+
+	shape := []int64{int64(batchSize), int64(seqLength)}
+
+	// Create the [batch_size, seq_length] input tensors the model expects
+	inputData := map[string][]int64{
+		"input_ids":      inputIDs,
+		"attention_mask": attentionMask,
+		"token_type_ids": tokenTypeIDs,
+	}
+	inputs := make([]ort.Value, len(e.spec.InputNames))
+	for i, name := range e.spec.InputNames {
+		data, ok := inputData[name]
+		if !ok {
+			return nil, fmt.Errorf("unsupported model input: %s", name)
+		}
+		tensor, err := ort.NewTensor(shape, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s tensor: %w", name, err)
+		}
+		defer tensor.Destroy()
+		inputs[i] = tensor
 	}
-	defer tokenTypeIDsTensor.Destroy()
 
 	// Run inference
 	// Output tensors will be allocated by the session
 	outputs := []ort.Value{nil}
-	err = e.session.Run([]ort.Value{inputIDsTensor, attentionMaskTensor, tokenTypeIDsTensor}, outputs)
+	err := e.session.Run(inputs, outputs)
 	if err != nil {
 		return nil, fmt.Errorf("inference failed: %w", err)
 	}
@@ -165,36 +259,91 @@ func (e *ONNXEmbedder) Embed(text string) ([]float32, error) {
 
 	// Get the data
 	outputFloat32 := outputTensor.GetData()
+	rowSize := seqLength * e.outputDim
+	if len(outputFloat32) != batchSize*rowSize {
+		return nil, fmt.Errorf("unexpected output size: got %d, want %d", len(outputFloat32), batchSize*rowSize)
+	}
 
-	// The output shape is [batch_size, seq_length, hidden_size]
-	// We need to perform mean pooling over the sequence dimension
-	// with attention mask to get a single vector per text
-	embedding := meanPooling(outputFloat32, attentionMask, seqLength, e.outputDim)
+	// Pool each sequence and L2-normalize
+	embeddings := make([][]float32, batchSize)
+	for b := 0; b < batchSize; b++ {
+		hidden := outputFloat32[b*rowSize : (b+1)*rowSize]
+		switch e.spec.Pooling {
+		case PoolingCLS:
+			embeddings[b] = normalize(clsPooling(hidden, e.outputDim))
+		default:
+			mask := attentionMask[b*seqLength : (b+1)*seqLength]
+			embeddings[b] = normalize(meanPooling(hidden, mask, seqLength, e.outputDim))
+		}
+	}
 
-	// Normalize the embedding (L2 normalization)
-	embedding = normalize(embedding)
+	return embeddings, nil
+}
 
-	return embedding, nil
+// realTokens returns the token IDs whose attention mask is set
+func realTokens(ids, mask []int32) []int32 {
+	tokens := make([]int32, 0, len(ids))
+	for i, id := range ids {
+		if i < len(mask) && mask[i] == 0 {
+			continue
+		}
+		tokens = append(tokens, id)
+	}
+	return tokens
 }
 
-// EmbedBatch embeds multiple texts
-func (e *ONNXEmbedder) EmbedBatch(texts []string) ([][]float32, error) {
-	if len(texts) == 0 {
-		return [][]float32{}, nil
+// planBatches groups sequence indices into batches of at most batchSize,
+// ordered by length so that sequences of similar length share a batch
+func planBatches(lengths []int, batchSize int) [][]int {
+	if batchSize <= 0 {
+		batchSize = 1
 	}
 
-	// For now, process one by one
-	// TODO: Implement true batch processing with padding
-	results := make([][]float32, len(texts))
-	for i, text := range texts {
-		embedding, err := e.Embed(text)
-		if err != nil {
-			return nil, fmt.Errorf("failed to embed text %d: %w", i, err)
+	order := make([]int, len(lengths))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return lengths[order[a]] < lengths[order[b]]
+	})
+
+	var batches [][]int
+	for start := 0; start < len(order); start += batchSize {
+		end := start + batchSize
+		if end > len(order) {
+			end = len(order)
 		}
-		results[i] = embedding
+		batches = append(batches, order[start:end])
 	}
 
-	return results, nil
+	return batches
+}
+
+// padBatch right-pads sequences to the longest one and flattens them into
+// row-major input_ids and attention_mask arrays
+func padBatch(sequences [][]int32, padTokenID int32) ([]int64, []int64, int) {
+	seqLength := 0
+	for _, seq := range sequences {
+		if len(seq) > seqLength {
+			seqLength = len(seq)
+		}
+	}
+
+	inputIDs := make([]int64, len(sequences)*seqLength)
+	attentionMask := make([]int64, len(sequences)*seqLength)
+	for b, seq := range sequences {
+		row := b * seqLength
+		for t := 0; t < seqLength; t++ {
+			if t < len(seq) {
+				inputIDs[row+t] = int64(seq[t])
+				attentionMask[row+t] = 1
+			} else {
+				inputIDs[row+t] = int64(padTokenID)
+			}
+		}
+	}
+
+	return inputIDs, attentionMask, seqLength
 }
 
 // meanPooling performs mean pooling over sequence dimension with attention mask
@@ -223,6 +372,13 @@ func meanPooling(hiddenStates []float32, attentionMask []int64, seqLength, hidde
 	return result
 }
 
+// clsPooling returns the hidden state of the first ([CLS] / <s>) token
+func clsPooling(hiddenStates []float32, hiddenSize int) []float32 {
+	result := make([]float32, hiddenSize)
+	copy(result, hiddenStates[:hiddenSize])
+	return result
+}
+
 // normalize performs L2 normalization
 func normalize(vec []float32) []float32 {
 	var norm float32
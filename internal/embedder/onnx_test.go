@@ -0,0 +1,164 @@
+package embedder
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+func TestPlanBatches(t *testing.T) {
+	lengths := []int{50, 3, 20, 4, 48, 21}
+
+	batches := planBatches(lengths, 2)
+
+	if len(batches) != 3 {
+		t.Fatalf("Expected 3 batches, got %d", len(batches))
+	}
+
+	// Sequences of similar length must share a batch
+	want := [][]int{{1, 3}, {2, 5}, {4, 0}}
+	for i := range want {
+		if len(batches[i]) != len(want[i]) {
+			t.Fatalf("Batch %d: expected %v, got %v", i, want[i], batches[i])
+		}
+		for j := range want[i] {
+			if batches[i][j] != want[i][j] {
+				t.Errorf("Batch %d: expected %v, got %v", i, want[i], batches[i])
+				break
+			}
+		}
+	}
+}
+
+func TestPlanBatches_CoversAllIndices(t *testing.T) {
+	lengths := []int{5, 1, 9, 3, 7}
+
+	seen := make(map[int]bool)
+	for _, batch := range planBatches(lengths, 4) {
+		if len(batch) > 4 {
+			t.Errorf("Batch exceeds batch size: %v", batch)
+		}
+		for _, idx := range batch {
+			if seen[idx] {
+				t.Errorf("Index %d planned twice", idx)
+			}
+			seen[idx] = true
+		}
+	}
+
+	if len(seen) != len(lengths) {
+		t.Errorf("Expected %d indices, got %d", len(lengths), len(seen))
+	}
+}
+
+func TestPadBatch(t *testing.T) {
+	sequences := [][]int32{{0, 10, 2}, {0, 11, 12, 13, 2}}
+
+	ids, mask, seqLength := padBatch(sequences, 1)
+
+	if seqLength != 5 {
+		t.Fatalf("Expected sequence length 5, got %d", seqLength)
+	}
+
+	wantIDs := []int64{0, 10, 2, 1, 1, 0, 11, 12, 13, 2}
+	wantMask := []int64{1, 1, 1, 0, 0, 1, 1, 1, 1, 1}
+	for i := range wantIDs {
+		if ids[i] != wantIDs[i] || mask[i] != wantMask[i] {
+			t.Fatalf("Unexpected padding: ids=%v mask=%v", ids, mask)
+		}
+	}
+}
+
+func TestRealTokens(t *testing.T) {
+	tokens := realTokens([]int32{0, 5, 2, 1, 1}, []int32{1, 1, 1, 0, 0})
+
+	if len(tokens) != 3 || tokens[0] != 0 || tokens[1] != 5 || tokens[2] != 2 {
+		t.Errorf("Unexpected tokens: %v", tokens)
+	}
+}
+
+func TestMeanPooling_IgnoresPadding(t *testing.T) {
+	// 3 tokens x 2 dims, last token is padding
+	hidden := []float32{1, 2, 3, 4, 100, 100}
+	mask := []int64{1, 1, 0}
+
+	pooled := meanPooling(hidden, mask, 3, 2)
+
+	if pooled[0] != 2 || pooled[1] != 3 {
+		t.Errorf("Expected [2 3], got %v", pooled)
+	}
+}
+
+func TestCLSPooling(t *testing.T) {
+	// 3 tokens x 2 dims, the first token is [CLS]
+	hidden := []float32{1, 2, 3, 4, 5, 6}
+
+	pooled := clsPooling(hidden, 2)
+
+	if len(pooled) != 2 || pooled[0] != 1 || pooled[1] != 2 {
+		t.Errorf("Expected [1 2], got %v", pooled)
+	}
+}
+
+// TestONNXEmbedder_BatchParity checks that padded batch inference matches
+// single-text inference. It requires the ONNX Runtime library and model files:
+// set DEVRAG_TEST_MODEL to the model.onnx path (tokenizer.json must be next to it)
+// and ONNXRUNTIME_LIB to the shared library if it is not on the default search path.
+func TestONNXEmbedder_BatchParity(t *testing.T) {
+	modelPath := os.Getenv("DEVRAG_TEST_MODEL")
+	if modelPath == "" {
+		modelPath = filepath.Join("..", "..", "models", models[DefaultModel].Name, "model.onnx")
+	}
+	if _, err := os.Stat(modelPath); err != nil {
+		t.Skipf("model not available at %s", modelPath)
+	}
+	if lib := os.Getenv("ONNXRUNTIME_LIB"); lib != "" {
+		ort.SetSharedLibraryPath(lib)
+	}
+
+	emb, err := NewONNXEmbedder(modelPath, models[DefaultModel], CPU, 3)
+	if err != nil {
+		t.Skipf("ONNX embedder unavailable: %v", err)
+	}
+	defer emb.Close()
+	defer ort.DestroyEnvironment()
+
+	texts := []string{
+		"短い文",
+		"This is a much longer sentence that will need padding when batched with the short ones.",
+		"Vector search",
+		"Markdown documents are split into chunks before they are embedded and stored in SQLite.",
+		"認証トークンの更新手順について説明します。",
+		"",
+		"ERR_AUTH_001",
+	}
+
+	batch, err := emb.EmbedBatch(texts)
+	if err != nil {
+		t.Fatalf("EmbedBatch failed: %v", err)
+	}
+	if len(batch) != len(texts) {
+		t.Fatalf("Expected %d embeddings, got %d", len(texts), len(batch))
+	}
+
+	for i, text := range texts {
+		single, err := emb.Embed(text)
+		if err != nil {
+			t.Fatalf("Embed(%q) failed: %v", text, err)
+		}
+		if len(single) != len(batch[i]) {
+			t.Fatalf("Text %d: dimension mismatch %d vs %d", i, len(single), len(batch[i]))
+		}
+
+		var maxDiff float64
+		for j := range single {
+			maxDiff = math.Max(maxDiff, math.Abs(float64(single[j]-batch[i][j])))
+		}
+		if maxDiff > 1e-4 {
+			t.Errorf("Text %d (%q): batch embedding differs from single embedding by %g", i, text, maxDiff)
+		}
+	}
+}
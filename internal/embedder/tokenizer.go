@@ -61,8 +61,9 @@ func NewTokenizer(tokenizerPath string, config TokenizerConfig) (*Tokenizer, err
 	}, nil
 }
 
-// NewTokenizerFromModelDir creates a tokenizer from the models directory
-func NewTokenizerFromModelDir(modelDir string) (*Tokenizer, error) {
+// NewTokenizerFromModelDir creates a tokenizer from the models directory that
+// truncates input to maxLength tokens
+func NewTokenizerFromModelDir(modelDir string, maxLength int) (*Tokenizer, error) {
 	tokenizerPath := filepath.Join(modelDir, "tokenizer.json")
 
 	// Check if tokenizer file exists
@@ -78,7 +79,7 @@ func NewTokenizerFromModelDir(modelDir string) (*Tokenizer, error) {
 	// - <unk>: 3
 	// - <mask>: 250001
 	config := TokenizerConfig{
-		MaxLength:     512,
+		MaxLength:     maxLength,
 		PadTokenID:    1,
 		ClsTokenID:    0,
 		SepTokenID:    2,
@@ -0,0 +1,244 @@
+package indexer
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// HeadingSeparator joins heading titles into a section breadcrumb
+const HeadingSeparator = " > "
+
+var (
+	atxHeadingPattern    = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	setextHeadingPattern = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+	fencePattern         = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})")
+	tableDelimiterRow    = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+)
+
+// block is a paragraph-level unit of markdown
+type block struct {
+	text   string
+	atomic bool // fenced code blocks and tables are never split
+}
+
+// section is the content under a heading
+type section struct {
+	headingPath string
+	heading     string // heading line(s) as written in the document
+	blocks      []block
+}
+
+// chunkMarkdown splits markdown into chunks that never cross a heading
+// Each chunk records the path of headings it belongs to
+func chunkMarkdown(content string, chunkSize int) []Chunk {
+	var chunks []Chunk
+	for _, sec := range splitSections(content) {
+		for _, text := range packSection(sec, chunkSize) {
+			chunks = append(chunks, Chunk{
+				Content:     text,
+				Position:    len(chunks),
+				HeadingPath: sec.headingPath,
+			})
+		}
+	}
+	return chunks
+}
+
+// splitSections parses markdown into sections at ATX and Setext headings
+// The heading of a section without content is carried into the next section,
+// so that a title directly followed by a subheading is not a chunk of its own
+func splitSections(content string) []section {
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+
+	var sections []section
+	var titles []string // heading titles by level
+	current := section{}
+	var paragraph []string
+	var fence string // opening fence marker while inside a code block
+	var codeLines []string
+
+	flushParagraph := func() {
+		if len(paragraph) == 0 {
+			return
+		}
+		current.blocks = append(current.blocks, block{
+			text:   strings.Join(paragraph, "\n"),
+			atomic: isTable(paragraph),
+		})
+		paragraph = nil
+	}
+
+	startSection := func(level int, title, headingText string) {
+		flushParagraph()
+
+		carried := ""
+		if len(current.blocks) > 0 {
+			sections = append(sections, current)
+		} else {
+			carried = current.heading
+		}
+
+		// Drop titles at this level and below, then fill skipped levels
+		if len(titles) >= level {
+			titles = titles[:level-1]
+		}
+		for len(titles) < level-1 {
+			titles = append(titles, "")
+		}
+		titles = append(titles, title)
+
+		current = section{
+			headingPath: joinHeadings(titles),
+			heading:     joinNonEmpty([]string{carried, headingText}, "\n\n"),
+		}
+	}
+
+	for _, line := range lines {
+		// Inside a fenced code block, collect lines until the closing fence
+		if fence != "" {
+			codeLines = append(codeLines, line)
+			if isClosingFence(line, fence) {
+				current.blocks = append(current.blocks, block{text: strings.Join(codeLines, "\n"), atomic: true})
+				fence, codeLines = "", nil
+			}
+			continue
+		}
+
+		if m := fencePattern.FindStringSubmatch(line); m != nil {
+			flushParagraph()
+			fence = m[1]
+			codeLines = []string{line}
+			continue
+		}
+
+		if strings.TrimSpace(line) == "" {
+			flushParagraph()
+			continue
+		}
+
+		if m := atxHeadingPattern.FindStringSubmatch(line); m != nil {
+			startSection(len(m[1]), strings.TrimSpace(m[2]), strings.TrimSpace(line))
+			continue
+		}
+
+		// A Setext underline turns the preceding paragraph into a heading
+		if m := setextHeadingPattern.FindStringSubmatch(line); m != nil && len(paragraph) > 0 && !isTable(paragraph) {
+			title := strings.TrimSpace(strings.Join(paragraph, " "))
+			headingText := strings.Join(append(paragraph, line), "\n")
+			paragraph = nil
+
+			level := 1
+			if m[1][0] == '-' {
+				level = 2
+			}
+			startSection(level, title, headingText)
+			continue
+		}
+
+		paragraph = append(paragraph, line)
+	}
+
+	// An unterminated code block runs to the end of the document
+	if fence != "" {
+		current.blocks = append(current.blocks, block{text: strings.Join(codeLines, "\n"), atomic: true})
+	}
+	flushParagraph()
+
+	if len(current.blocks) > 0 || current.heading != "" {
+		sections = append(sections, current)
+	}
+
+	return sections
+}
+
+// packSection combines the blocks of a section into chunks of approximately chunkSize characters
+// The heading is kept with the first chunk. Oversized paragraphs are split at sentence
+// boundaries, while oversized code blocks and tables become a chunk of their own
+func packSection(sec section, chunkSize int) []string {
+	var chunks []string
+	var current strings.Builder
+	hasContent := false // current holds more than the heading
+
+	current.WriteString(sec.heading)
+
+	flush := func() {
+		if text := strings.TrimSpace(current.String()); text != "" {
+			chunks = append(chunks, text)
+		}
+		current.Reset()
+		hasContent = false
+	}
+
+	appendText := func(text string) {
+		if current.Len() > 0 {
+			current.WriteString("\n\n")
+		}
+		current.WriteString(text)
+		hasContent = true
+	}
+
+	for _, b := range sec.blocks {
+		text := strings.TrimRight(b.text, " \t")
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+
+		currentLen := utf8.RuneCountInString(current.String())
+		blockLen := utf8.RuneCountInString(text)
+
+		// If adding this block exceeds chunk size, start new chunk
+		if hasContent && currentLen+blockLen+2 > chunkSize { // +2 for "\n\n"
+			flush()
+		}
+
+		if blockLen <= chunkSize || b.atomic {
+			appendText(text)
+			if blockLen > chunkSize {
+				flush()
+			}
+			continue
+		}
+
+		// Split a large paragraph, keeping a pending heading with its first part
+		for i, part := range splitLargeParagraph(strings.TrimSpace(text), chunkSize) {
+			if i > 0 || current.Len() == 0 {
+				chunks = append(chunks, part)
+				continue
+			}
+			appendText(part)
+			flush()
+		}
+	}
+
+	flush()
+	return chunks
+}
+
+// isTable reports whether paragraph lines form a GFM table
+func isTable(lines []string) bool {
+	return len(lines) >= 2 && strings.Contains(lines[0], "|") && tableDelimiterRow.MatchString(lines[1]) &&
+		strings.Contains(lines[1], "-")
+}
+
+// isClosingFence reports whether line closes a code block opened with fence
+func isClosingFence(line, fence string) bool {
+	trimmed := strings.TrimSpace(line)
+	return strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == ""
+}
+
+// joinHeadings builds a breadcrumb such as "Auth > JWT > Refresh tokens"
+func joinHeadings(titles []string) string {
+	return joinNonEmpty(titles, HeadingSeparator)
+}
+
+// joinNonEmpty joins the non-empty strings with sep
+func joinNonEmpty(parts []string, sep string) string {
+	nonEmpty := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p != "" {
+			nonEmpty = append(nonEmpty, p)
+		}
+	}
+	return strings.Join(nonEmpty, sep)
+}
@@ -0,0 +1,216 @@
+package indexer
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestChunkMarkdown_HeadingPaths(t *testing.T) {
+	content := `# Auth
+
+Overview of authentication.
+
+## JWT
+
+Tokens are signed with HS256.
+
+### Refresh tokens
+
+Refresh tokens expire after 30 days.
+
+## Sessions
+
+Sessions are stored in Redis.
+`
+
+	chunks := chunkMarkdown(content, 500)
+
+	expected := []string{"Auth", "Auth > JWT", "Auth > JWT > Refresh tokens", "Auth > Sessions"}
+	if len(chunks) != len(expected) {
+		t.Fatalf("Expected %d chunks, got %d: %+v", len(expected), len(chunks), chunks)
+	}
+
+	for i, path := range expected {
+		if chunks[i].HeadingPath != path {
+			t.Errorf("Chunk %d: expected heading path %q, got %q", i, path, chunks[i].HeadingPath)
+		}
+		if chunks[i].Position != i {
+			t.Errorf("Chunk %d: expected position %d, got %d", i, i, chunks[i].Position)
+		}
+	}
+
+	if !strings.HasPrefix(chunks[2].Content, "### Refresh tokens") {
+		t.Errorf("Expected chunk to start with its heading, got: %s", chunks[2].Content)
+	}
+	if strings.Contains(chunks[2].Content, "Sessions") {
+		t.Errorf("Chunk crossed a section boundary: %s", chunks[2].Content)
+	}
+}
+
+func TestChunkMarkdown_SetextHeadings(t *testing.T) {
+	content := "Guide\n=====\n\nIntro text.\n\nInstall\n-------\n\nRun the installer.\n"
+
+	chunks := chunkMarkdown(content, 500)
+
+	if len(chunks) != 2 {
+		t.Fatalf("Expected 2 chunks, got %d: %+v", len(chunks), chunks)
+	}
+	if chunks[0].HeadingPath != "Guide" {
+		t.Errorf("Expected heading path 'Guide', got %q", chunks[0].HeadingPath)
+	}
+	if chunks[1].HeadingPath != "Guide > Install" {
+		t.Errorf("Expected heading path 'Guide > Install', got %q", chunks[1].HeadingPath)
+	}
+}
+
+func TestChunkMarkdown_HeadingOnlySectionMerged(t *testing.T) {
+	content := "# API\n\n## Users\n\nList users with GET /users.\n"
+
+	chunks := chunkMarkdown(content, 500)
+
+	if len(chunks) != 1 {
+		t.Fatalf("Expected 1 chunk, got %d: %+v", len(chunks), chunks)
+	}
+	if chunks[0].HeadingPath != "API > Users" {
+		t.Errorf("Expected heading path 'API > Users', got %q", chunks[0].HeadingPath)
+	}
+	if !strings.HasPrefix(chunks[0].Content, "# API\n\n## Users") {
+		t.Errorf("Expected both headings in chunk, got: %s", chunks[0].Content)
+	}
+}
+
+func TestChunkMarkdown_SkippedLevels(t *testing.T) {
+	content := "# Top\n\ntext\n\n### Deep\n\nmore text\n"
+
+	chunks := chunkMarkdown(content, 500)
+
+	if len(chunks) != 2 {
+		t.Fatalf("Expected 2 chunks, got %d", len(chunks))
+	}
+	if chunks[1].HeadingPath != "Top > Deep" {
+		t.Errorf("Expected heading path 'Top > Deep', got %q", chunks[1].HeadingPath)
+	}
+}
+
+func TestChunkMarkdown_CodeBlockIntact(t *testing.T) {
+	code := "```go\nfunc main() {\n\n\t// # not a heading\n\n\tfmt.Println(\"hello\")\n}\n```"
+	content := "# Example\n\n" + strings.Repeat("Some text. ", 5) + "\n\n" + code + "\n\nAfter the code.\n"
+
+	// Chunk size smaller than the code block
+	chunks := chunkMarkdown(content, 40)
+
+	found := false
+	for _, chunk := range chunks {
+		if strings.Contains(chunk.Content, "```go") {
+			found = true
+			if !strings.Contains(chunk.Content, code) {
+				t.Errorf("Code block was split: %q", chunk.Content)
+			}
+		}
+		if chunk.HeadingPath != "Example" {
+			t.Errorf("Expected heading path 'Example', got %q", chunk.HeadingPath)
+		}
+	}
+	if !found {
+		t.Fatal("Code block not found in any chunk")
+	}
+}
+
+func TestChunkMarkdown_TableIntact(t *testing.T) {
+	table := "| Name | Value |\n|------|-------|\n| a | 1 |\n| b | 2 |\n| c | 3 |"
+	content := "## Settings\n\n" + table + "\n"
+
+	chunks := chunkMarkdown(content, 20)
+
+	found := false
+	for _, chunk := range chunks {
+		if strings.Contains(chunk.Content, "| Name |") {
+			found = true
+			if !strings.Contains(chunk.Content, table) {
+				t.Errorf("Table was split: %q", chunk.Content)
+			}
+		}
+	}
+	if !found {
+		t.Fatal("Table not found in any chunk")
+	}
+}
+
+func TestChunkMarkdown_LargeSectionSplit(t *testing.T) {
+	paragraph := strings.Repeat("This sentence belongs to the section. ", 10)
+	content := "# Long\n\n" + paragraph + "\n\n" + paragraph + "\n"
+
+	chunks := chunkMarkdown(content, 200)
+
+	if len(chunks) < 2 {
+		t.Fatalf("Expected multiple chunks, got %d", len(chunks))
+	}
+	for i, chunk := range chunks {
+		if chunk.HeadingPath != "Long" {
+			t.Errorf("Chunk %d: expected heading path 'Long', got %q", i, chunk.HeadingPath)
+		}
+		if chunk.Position != i {
+			t.Errorf("Chunk %d: expected position %d, got %d", i, i, chunk.Position)
+		}
+	}
+}
+
+func TestChunkMarkdown_NoHeadings(t *testing.T) {
+	chunks := chunkMarkdown("Just a paragraph.\n\nAnd another one.\n", 500)
+
+	if len(chunks) != 1 {
+		t.Fatalf("Expected 1 chunk, got %d", len(chunks))
+	}
+	if chunks[0].HeadingPath != "" {
+		t.Errorf("Expected empty heading path, got %q", chunks[0].HeadingPath)
+	}
+}
+
+func TestChunkMarkdown_Empty(t *testing.T) {
+	if chunks := chunkMarkdown("  \n\n", 500); len(chunks) != 0 {
+		t.Errorf("Expected no chunks, got %d", len(chunks))
+	}
+}
+
+func TestChunkMarkdown_ShortText(t *testing.T) {
+	chunks := chunkMarkdown("Paragraph 1\n\nParagraph 2\n\nParagraph 3", 500)
+
+	if len(chunks) != 1 {
+		t.Fatalf("Expected 1 chunk for short text, got %d", len(chunks))
+	}
+	if !strings.Contains(chunks[0].Content, "Paragraph 1") || !strings.Contains(chunks[0].Content, "Paragraph 3") {
+		t.Errorf("Chunk does not contain expected content: %q", chunks[0].Content)
+	}
+}
+
+func TestChunkMarkdown_LongText(t *testing.T) {
+	// Create content longer than chunk size
+	var paragraphs []string
+	for i := 0; i < 10; i++ {
+		paragraphs = append(paragraphs, strings.Repeat("Test paragraph. ", 50))
+	}
+
+	chunks := chunkMarkdown(strings.Join(paragraphs, "\n\n"), 500)
+
+	if len(chunks) < 2 {
+		t.Fatalf("Expected multiple chunks, got %d", len(chunks))
+	}
+	for i, chunk := range chunks {
+		if chunk.Content == "" {
+			t.Errorf("Chunk %d is empty", i)
+		}
+		if n := utf8.RuneCountInString(chunk.Content); n > 500 {
+			t.Errorf("Chunk %d has %d characters, more than the chunk size", i, n)
+		}
+		if chunk.Position != i {
+			t.Errorf("Expected chunk %d at position %d, got %d", i, i, chunk.Position)
+		}
+	}
+}
+
+func TestChunkMarkdown_EmptyText(t *testing.T) {
+	if chunks := chunkMarkdown("", 500); len(chunks) != 0 {
+		t.Errorf("Expected 0 chunks for empty text, got %d", len(chunks))
+	}
+}
@@ -1,12 +1,16 @@
 package indexer
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"os"
-	"path/filepath"
+	"sync"
 
 	"github.com/tomohiro-owada/devrag/internal/config"
 	"github.com/tomohiro-owada/devrag/internal/embedder"
+	"github.com/tomohiro-owada/devrag/internal/frontmatter"
+	"github.com/tomohiro-owada/devrag/internal/sources"
 	"github.com/tomohiro-owada/devrag/internal/vectordb"
 )
 
@@ -14,6 +18,13 @@ type Indexer struct {
 	db       *vectordb.DB
 	embedder embedder.Embedder
 	config   *config.Config
+	sources  *sources.Set
+
+	// mu serializes index mutations between MCP tool calls and the watcher
+	mu sync.RWMutex
+
+	// onChange is called after a document has been re-indexed or removed
+	onChange func(filename string, removed bool)
 }
 
 // NewIndexer creates a new indexer
@@ -22,96 +33,153 @@ func NewIndexer(db *vectordb.DB, emb embedder.Embedder, cfg *config.Config) *Ind
 		db:       db,
 		embedder: emb,
 		config:   cfg,
+		sources:  sources.New(cfg.DocumentSources()),
 	}
 }
 
-// IndexFile indexes a single markdown file
-func (idx *Indexer) IndexFile(filePath string) error {
-	fmt.Fprintf(os.Stderr, "[INFO] Indexing file: %s\n", filePath)
+// Sources returns the document sources that Sync and the watcher index
+func (idx *Indexer) Sources() *sources.Set {
+	return idx.sources
+}
 
-	// Get file info
-	info, err := os.Stat(filePath)
-	if err != nil {
-		return fmt.Errorf("failed to stat file: %w", err)
-	}
+// Lock acquires exclusive access to the index for a mutation
+func (idx *Indexer) Lock() {
+	idx.mu.Lock()
+}
 
-	// Parse markdown
-	chunks, err := ParseMarkdown(filePath, idx.config.ChunkSize)
-	if err != nil {
-		return fmt.Errorf("failed to parse markdown: %w", err)
-	}
+// Unlock releases exclusive access acquired by Lock
+func (idx *Indexer) Unlock() {
+	idx.mu.Unlock()
+}
 
-	if len(chunks) == 0 {
-		fmt.Fprintf(os.Stderr, "[WARN] No chunks extracted from %s (file may be empty)\n", filePath)
-		return nil
-	}
+// RLock acquires shared access to the index for reading
+func (idx *Indexer) RLock() {
+	idx.mu.RLock()
+}
 
-	fmt.Fprintf(os.Stderr, "[INFO] Parsed %d chunks\n", len(chunks))
+// RUnlock releases shared access acquired by RLock
+func (idx *Indexer) RUnlock() {
+	idx.mu.RUnlock()
+}
+
+// OnChange registers fn to be called after a document has been re-indexed or
+// removed. fn runs while the index is locked and must not call the indexer.
+func (idx *Indexer) OnChange(fn func(filename string, removed bool)) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.onChange = fn
+}
 
-	// Vectorize chunks
-	texts := make([]string, len(chunks))
-	for i, chunk := range chunks {
-		texts[i] = chunk.Content
+// notifyChange reports a changed document to the OnChange listener
+func (idx *Indexer) notifyChange(filename string, removed bool) {
+	if idx.onChange != nil {
+		idx.onChange(filename, removed)
 	}
+}
 
-	vectors, err := idx.embedder.EmbedBatch(texts)
-	if err != nil {
-		return fmt.Errorf("failed to vectorize: %w", err)
+// DeleteDocument removes a document from the index
+func (idx *Indexer) DeleteDocument(filename string) error {
+	if err := idx.db.DeleteDocument(filename); err != nil {
+		return err
 	}
+	idx.notifyChange(filename, true)
+	return nil
+}
 
-	fmt.Fprintf(os.Stderr, "[INFO] Generated %d embeddings\n", len(vectors))
+// IndexResult reports how the chunks of an indexed file were embedded
+type IndexResult struct {
+	Chunks   int `json:"chunks"`   // chunks stored for the file
+	Reused   int `json:"reused"`   // chunks whose vectors were reused from the index
+	Embedded int `json:"embedded"` // chunks embedded by the model
+}
 
-	// Convert chunks to ChunkInterface slice
-	chunkInterfaces := make([]vectordb.ChunkInterface, len(chunks))
-	for i, chunk := range chunks {
-		chunkInterfaces[i] = chunk
+// IndexFile indexes a single markdown file
+// Vectors of chunks whose content is already in the index are reused,
+// so only new or changed chunks are embedded
+func (idx *Indexer) IndexFile(filePath string) (*IndexResult, error) {
+	var task *fileTask
+	idx.indexFiles([]indexJob{{path: filePath, force: true}}, func(t *fileTask) {
+		task = t
+	})
+	if task.err != nil {
+		return nil, task.err
 	}
+	return task.result, nil
+}
 
-	// Store in database
-	if err := idx.db.InsertDocument(filePath, info.ModTime(), chunkInterfaces, vectors); err != nil {
-		return fmt.Errorf("failed to store in database: %w", err)
+// IndexFiles indexes several markdown files in the indexing pipeline
+// done is called for every file once it has been stored or has failed
+func (idx *Indexer) IndexFiles(paths []string, done func(path string, result *IndexResult, err error)) {
+	jobs := make([]indexJob, len(paths))
+	for i, path := range paths {
+		jobs[i] = indexJob{path: path, force: true}
 	}
-
-	fmt.Fprintf(os.Stderr, "[INFO] Successfully indexed %s (%d chunks)\n", filePath, len(chunks))
-	return nil
+	idx.indexFiles(jobs, func(task *fileTask) {
+		if task.err != nil {
+			done(task.path, nil, task.err)
+			return
+		}
+		done(task.path, task.result, nil)
+	})
 }
 
-// IndexDirectory indexes all markdown files in a directory
-func (idx *Indexer) IndexDirectory(dir string) error {
-	fmt.Fprintf(os.Stderr, "[INFO] Indexing directory: %s\n", dir)
+// HashFile returns the hex-encoded SHA-256 of a file's content, as stored
+// for indexed documents
+func HashFile(filePath string) (string, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	return hashContent(data), nil
+}
 
-	fileCount := 0
+// hashContent returns the hex-encoded SHA-256 of file content
+func hashContent(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
 
-	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "[WARN] Error accessing %s: %v\n", path, err)
-			return nil // Continue walking despite errors
-		}
+// readMetadata parses the frontmatter of file content and converts it for storage
+func readMetadata(data []byte) (*vectordb.DocumentMetadata, error) {
+	fm, _, err := frontmatter.Parse(string(data))
+	if err != nil || fm == nil {
+		return nil, err
+	}
 
-		// Skip directories
-		if info.IsDir() {
-			return nil
-		}
+	return &vectordb.DocumentMetadata{
+		Domain:   fm.Domain,
+		DocType:  fm.DocType,
+		Language: fm.Language,
+		Project:  fm.Project,
+		Tags:     fm.Tags,
+	}, nil
+}
 
-		// Only process markdown files
-		if filepath.Ext(path) != ".md" {
-			return nil
-		}
+// IndexDirectory indexes the files of the document sources below a directory
+// Files are parsed, embedded and stored by the indexing pipeline
+func (idx *Indexer) IndexDirectory(dir string) error {
+	fmt.Fprintf(os.Stderr, "[INFO] Indexing directory: %s\n", dir)
 
-		// Index the file
-		if err := idx.IndexFile(path); err != nil {
-			fmt.Fprintf(os.Stderr, "[WARN] Failed to index %s: %v\n", path, err)
-			return nil // Continue with other files
+	var jobs []indexJob
+	err := idx.sources.Walk(dir, func(path string, info os.FileInfo) error {
+		if !info.IsDir() {
+			jobs = append(jobs, indexJob{path: path, force: true})
 		}
-
-		fileCount++
 		return nil
 	})
-
 	if err != nil {
 		return fmt.Errorf("failed to walk directory: %w", err)
 	}
 
+	fileCount := 0
+	idx.indexFiles(jobs, func(task *fileTask) {
+		if task.err != nil {
+			fmt.Fprintf(os.Stderr, "[WARN] Failed to index %s: %v\n", task.path, task.err)
+			return // Continue with other files
+		}
+		fileCount++
+	})
+
 	fmt.Fprintf(os.Stderr, "[INFO] Indexing complete: %d files processed\n", fileCount)
 	return nil
 }
@@ -2,15 +2,18 @@ package indexer
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"os"
 	"strings"
-	"unicode/utf8"
+
+	"github.com/tomohiro-owada/devrag/internal/frontmatter"
 )
 
 type Chunk struct {
-	Content  string
-	Position int
+	Content     string
+	Position    int
+	HeadingPath string // headings the chunk belongs to, joined with HeadingSeparator
 }
 
 // GetContent returns the content of the chunk
@@ -23,17 +26,25 @@ func (c Chunk) GetPosition() int {
 	return c.Position
 }
 
+// GetHeadingPath returns the section breadcrumb of the chunk
+func (c Chunk) GetHeadingPath() string {
+	return c.HeadingPath
+}
+
 // ParseMarkdown parses a markdown file and splits into chunks
 func ParseMarkdown(filepath string, chunkSize int) ([]Chunk, error) {
-	file, err := os.Open(filepath)
+	data, err := os.ReadFile(filepath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open file: %w", err)
 	}
-	defer file.Close()
+	return parseMarkdown(data, chunkSize)
+}
 
-	// Read entire file
+// parseMarkdown splits the content of a markdown file into chunks
+func parseMarkdown(data []byte, chunkSize int) ([]Chunk, error) {
+	// Read entire content line by line
 	var content strings.Builder
-	scanner := bufio.NewScanner(file)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
 	for scanner.Scan() {
 		content.WriteString(scanner.Text())
 		content.WriteString("\n")
@@ -43,80 +54,11 @@ func ParseMarkdown(filepath string, chunkSize int) ([]Chunk, error) {
 		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
 
-	// Split into chunks
-	chunks := splitIntoChunks(content.String(), chunkSize)
-
-	// Create Chunk structs
-	result := make([]Chunk, len(chunks))
-	for i, c := range chunks {
-		result[i] = Chunk{
-			Content:  c,
-			Position: i,
-		}
-	}
-
-	return result, nil
-}
-
-// splitIntoChunks splits text into chunks of approximately chunkSize characters
-func splitIntoChunks(content string, chunkSize int) []string {
-	// Use rune count for character-based chunking
-	if utf8.RuneCountInString(content) <= chunkSize {
-		// If content is small enough, return as single chunk
-		trimmed := strings.TrimSpace(content)
-		if trimmed == "" {
-			return []string{}
-		}
-		return []string{trimmed}
-	}
-
-	var chunks []string
-	var currentChunk strings.Builder
-
-	// Split by paragraphs (double newline)
-	paragraphs := strings.Split(content, "\n\n")
+	// Frontmatter is stored as document metadata, not as chunk content
+	_, body, _ := frontmatter.Parse(content.String())
 
-	for _, para := range paragraphs {
-		para = strings.TrimSpace(para)
-		if para == "" {
-			continue
-		}
-
-		currentLen := utf8.RuneCountInString(currentChunk.String())
-		paraLen := utf8.RuneCountInString(para)
-
-		// If adding this paragraph exceeds chunk size, start new chunk
-		if currentLen > 0 && currentLen+paraLen+2 > chunkSize { // +2 for "\n\n"
-			chunks = append(chunks, currentChunk.String())
-			currentChunk.Reset()
-			currentLen = 0
-		}
-
-		// If single paragraph is too large, split it
-		if paraLen > chunkSize {
-			// Flush current chunk first
-			if currentChunk.Len() > 0 {
-				chunks = append(chunks, currentChunk.String())
-				currentChunk.Reset()
-			}
-
-			// Split by sentences or fixed size
-			subChunks := splitLargeParagraph(para, chunkSize)
-			chunks = append(chunks, subChunks...)
-		} else {
-			if currentChunk.Len() > 0 {
-				currentChunk.WriteString("\n\n")
-			}
-			currentChunk.WriteString(para)
-		}
-	}
-
-	// Add remaining chunk
-	if currentChunk.Len() > 0 {
-		chunks = append(chunks, currentChunk.String())
-	}
-
-	return chunks
+	// Split into chunks along the heading structure
+	return chunkMarkdown(body, chunkSize), nil
 }
 
 // splitLargeParagraph splits a large paragraph into smaller chunks
@@ -97,57 +97,6 @@ func TestParseMarkdown_NonExistentFile(t *testing.T) {
 	}
 }
 
-func TestSplitIntoChunks_ShortText(t *testing.T) {
-	content := "Paragraph 1\n\nParagraph 2\n\nParagraph 3"
-	chunks := splitIntoChunks(content, 500)
-
-	if len(chunks) != 1 {
-		t.Errorf("Expected 1 chunk for short text, got %d", len(chunks))
-	}
-
-	if !strings.Contains(chunks[0], "Paragraph 1") {
-		t.Error("Chunk does not contain expected content")
-	}
-}
-
-func TestSplitIntoChunks_LongText(t *testing.T) {
-	// Create content longer than chunk size
-	var paragraphs []string
-	for i := 0; i < 10; i++ {
-		paragraphs = append(paragraphs, strings.Repeat("Test paragraph. ", 50))
-	}
-	content := strings.Join(paragraphs, "\n\n")
-
-	chunks := splitIntoChunks(content, 500)
-
-	if len(chunks) < 2 {
-		t.Errorf("Expected multiple chunks, got %d", len(chunks))
-	}
-
-	// Verify chunks are not empty
-	for i, chunk := range chunks {
-		if len(chunk) == 0 {
-			t.Errorf("Chunk %d is empty", i)
-		}
-	}
-}
-
-func TestSplitIntoChunks_EmptyText(t *testing.T) {
-	chunks := splitIntoChunks("", 500)
-
-	if len(chunks) != 0 {
-		t.Errorf("Expected 0 chunks for empty text, got %d", len(chunks))
-	}
-}
-
-func TestSplitIntoChunks_WhitespaceOnly(t *testing.T) {
-	chunks := splitIntoChunks("   \n\n   \n\n   ", 500)
-
-	if len(chunks) != 0 {
-		t.Errorf("Expected 0 chunks for whitespace-only text, got %d", len(chunks))
-	}
-}
-
 func TestSplitLargeParagraph(t *testing.T) {
 	// Create a very long paragraph
 	longPara := strings.Repeat("This is a long sentence. ", 100)
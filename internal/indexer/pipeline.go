@@ -0,0 +1,354 @@
+package indexer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/tomohiro-owada/devrag/internal/vectordb"
+)
+
+// The indexing pipeline has three stages:
+//
+//  1. parse workers read, hash and chunk files in parallel (indexing.workers)
+//  2. a single embedding stage looks up stored vectors and embeds the remaining
+//     chunks of several files per model call (compute.batch_size)
+//  3. a single writer, running on the caller's goroutine, stores the files in
+//     batched transactions (indexing.write_batch_size)
+//
+// The writer never keeps a transaction open while it waits for the embedding
+// stage, so stored vectors can be looked up while files are being written.
+
+// indexJob is a file to bring up to date
+type indexJob struct {
+	path   string
+	state  vectordb.DocumentState // indexed version, if exists
+	exists bool
+	force  bool // re-index even if the file is unchanged
+}
+
+// fileTask carries a file through the pipeline
+type fileTask struct {
+	path     string
+	change   fileChange
+	modTime  time.Time
+	hash     string
+	chunks   []Chunk
+	hashes   []string // content hash of each chunk
+	vectors  [][]float32
+	metadata *vectordb.DocumentMetadata
+	result   *IndexResult // nil unless the file was re-indexed
+	err      error
+}
+
+// needsIndexing reports whether the file's chunks have to be embedded and stored
+func (t *fileTask) needsIndexing() bool {
+	return t.err == nil && (t.change == fileAdded || t.change == fileUpdated)
+}
+
+// indexFiles brings files up to date through the pipeline
+// done is called for every file once it has been handled, on the caller's goroutine
+func (idx *Indexer) indexFiles(jobs []indexJob, done func(task *fileTask)) {
+	if len(jobs) == 0 {
+		return
+	}
+
+	workers := idx.config.Indexing.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	workers = min(workers, len(jobs))
+
+	pending := make(chan indexJob)
+	go func() {
+		defer close(pending)
+		for _, job := range jobs {
+			pending <- job
+		}
+	}()
+
+	// Stage 1: parse files in parallel
+	parsed := make(chan *fileTask, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range pending {
+				parsed <- idx.prepareFile(job)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(parsed)
+	}()
+
+	// Stage 2: embed chunks in batches spanning files
+	embedded := make(chan *fileTask, idx.config.Indexing.WriteBatchSize)
+	go idx.embedFiles(parsed, embedded)
+
+	// Stage 3: store files in batched transactions
+	idx.storeFiles(embedded, done)
+}
+
+// prepareFile classifies a file against its indexed version and, if it has to be
+// re-indexed, reads and chunks it
+// The modification time is a fast pre-check; when it differs the content hash
+// decides whether the file is re-embedded or only its timestamp is updated
+func (idx *Indexer) prepareFile(job indexJob) *fileTask {
+	task := &fileTask{path: job.path, change: fileUnchanged}
+	if !job.exists {
+		task.change = fileAdded
+	}
+
+	info, err := os.Stat(job.path)
+	if err != nil {
+		task.err = fmt.Errorf("failed to stat file: %w", err)
+		return task
+	}
+	task.modTime = info.ModTime()
+
+	if !job.force && job.exists && task.modTime.Equal(job.state.ModifiedAt) {
+		return task
+	}
+
+	// Read the file once; its content is hashed, chunked and searched for frontmatter
+	data, err := os.ReadFile(job.path)
+	if err != nil {
+		task.err = fmt.Errorf("failed to read file: %w", err)
+		return task
+	}
+
+	// Hash content so that Sync can tell edits from timestamp-only changes
+	task.hash = hashContent(data)
+
+	if job.exists {
+		task.change = fileUpdated
+	}
+	if !job.force {
+		switch {
+		case !job.exists:
+			// New file: exists in filesystem but not in database
+			fmt.Fprintf(os.Stderr, "[INFO] New file detected: %s\n", job.path)
+		case task.hash == job.state.ContentHash:
+			// Touched file: only the timestamp changed, keep the existing embeddings
+			fmt.Fprintf(os.Stderr, "[INFO] Touched file detected: %s\n", job.path)
+			task.change = fileTouched
+			return task
+		default:
+			// Updated file: content differs
+			fmt.Fprintf(os.Stderr, "[INFO] Updated file detected: %s (fs: %v, db: %v)\n",
+				job.path, task.modTime.Format(time.RFC3339), job.state.ModifiedAt.Format(time.RFC3339))
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "[INFO] Indexing file: %s\n", job.path)
+
+	// Parse markdown
+	task.chunks, err = parseMarkdown(data, idx.config.ChunkSize)
+	if err != nil {
+		task.err = fmt.Errorf("failed to parse markdown: %w", err)
+		return task
+	}
+	fmt.Fprintf(os.Stderr, "[INFO] Parsed %d chunks from %s\n", len(task.chunks), job.path)
+
+	task.hashes = make([]string, len(task.chunks))
+	for i, chunk := range task.chunks {
+		task.hashes[i] = vectordb.ChunkHash(chunk.Content)
+	}
+
+	// Read frontmatter metadata (documents without frontmatter are stored without metadata)
+	task.metadata, err = readMetadata(data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[WARN] Failed to parse frontmatter of %s: %v\n", job.path, err)
+	}
+	return task
+}
+
+// embedBatch collects the chunks of several files that are not in the index yet,
+// so that small files share model calls
+type embedBatch struct {
+	tasks  []*fileTask
+	texts  []string
+	hashes []string
+	seen   map[string]bool
+}
+
+// add reuses the stored vectors of a file's chunks and queues the others,
+// embedding identical chunks only once
+func (b *embedBatch) add(task *fileTask, stored map[string][]float32) {
+	task.vectors = make([][]float32, len(task.chunks))
+	task.result = &IndexResult{Chunks: len(task.chunks)}
+	for i, chunk := range task.chunks {
+		if vec, ok := stored[task.hashes[i]]; ok {
+			task.vectors[i] = vec
+			continue
+		}
+		if !b.seen[task.hashes[i]] {
+			b.seen[task.hashes[i]] = true
+			b.texts = append(b.texts, chunk.Content)
+			b.hashes = append(b.hashes, task.hashes[i])
+			task.result.Embedded++
+		}
+	}
+	task.result.Reused = task.result.Chunks - task.result.Embedded
+	b.tasks = append(b.tasks, task)
+}
+
+// embedFiles is the embedding stage of the pipeline
+func (idx *Indexer) embedFiles(in <-chan *fileTask, out chan<- *fileTask) {
+	defer close(out)
+
+	batch := &embedBatch{seen: make(map[string]bool)}
+	flush := func() {
+		idx.embedBatch(batch)
+		for _, task := range batch.tasks {
+			out <- task
+		}
+		batch = &embedBatch{seen: make(map[string]bool)}
+	}
+
+	for task := range in {
+		if !task.needsIndexing() || len(task.chunks) == 0 {
+			out <- task
+			continue
+		}
+
+		// Look up vectors of chunks that are already indexed
+		stored, err := idx.db.GetEmbeddingsByHash(task.hashes)
+		if err != nil {
+			task.err = fmt.Errorf("failed to look up stored vectors: %w", err)
+			out <- task
+			continue
+		}
+
+		batch.add(task, stored)
+		if len(batch.texts) >= idx.config.Compute.BatchSize {
+			flush()
+		}
+	}
+	if len(batch.tasks) > 0 {
+		flush()
+	}
+}
+
+// embedBatch embeds the queued chunks and fills in the vectors of the batch's files
+func (idx *Indexer) embedBatch(batch *embedBatch) {
+	vectors := make(map[string][]float32, len(batch.texts))
+	if len(batch.texts) > 0 {
+		embedded, err := idx.embedder.EmbedDocuments(batch.texts)
+		if err != nil {
+			for _, task := range batch.tasks {
+				task.err = fmt.Errorf("failed to vectorize: %w", err)
+			}
+			return
+		}
+		for i, hash := range batch.hashes {
+			vectors[hash] = embedded[i]
+		}
+	}
+
+	for _, task := range batch.tasks {
+		for i := range task.vectors {
+			if task.vectors[i] == nil {
+				task.vectors[i] = vectors[task.hashes[i]]
+			}
+		}
+		fmt.Fprintf(os.Stderr, "[INFO] Generated %d embeddings for %s (%d reused)\n", task.result.Embedded, task.path, task.result.Reused)
+	}
+}
+
+// storeFiles is the writer stage of the pipeline
+// It stores the files that are ready in one transaction, without waiting for
+// more, and then blocks for the next file
+func (idx *Indexer) storeFiles(in <-chan *fileTask, done func(task *fileTask)) {
+	for task := range in {
+		batch := []*fileTask{task}
+	collect:
+		for len(batch) < idx.config.Indexing.WriteBatchSize {
+			select {
+			case task, ok := <-in:
+				if !ok {
+					break collect
+				}
+				batch = append(batch, task)
+			default:
+				break collect
+			}
+		}
+
+		idx.storeBatch(batch)
+		for _, task := range batch {
+			done(task)
+		}
+	}
+}
+
+// storeBatch writes a batch of files to the index
+// If the transaction fails, the files are stored one by one so that a single
+// bad file does not fail the others
+func (idx *Indexer) storeBatch(batch []*fileTask) {
+	var tasks []*fileTask
+	var records []vectordb.DocumentRecord
+	for _, task := range batch {
+		switch {
+		case task.err != nil:
+		case task.change == fileTouched:
+			task.err = idx.db.UpdateModifiedAt(task.path, task.modTime)
+		case !task.needsIndexing():
+		case len(task.chunks) == 0:
+			fmt.Fprintf(os.Stderr, "[WARN] No chunks extracted from %s (file may be empty)\n", task.path)
+			task.result = &IndexResult{}
+			// Drop a previously indexed version of the file
+			if err := idx.DeleteDocument(task.path); err != nil && !errors.Is(err, vectordb.ErrDocumentNotFound) {
+				task.err = fmt.Errorf("failed to delete old version: %w", err)
+			}
+		default:
+			tasks = append(tasks, task)
+			records = append(records, task.record())
+		}
+	}
+	if len(records) == 0 {
+		return
+	}
+
+	if err := idx.db.InsertDocuments(records); err != nil {
+		if len(records) == 1 {
+			tasks[0].err = fmt.Errorf("failed to store in database: %w", err)
+		} else {
+			fmt.Fprintf(os.Stderr, "[WARN] Failed to store %d documents in one transaction, storing them one by one: %v\n", len(records), err)
+			for i, task := range tasks {
+				if err := idx.db.InsertDocuments(records[i : i+1]); err != nil {
+					task.err = fmt.Errorf("failed to store in database: %w", err)
+				}
+			}
+		}
+	}
+
+	for _, task := range tasks {
+		if task.err == nil {
+			fmt.Fprintf(os.Stderr, "[INFO] Successfully indexed %s (%d chunks)\n", task.path, len(task.chunks))
+			idx.notifyChange(task.path, false)
+		}
+	}
+}
+
+// record converts a parsed and embedded file for storage
+func (t *fileTask) record() vectordb.DocumentRecord {
+	chunks := make([]vectordb.ChunkInterface, len(t.chunks))
+	for i, chunk := range t.chunks {
+		chunks[i] = chunk
+	}
+	return vectordb.DocumentRecord{
+		Filename:    t.path,
+		ModifiedAt:  t.modTime,
+		ContentHash: t.hash,
+		Metadata:    t.metadata,
+		Chunks:      chunks,
+		Embeddings:  t.vectors,
+	}
+}
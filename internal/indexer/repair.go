@@ -0,0 +1,36 @@
+package indexer
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/tomohiro-owada/devrag/internal/vectordb"
+)
+
+// RepairIndex fixes inconsistencies between documents, chunks and vectors
+// Documents that had to be removed are re-indexed from their files if they still
+// exist; the returned list holds the re-indexed filenames
+func (idx *Indexer) RepairIndex() (*vectordb.IntegrityReport, []string, error) {
+	report, err := idx.db.RepairIntegrity()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to repair index: %w", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "[INFO] Repair removed %d orphan vectors, %d orphan chunks and %d incomplete documents\n",
+		len(report.OrphanVectors), len(report.OrphanChunks), len(report.AffectedDocuments()))
+
+	reindexed := []string{}
+	for _, filename := range report.AffectedDocuments() {
+		if _, err := os.Stat(filename); err != nil {
+			fmt.Fprintf(os.Stderr, "[WARN] Not re-indexing %s: %v\n", filename, err)
+			idx.notifyChange(filename, true)
+			continue
+		}
+		if _, err := idx.IndexFile(filename); err != nil {
+			return report, reindexed, fmt.Errorf("failed to re-index %s: %w", filename, err)
+		}
+		reindexed = append(reindexed, filename)
+	}
+
+	return report, reindexed, nil
+}
@@ -0,0 +1,25 @@
+package indexer
+
+import (
+	"fmt"
+
+	"github.com/tomohiro-owada/devrag/internal/vectordb"
+)
+
+// Search vectorizes the query text unless the mode does not need a vector and
+// searches the index
+func (idx *Indexer) Search(query vectordb.SearchQuery) ([]vectordb.SearchResult, error) {
+	if query.Mode != vectordb.ModeKeyword {
+		vector, err := idx.embedder.EmbedQuery(query.Text)
+		if err != nil {
+			return nil, fmt.Errorf("failed to vectorize query: %w", err)
+		}
+		query.Vector = vector
+	}
+
+	results, err := idx.db.Search(query)
+	if err != nil {
+		return nil, fmt.Errorf("search failed: %w", err)
+	}
+	return results, nil
+}
@@ -0,0 +1,156 @@
+package indexer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/tomohiro-owada/devrag/internal/config"
+	"github.com/tomohiro-owada/devrag/internal/embedder"
+)
+
+// ErrEmbeddingMismatch is returned when the index was built with different
+// embedding settings and model.on_mismatch is "refuse"
+var ErrEmbeddingMismatch = errors.New("index was built with different embedding settings")
+
+// Index metadata keys recording how the stored vectors were produced
+const (
+	modelSettingKey         = "model"
+	dimensionsSettingKey    = "dimensions"
+	poolingSettingKey       = "pooling"
+	queryPrefixSettingKey   = "query_prefix"
+	passagePrefixSettingKey = "passage_prefix"
+)
+
+// settingValue is a single embedding setting as stored in index metadata
+type settingValue struct {
+	key   string
+	value string
+}
+
+// settingValues flattens embedding settings into index metadata entries
+func settingValues(s embedder.Settings) []settingValue {
+	return []settingValue{
+		{modelSettingKey, s.Model},
+		{dimensionsSettingKey, strconv.Itoa(s.Dimensions)},
+		{poolingSettingKey, string(s.Pooling)},
+		{queryPrefixSettingKey, s.QueryPrefix},
+		{passagePrefixSettingKey, s.PassagePrefix},
+	}
+}
+
+// EnsureEmbeddingSettings compares the embedding settings the index was built with
+// against the current ones. On a mismatch the index is either cleared so that the
+// next Sync re-embeds every document, or ErrEmbeddingMismatch is returned, as
+// selected by model.on_mismatch. It reports whether the index was cleared.
+func (idx *Indexer) EnsureEmbeddingSettings(current embedder.Settings) (bool, error) {
+	changes, err := idx.settingChanges(current)
+	if err != nil {
+		return false, err
+	}
+
+	if len(changes) == 0 {
+		// Record the settings of new indexes
+		return false, idx.recordSettings(current)
+	}
+
+	if idx.config.Model.OnMismatch == config.OnMismatchRefuse {
+		return false, fmt.Errorf("%w: %s", ErrEmbeddingMismatch, strings.Join(changes, ", "))
+	}
+
+	fmt.Fprintf(os.Stderr, "[WARN] Embedding settings changed (%s), existing embeddings are incompatible\n", strings.Join(changes, ", "))
+	fmt.Fprintf(os.Stderr, "[WARN] Clearing the index, all documents will be re-indexed\n")
+
+	if err := idx.db.ResetIndex(current.Dimensions); err != nil {
+		return false, fmt.Errorf("failed to clear index: %w", err)
+	}
+	if err := idx.recordSettings(current); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// CheckEmbeddingSettings returns ErrEmbeddingMismatch if the index was built with
+// different embedding settings, without modifying the index
+func (idx *Indexer) CheckEmbeddingSettings(current embedder.Settings) error {
+	changes, err := idx.settingChanges(current)
+	if err != nil {
+		return err
+	}
+	if len(changes) > 0 {
+		return fmt.Errorf("%w: %s", ErrEmbeddingMismatch, strings.Join(changes, ", "))
+	}
+	return nil
+}
+
+// settingChanges describes how the current embedding settings differ from those
+// the index was built with. An empty index without recorded settings matches any
+// settings of its vector table's dimension.
+func (idx *Indexer) settingChanges(current embedder.Settings) ([]string, error) {
+	// vec0 tables have a fixed dimension, so even an empty table has to be recreated
+	var changes []string
+	if idx.db.Dimensions() != current.Dimensions {
+		changes = append(changes, fmt.Sprintf("vector table dimensions: %d -> %d", idx.db.Dimensions(), current.Dimensions))
+	}
+
+	recorded, err := idx.recordedSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	if recorded == nil {
+		docs, err := idx.db.ListDocuments()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list documents: %w", err)
+		}
+		if len(docs) == 0 {
+			return changes, nil
+		}
+		// The settings of documents indexed without a record are unknown
+		return append(changes, "embedding settings not recorded"), nil
+	}
+
+	for _, s := range settingValues(current) {
+		if recorded[s.key] != s.value {
+			changes = append(changes, fmt.Sprintf("%s: %q -> %q", s.key, recorded[s.key], s.value))
+		}
+	}
+	return changes, nil
+}
+
+// RecordedModel returns the name of the model the index was built with, or an
+// empty string if it has not been recorded
+func (idx *Indexer) RecordedModel() (string, error) {
+	model, _, err := idx.db.GetIndexMetadata(modelSettingKey)
+	return model, err
+}
+
+// recordedSettings returns the embedding settings stored in index metadata,
+// or nil if none have been recorded
+func (idx *Indexer) recordedSettings() (map[string]string, error) {
+	recorded := make(map[string]string)
+	for _, s := range settingValues(embedder.Settings{}) {
+		value, ok, err := idx.db.GetIndexMetadata(s.key)
+		if err != nil {
+			return nil, err
+		}
+		if s.key == modelSettingKey && !ok {
+			return nil, nil
+		}
+		recorded[s.key] = value
+	}
+	return recorded, nil
+}
+
+// recordSettings stores the embedding settings in index metadata
+func (idx *Indexer) recordSettings(settings embedder.Settings) error {
+	for _, s := range settingValues(settings) {
+		if err := idx.db.SetIndexMetadata(s.key, s.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -3,19 +3,39 @@ package indexer
 import (
 	"fmt"
 	"os"
-	"path/filepath"
-	"time"
 )
 
 // SyncResult represents the results of a sync operation
 type SyncResult struct {
-	Added   []string
-	Updated []string
-	Deleted []string
+	Added   []string      `json:"added"`
+	Updated []string      `json:"updated"`
+	Deleted []string      `json:"deleted"`
+	Touched []string      `json:"touched"` // modification time changed but content is identical
+	Failed  []SyncFailure `json:"failed"`  // files that could not be read or indexed
+
+	ReusedChunks   int `json:"reused_chunks"`   // chunks whose stored vectors were reused
+	EmbeddedChunks int `json:"embedded_chunks"` // chunks embedded by the model
 }
 
-// Sync synchronizes the documents directory with the database
+// SyncFailure is a file that could not be synced
+type SyncFailure struct {
+	File  string `json:"file"`
+	Error string `json:"error"`
+}
+
+// fileChange classifies how a file differs from its indexed version
+type fileChange int
+
+const (
+	fileUnchanged fileChange = iota
+	fileTouched
+	fileAdded
+	fileUpdated
+)
+
+// Sync synchronizes the document sources with the database
 // It detects new, updated, and deleted files and updates the index accordingly
+// Files that no longer match a source are removed from the index like deleted files
 func (idx *Indexer) Sync() (*SyncResult, error) {
 	fmt.Fprintf(os.Stderr, "[INFO] Starting sync...\n")
 
@@ -23,42 +43,24 @@ func (idx *Indexer) Sync() (*SyncResult, error) {
 		Added:   []string{},
 		Updated: []string{},
 		Deleted: []string{},
+		Touched: []string{},
+		Failed:  []SyncFailure{},
 	}
 
-	// Step 1: Get files from database (filename -> modified_at)
-	dbFiles, err := idx.db.ListDocuments()
+	// Step 1: Get files from database (filename -> modified_at, content_hash)
+	dbFileMap, err := idx.db.ListDocumentStates()
 	if err != nil {
 		return nil, fmt.Errorf("failed to list database files: %w", err)
 	}
 
-	fmt.Fprintf(os.Stderr, "[INFO] Found %d documents in database\n", len(dbFiles))
-
-	// Create map for quick lookup
-	dbFileMap := make(map[string]time.Time)
-	for filename, modTime := range dbFiles {
-		dbFileMap[filename] = modTime
-	}
-
-	// Step 2: Scan filesystem (filepath -> mtime)
-	fsFiles := make(map[string]time.Time)
-	err = filepath.Walk(idx.config.DocumentsDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "[WARN] Error accessing %s: %v\n", path, err)
-			return nil // Continue walking despite errors
-		}
-
-		// Skip directories
-		if info.IsDir() {
-			return nil
-		}
+	fmt.Fprintf(os.Stderr, "[INFO] Found %d documents in database\n", len(dbFileMap))
 
-		// Only process markdown files
-		if filepath.Ext(path) != ".md" {
-			return nil
+	// Step 2: Scan the document sources
+	var fsFiles []string
+	err = idx.sources.Walk("", func(path string, info os.FileInfo) error {
+		if !info.IsDir() {
+			fsFiles = append(fsFiles, path)
 		}
-
-		// Store file path and modification time
-		fsFiles[path] = info.ModTime()
 		return nil
 	})
 
@@ -70,52 +72,45 @@ func (idx *Indexer) Sync() (*SyncResult, error) {
 
 	// Step 3: Detect changes and process them
 
-	// 3a. Check for new and updated files
-	for fsPath, fsMtime := range fsFiles {
-		if dbMtime, exists := dbFileMap[fsPath]; !exists {
-			// New file: exists in filesystem but not in database
-			fmt.Fprintf(os.Stderr, "[INFO] New file detected: %s\n", fsPath)
-			result.Added = append(result.Added, fsPath)
+	// 3a. Check for new, updated and touched files, indexing them in the pipeline
+	jobs := make([]indexJob, len(fsFiles))
+	onDisk := make(map[string]bool, len(fsFiles))
+	for i, fsPath := range fsFiles {
+		state, exists := dbFileMap[fsPath]
+		jobs[i] = indexJob{path: fsPath, state: state, exists: exists}
+		onDisk[fsPath] = true
+	}
+	idx.indexFiles(jobs, func(task *fileTask) {
+		if task.err != nil {
+			fmt.Fprintf(os.Stderr, "[ERROR] Failed to sync %s: %v\n", task.path, task.err)
+			result.Failed = append(result.Failed, SyncFailure{File: task.path, Error: task.err.Error()})
+			// Continue with other files even if one fails
+			return
+		}
+		if task.result != nil {
+			result.ReusedChunks += task.result.Reused
+			result.EmbeddedChunks += task.result.Embedded
+		}
 
-			// Index the new file
-			if err := idx.IndexFile(fsPath); err != nil {
-				fmt.Fprintf(os.Stderr, "[ERROR] Failed to index new file %s: %v\n", fsPath, err)
-				// Continue with other files even if one fails
-			}
-		} else {
-			// File exists in both filesystem and database
-			// Check if it has been modified by comparing timestamps
-			// We need to normalize timestamps to avoid false positives due to precision differences
-			if !timeEqual(fsMtime, dbMtime) {
-				// Updated file: mtime differs
-				fmt.Fprintf(os.Stderr, "[INFO] Updated file detected: %s (fs: %v, db: %v)\n",
-					fsPath, fsMtime.Format(time.RFC3339), dbMtime.Format(time.RFC3339))
-				result.Updated = append(result.Updated, fsPath)
-
-				// Delete old version from database
-				if err := idx.db.DeleteDocument(fsPath); err != nil {
-					fmt.Fprintf(os.Stderr, "[ERROR] Failed to delete old version of %s: %v\n", fsPath, err)
-					continue
-				}
-
-				// Re-index the updated file
-				if err := idx.IndexFile(fsPath); err != nil {
-					fmt.Fprintf(os.Stderr, "[ERROR] Failed to reindex %s: %v\n", fsPath, err)
-					// Continue with other files even if one fails
-				}
-			}
+		switch task.change {
+		case fileAdded:
+			result.Added = append(result.Added, task.path)
+		case fileUpdated:
+			result.Updated = append(result.Updated, task.path)
+		case fileTouched:
+			result.Touched = append(result.Touched, task.path)
 		}
-	}
+	})
 
 	// 3b. Check for deleted files
 	for dbPath := range dbFileMap {
-		if _, exists := fsFiles[dbPath]; !exists {
+		if !onDisk[dbPath] {
 			// Deleted file: exists in database but not in filesystem
 			fmt.Fprintf(os.Stderr, "[INFO] Deleted file detected: %s\n", dbPath)
 			result.Deleted = append(result.Deleted, dbPath)
 
 			// Delete from database
-			if err := idx.db.DeleteDocument(dbPath); err != nil {
+			if err := idx.DeleteDocument(dbPath); err != nil {
 				fmt.Fprintf(os.Stderr, "[ERROR] Failed to delete %s from database: %v\n", dbPath, err)
 				// Continue with other files even if one fails
 			}
@@ -123,15 +118,9 @@ func (idx *Indexer) Sync() (*SyncResult, error) {
 	}
 
 	// Print summary statistics
-	fmt.Fprintf(os.Stderr, "[INFO] Sync complete: +%d, ~%d, -%d\n",
-		len(result.Added), len(result.Updated), len(result.Deleted))
+	fmt.Fprintf(os.Stderr, "[INFO] Sync complete: +%d, ~%d, -%d (%d touched, %d failed)\n",
+		len(result.Added), len(result.Updated), len(result.Deleted), len(result.Touched), len(result.Failed))
+	fmt.Fprintf(os.Stderr, "[INFO] Chunks: %d embedded, %d reused\n", result.EmbeddedChunks, result.ReusedChunks)
 
 	return result, nil
 }
-
-// timeEqual compares two timestamps with tolerance for filesystem precision differences
-// Some filesystems only support second-level precision, while others support nanoseconds
-func timeEqual(t1, t2 time.Time) bool {
-	// Truncate to second precision to handle filesystem differences
-	return t1.Truncate(time.Second).Equal(t2.Truncate(time.Second))
-}
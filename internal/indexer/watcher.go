@@ -0,0 +1,191 @@
+package indexer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/tomohiro-owada/devrag/internal/vectordb"
+)
+
+// Watcher keeps the index in sync with the document sources while the server runs
+// Filesystem events are collected until no new event arrives for the debounce
+// interval, then applied under the indexer lock
+type Watcher struct {
+	idx      *Indexer
+	fsw      *fsnotify.Watcher
+	debounce time.Duration
+	done     chan struct{}
+	stopped  chan struct{}
+}
+
+// NewWatcher starts watching the directories of the document sources
+func NewWatcher(idx *Indexer, debounce time.Duration) (*Watcher, error) {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create watcher: %w", err)
+	}
+
+	w := &Watcher{
+		idx:      idx,
+		fsw:      fsw,
+		debounce: debounce,
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+
+	if _, err := w.addTree(""); err != nil {
+		fsw.Close()
+		return nil, err
+	}
+
+	go w.run()
+
+	fmt.Fprintf(os.Stderr, "[INFO] Watching %s for changes (debounce: %v)\n", strings.Join(idx.sources.Roots(), ", "), debounce)
+	return w, nil
+}
+
+// Close stops the watcher and waits for pending changes to be applied
+func (w *Watcher) Close() error {
+	close(w.done)
+	<-w.stopped
+	return w.fsw.Close()
+}
+
+// run collects events and applies them once the directory has been quiet for the debounce interval
+func (w *Watcher) run() {
+	defer close(w.stopped)
+
+	pending := make(map[string]struct{})
+	timer := time.NewTimer(w.debounce)
+	timer.Stop()
+
+	for {
+		select {
+		case event, ok := <-w.fsw.Events:
+			if !ok {
+				return
+			}
+			// Permission changes do not affect content
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			pending[event.Name] = struct{}{}
+			timer.Reset(w.debounce)
+
+		case err, ok := <-w.fsw.Errors:
+			if !ok {
+				return
+			}
+			fmt.Fprintf(os.Stderr, "[WARN] Watcher error: %v\n", err)
+
+		case <-timer.C:
+			w.apply(pending)
+			pending = make(map[string]struct{})
+
+		case <-w.done:
+			if len(pending) > 0 {
+				w.apply(pending)
+			}
+			return
+		}
+	}
+}
+
+// apply updates the index for every changed path
+func (w *Watcher) apply(pending map[string]struct{}) {
+	paths := make([]string, 0, len(pending))
+	for path := range pending {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	w.idx.Lock()
+	defer w.idx.Unlock()
+
+	states, err := w.idx.db.ListDocumentStates()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[ERROR] Failed to list documents: %v\n", err)
+		return
+	}
+
+	// Changed files are indexed together once all events have been classified
+	var jobs []indexJob
+	queued := make(map[string]bool)
+	queue := func(path string) {
+		if !queued[path] {
+			queued[path] = true
+			state, exists := states[path]
+			jobs = append(jobs, indexJob{path: path, state: state, exists: exists})
+		}
+	}
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		switch {
+		case err == nil && info.IsDir():
+			// Directory created or moved in: watch it and index its files
+			files, err := w.addTree(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "[WARN] %v\n", err)
+			}
+			for _, file := range files {
+				queue(file)
+			}
+		case err == nil:
+			if w.idx.sources.Match(path) {
+				queue(path)
+			}
+		case os.IsNotExist(err):
+			// File or directory deleted or moved away
+			w.fsw.Remove(path)
+			w.remove(path, states)
+		default:
+			fmt.Fprintf(os.Stderr, "[WARN] Error accessing %s: %v\n", path, err)
+		}
+	}
+
+	// Files whose content is unchanged only have their timestamp updated
+	w.idx.indexFiles(jobs, func(task *fileTask) {
+		if task.err != nil {
+			fmt.Fprintf(os.Stderr, "[ERROR] Failed to sync %s: %v\n", task.path, task.err)
+		}
+	})
+}
+
+// addTree watches dir, or every source if dir is empty, and the subdirectories
+// that are not excluded. It returns the files of the sources found below dir.
+func (w *Watcher) addTree(dir string) ([]string, error) {
+	var files []string
+	err := w.idx.sources.Walk(dir, func(path string, info os.FileInfo) error {
+		if info.IsDir() {
+			if err := w.fsw.Add(path); err != nil {
+				return fmt.Errorf("failed to watch %s: %w", path, err)
+			}
+			return nil
+		}
+
+		files = append(files, path)
+		return nil
+	})
+	return files, err
+}
+
+// remove deletes a file, or every file below a directory, from the index
+func (w *Watcher) remove(path string, states map[string]vectordb.DocumentState) {
+	prefix := path + string(filepath.Separator)
+	for filename := range states {
+		if filename != path && !strings.HasPrefix(filename, prefix) {
+			continue
+		}
+
+		fmt.Fprintf(os.Stderr, "[INFO] Deleted file detected: %s\n", filename)
+		if err := w.idx.DeleteDocument(filename); err != nil {
+			fmt.Fprintf(os.Stderr, "[ERROR] Failed to delete %s from database: %v\n", filename, err)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/tomohiro-owada/devrag/internal/frontmatter"
+	"github.com/tomohiro-owada/devrag/internal/vectordb"
+)
+
+// auditEntryKey is the context key of the audit entry of the running tool call
+type auditEntryKey struct{}
+
+// auditTools records every call of a mutating tool in the audit log
+// Handlers add details such as the resolved document path and frontmatter before
+// and after the call through auditEntryFromContext
+func (s *MCPServer) auditTools(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if readOnlyTools[request.Params.Name] {
+			return next(ctx, request)
+		}
+
+		entry := &vectordb.AuditEntry{Tool: request.Params.Name}
+		if session := server.ClientSessionFromContext(ctx); session != nil {
+			entry.Session = session.SessionID()
+		}
+		if args, err := json.Marshal(request.GetArguments()); err == nil {
+			entry.Arguments = string(args)
+		}
+
+		result, err := next(context.WithValue(ctx, auditEntryKey{}, entry), request)
+
+		switch {
+		case err != nil:
+			entry.Error = err.Error()
+		case result != nil && result.IsError:
+			entry.Error = resultText(result)
+		}
+
+		if auditErr := s.db.AppendAudit(entry); auditErr != nil {
+			fmt.Fprintf(os.Stderr, "[ERROR] Failed to record %s in the audit log: %v\n", entry.Tool, auditErr)
+		}
+
+		return result, err
+	}
+}
+
+// auditEntryFromContext returns the audit entry of the running tool call
+// It returns a throwaway entry for calls that are not audited
+func auditEntryFromContext(ctx context.Context) *vectordb.AuditEntry {
+	if entry, ok := ctx.Value(auditEntryKey{}).(*vectordb.AuditEntry); ok {
+		return entry
+	}
+	return &vectordb.AuditEntry{}
+}
+
+// frontmatterJSON returns a file's frontmatter as JSON for the audit log,
+// or an empty string if the file has none or cannot be read
+func frontmatterJSON(filePath string) string {
+	metadata, _, err := frontmatter.ReadFile(filePath)
+	if err != nil || metadata == nil {
+		return ""
+	}
+	data, err := json.Marshal(map[string]interface{}{
+		"domain":   metadata.Domain,
+		"docType":  metadata.DocType,
+		"language": metadata.Language,
+		"tags":     metadata.Tags,
+		"project":  metadata.Project,
+	})
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// resultText joins the text content of a tool result
+func resultText(result *mcp.CallToolResult) string {
+	text := ""
+	for _, content := range result.Content {
+		if c, ok := content.(mcp.TextContent); ok {
+			text += c.Text
+		}
+	}
+	return text
+}
@@ -0,0 +1,246 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/tomohiro-owada/devrag/internal/vectordb"
+)
+
+// specDocTypes are the frontmatter docTypes find_related_specs searches
+var specDocTypes = []string{"spec", "design", "api"}
+
+// registerPrompts registers prompt templates that are prefilled with chunks
+// retrieved from the index. They only read the index, so they are also
+// available in read-only mode.
+func (s *MCPServer) registerPrompts() {
+	s.server.AddPrompt(
+		mcp.NewPrompt("answer_from_docs",
+			mcp.WithPromptDescription("質問に関連するチャンクを検索し、出典付きで回答させるプロンプト"),
+			mcp.WithArgument("question",
+				mcp.ArgumentDescription("質問（自然言語）"),
+				mcp.RequiredArgument(),
+			),
+			mcp.WithArgument("filters",
+				mcp.ArgumentDescription("frontmatterによる絞り込み（\";\"区切り、AND条件）: \"domain=backend; tags contains auth\""),
+			),
+			mcp.WithArgument("top_k",
+				mcp.ArgumentDescription("含めるチャンクの最大件数"),
+			),
+		),
+		s.handleAnswerFromDocsPrompt,
+	)
+
+	s.server.AddPrompt(
+		mcp.NewPrompt("summarize_document",
+			mcp.WithPromptDescription("ドキュメント全体のチャンクを含めて要約させるプロンプト"),
+			mcp.WithArgument("filename",
+				mcp.ArgumentDescription("ドキュメント名（list_documentsで取得できる名前）"),
+				mcp.RequiredArgument(),
+			),
+		),
+		s.handleSummarizeDocumentPrompt,
+	)
+
+	s.server.AddPrompt(
+		mcp.NewPrompt("find_related_specs",
+			mcp.WithPromptDescription("トピックに関連する仕様書・設計書・API文書を検索し、ドキュメントごとにまとめさせるプロンプト"),
+			mcp.WithArgument("topic",
+				mcp.ArgumentDescription("トピック（自然言語）"),
+				mcp.RequiredArgument(),
+			),
+			mcp.WithArgument("top_k",
+				mcp.ArgumentDescription("含めるチャンクの最大件数"),
+			),
+		),
+		s.handleFindRelatedSpecsPrompt,
+	)
+
+	fmt.Fprintf(os.Stderr, "[INFO] Registered 3 MCP prompts\n")
+}
+
+func (s *MCPServer) handleAnswerFromDocsPrompt(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+	question := strings.TrimSpace(request.Params.Arguments["question"])
+	if question == "" {
+		return nil, fmt.Errorf("question is required")
+	}
+
+	topK, err := promptTopK(request, s.config.SearchTopK)
+	if err != nil {
+		return nil, err
+	}
+
+	var filters []vectordb.Filter
+	for _, expr := range strings.Split(request.Params.Arguments["filters"], ";") {
+		if strings.TrimSpace(expr) == "" {
+			continue
+		}
+		filter, err := vectordb.ParseFilter(strings.TrimSpace(expr))
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+
+	s.indexer.RLock()
+	defer s.indexer.RUnlock()
+
+	results, err := s.indexer.Search(vectordb.SearchQuery{
+		Text:          question,
+		TopK:          topK,
+		Filters:       filters,
+		ContextWindow: 1,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	b.WriteString("Answer the question below using only the numbered excerpts from the indexed documentation.\n")
+	b.WriteString("Cite the excerpts you rely on as [n] together with their document name. ")
+	b.WriteString("If the excerpts do not contain the answer, say so instead of guessing.\n\n")
+	fmt.Fprintf(&b, "Question: %s\n\n", question)
+	writeExcerpts(&b, results)
+
+	return mcp.NewGetPromptResult(
+		fmt.Sprintf("Answer %q from %d excerpts", question, len(results)),
+		[]mcp.PromptMessage{mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent(b.String()))},
+	), nil
+}
+
+func (s *MCPServer) handleSummarizeDocumentPrompt(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+	filename := strings.TrimSpace(request.Params.Arguments["filename"])
+	if filename == "" {
+		return nil, fmt.Errorf("filename is required")
+	}
+
+	s.indexer.RLock()
+	defer s.indexer.RUnlock()
+
+	doc, err := s.db.GetDocument(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get document: %w", err)
+	}
+
+	chunks := []vectordb.DocumentChunk{}
+	if doc.ChunkCount > 0 {
+		chunks, err = s.db.GetDocumentChunks(filename, 0, doc.ChunkCount-1)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get chunks: %w", err)
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Summarize the document %s from its %d sections below.\n", doc.Filename, len(chunks))
+	b.WriteString("Describe its purpose, the main points of each part and any open questions or decisions. ")
+	b.WriteString("Cite sections as [n] when referring to them.\n\n")
+	for i, chunk := range chunks {
+		fmt.Fprintf(&b, "[%d] %s (chunk %d)\n%s\n\n", i+1, chunkLabel(chunk.HeadingPath, chunk.Position), chunk.ID, chunk.Content)
+	}
+
+	return mcp.NewGetPromptResult(
+		fmt.Sprintf("Summarize %s", doc.Filename),
+		[]mcp.PromptMessage{mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent(b.String()))},
+	), nil
+}
+
+func (s *MCPServer) handleFindRelatedSpecsPrompt(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+	topic := strings.TrimSpace(request.Params.Arguments["topic"])
+	if topic == "" {
+		return nil, fmt.Errorf("topic is required")
+	}
+
+	topK, err := promptTopK(request, s.config.SearchTopK)
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := vectordb.ParseFilter(fmt.Sprintf("docType in (%s)", strings.Join(specDocTypes, ", ")))
+	if err != nil {
+		return nil, err
+	}
+
+	s.indexer.RLock()
+	defer s.indexer.RUnlock()
+
+	results, err := s.indexer.Search(vectordb.SearchQuery{
+		Text:    topic,
+		TopK:    topK,
+		Filters: []vectordb.Filter{filter},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Group hits by document, keeping documents in order of their best hit
+	var order []string
+	byDocument := make(map[string][]vectordb.SearchResult)
+	for _, r := range results {
+		if _, ok := byDocument[r.DocumentName]; !ok {
+			order = append(order, r.DocumentName)
+		}
+		byDocument[r.DocumentName] = append(byDocument[r.DocumentName], r)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "List the specifications, design documents and API documents related to %q.\n", topic)
+	b.WriteString("For each document explain in one or two sentences how it relates to the topic, citing the excerpts as [n]. ")
+	b.WriteString("Point out any contradictions between documents.\n\n")
+	if len(results) == 0 {
+		fmt.Fprintf(&b, "No documents with docType %s matched the topic.\n", strings.Join(specDocTypes, ", "))
+	}
+	n := 0
+	for _, name := range order {
+		fmt.Fprintf(&b, "## %s\n\n", name)
+		for _, r := range byDocument[name] {
+			n++
+			fmt.Fprintf(&b, "[%d] %s (chunk %d)\n%s\n\n", n, chunkLabel(r.HeadingPath, r.Position), r.ChunkID, r.ChunkContent)
+		}
+	}
+
+	return mcp.NewGetPromptResult(
+		fmt.Sprintf("Find specs related to %q in %d documents", topic, len(order)),
+		[]mcp.PromptMessage{mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent(b.String()))},
+	), nil
+}
+
+// promptTopK parses the optional top_k prompt argument
+func promptTopK(request mcp.GetPromptRequest, defaultTopK int) (int, error) {
+	value := strings.TrimSpace(request.Params.Arguments["top_k"])
+	if value == "" {
+		return defaultTopK, nil
+	}
+	topK, err := strconv.Atoi(value)
+	if err != nil || topK <= 0 {
+		return 0, fmt.Errorf("top_k must be a positive integer: %q", value)
+	}
+	return topK, nil
+}
+
+// writeExcerpts writes numbered search results with their citations, using the
+// surrounding context when it was requested
+func writeExcerpts(b *strings.Builder, results []vectordb.SearchResult) {
+	if len(results) == 0 {
+		b.WriteString("No excerpts matched the question.\n")
+		return
+	}
+	for i, r := range results {
+		content := r.ChunkContent
+		if r.Context != "" {
+			content = r.Context
+		}
+		fmt.Fprintf(b, "[%d] %s — %s (chunk %d)\n%s\n\n", i+1, r.DocumentName, chunkLabel(r.HeadingPath, r.Position), r.ChunkID, content)
+	}
+}
+
+// chunkLabel describes where a chunk is within its document
+func chunkLabel(headingPath string, position int) string {
+	if headingPath != "" {
+		return headingPath
+	}
+	return fmt.Sprintf("position %d", position)
+}
@@ -0,0 +1,145 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/tomohiro-owada/devrag/internal/vectordb"
+)
+
+// documentURIPrefix is the URI scheme of indexed documents exposed as resources
+// The rest of the URI is the document path relative to the directory containing
+// all document sources (documents_dir unless sources are configured)
+const documentURIPrefix = "devrag://doc/"
+
+// registerResources exposes every indexed document as an MCP resource and keeps
+// the list up to date as documents are re-indexed or removed
+func (s *MCPServer) registerResources() error {
+	s.server.AddResourceTemplate(
+		mcp.NewResourceTemplate(
+			documentURIPrefix+"{+path}",
+			"Indexed document",
+			mcp.WithTemplateDescription("インデックス済みドキュメント（ドキュメントソースの共通ディレクトリからの相対パス）"),
+			mcp.WithTemplateMIMEType("text/markdown"),
+		),
+		s.handleReadDocument,
+	)
+
+	docs, err := s.db.ListDocuments()
+	if err != nil {
+		return fmt.Errorf("failed to list documents: %w", err)
+	}
+
+	s.resourcesMu.Lock()
+	s.resources = make(map[string]bool)
+	s.resourcesMu.Unlock()
+	for filename := range docs {
+		s.addDocumentResource(filename)
+	}
+
+	s.indexer.OnChange(s.documentChanged)
+
+	fmt.Fprintf(os.Stderr, "[INFO] Registered %d document resources\n", len(docs))
+	return nil
+}
+
+// addDocumentResource adds a document to the resource list unless it is listed,
+// reporting its URI
+func (s *MCPServer) addDocumentResource(filename string) string {
+	uri := s.documentURI(filename)
+
+	s.resourcesMu.Lock()
+	defer s.resourcesMu.Unlock()
+	if s.resources[uri] {
+		return uri
+	}
+	s.resources[uri] = true
+
+	s.server.AddResource(
+		mcp.NewResource(uri, strings.TrimPrefix(uri, documentURIPrefix), mcp.WithMIMEType("text/markdown")),
+		s.handleReadDocument,
+	)
+	return uri
+}
+
+// documentChanged updates the resource list and notifies clients after a
+// document has been re-indexed or removed
+// resources/subscribe is not supported, so the update is broadcast to every
+// client and clients filter it by URI
+func (s *MCPServer) documentChanged(filename string, removed bool) {
+	var uri string
+	if removed {
+		uri = s.documentURI(filename)
+		s.resourcesMu.Lock()
+		delete(s.resources, uri)
+		s.resourcesMu.Unlock()
+		s.server.DeleteResources(uri)
+	} else {
+		uri = s.addDocumentResource(filename)
+	}
+
+	s.server.SendNotificationToAllClients(mcp.MethodNotificationResourceUpdated, map[string]any{"uri": uri})
+}
+
+func (s *MCPServer) handleReadDocument(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	filename, err := s.documentFilename(request.Params.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	s.indexer.RLock()
+	defer s.indexer.RUnlock()
+
+	doc, err := s.db.GetDocument(filename)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", mcp.ErrResourceNotFound, err)
+	}
+
+	chunks := []vectordb.DocumentChunk{}
+	if doc.ChunkCount > 0 {
+		chunks, err = s.db.GetDocumentChunks(filename, 0, doc.ChunkCount-1)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	parts := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		parts[i] = chunk.Content
+	}
+
+	return []mcp.ResourceContents{
+		mcp.TextResourceContents{
+			URI:      request.Params.URI,
+			MIMEType: "text/markdown",
+			Text:     strings.Join(parts, "\n\n"),
+		},
+	}, nil
+}
+
+// documentURI returns the resource URI of an indexed document
+func (s *MCPServer) documentURI(filename string) string {
+	rel, err := filepath.Rel(s.indexer.Sources().Base(), filename)
+	if err != nil || !filepath.IsLocal(rel) {
+		rel = filename
+	}
+	return documentURIPrefix + filepath.ToSlash(rel)
+}
+
+// documentFilename returns the indexed filename of a resource URI
+func (s *MCPServer) documentFilename(uri string) (string, error) {
+	rel, ok := strings.CutPrefix(uri, documentURIPrefix)
+	if !ok || rel == "" {
+		return "", fmt.Errorf("%w: %s", mcp.ErrResourceNotFound, uri)
+	}
+
+	path := filepath.FromSlash(rel)
+	if !filepath.IsLocal(path) {
+		return "", fmt.Errorf("invalid document path: %s", rel)
+	}
+	return filepath.Join(s.indexer.Sources().Base(), path), nil
+}
@@ -1,9 +1,15 @@
 package mcp
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"sync"
+	"time"
 
+	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	"github.com/tomohiro-owada/devrag/internal/config"
 	"github.com/tomohiro-owada/devrag/internal/embedder"
@@ -17,6 +23,10 @@ type MCPServer struct {
 	db       *vectordb.DB
 	embedder embedder.Embedder
 	config   *config.Config
+
+	// resources holds the URIs of documents listed as resources
+	resourcesMu sync.Mutex
+	resources   map[string]bool
 }
 
 // NewMCPServer creates a new MCP server
@@ -29,36 +39,144 @@ func NewMCPServer(idx *indexer.Indexer, db *vectordb.DB, emb embedder.Embedder,
 	}
 }
 
-// Start starts the MCP server
-func (s *MCPServer) Start() error {
+// Start starts the MCP server on the configured transport and blocks until ctx
+// is cancelled or the server fails
+func (s *MCPServer) Start(ctx context.Context) error {
 	fmt.Fprintf(os.Stderr, "[INFO] Starting MCP server...\n")
 
 	// Create MCP server
+	hooks := &server.Hooks{}
+	hooks.AddBeforeAny(streamNotifications)
 	s.server = server.NewMCPServer(
 		"devrag",
 		"1.0.0",
+		server.WithToolHandlerMiddleware(s.serializeTools),
+		server.WithToolHandlerMiddleware(s.auditTools),
+		// Updates are broadcast, as mcp-go does not route resources/subscribe
+		server.WithResourceCapabilities(false, true),
+		server.WithPromptCapabilities(false),
+		server.WithHooks(hooks),
 	)
 
-	// Register tools
+	// Register tools, prompts and resources
 	s.registerTools()
+	s.registerPrompts()
+	if err := s.registerResources(); err != nil {
+		return err
+	}
+
+	var err error
+	switch transport, addr := s.config.Server.Transport, s.config.Server.Address; transport {
+	case config.TransportHTTP:
+		httpServer := server.NewStreamableHTTPServer(s.server)
+		fmt.Fprintf(os.Stderr, "[INFO] Serving MCP over streamable HTTP at http://%s/mcp\n", addr)
+		err = serveHTTP(ctx, func() error { return httpServer.Start(addr) }, httpServer.Shutdown)
+	case config.TransportSSE:
+		sseServer := server.NewSSEServer(s.server, server.WithBaseURL("http://"+addr))
+		fmt.Fprintf(os.Stderr, "[INFO] Serving MCP over SSE at http://%s/sse\n", addr)
+		err = serveHTTP(ctx, func() error { return sseServer.Start(addr) }, sseServer.Shutdown)
+	case config.TransportStdio:
+		err = server.NewStdioServer(s.server).Listen(ctx, os.Stdin, os.Stdout)
+	default:
+		err = fmt.Errorf("unknown transport: %s", transport)
+	}
 
-	// Start server (stdio)
-	if err := server.ServeStdio(s.server); err != nil {
+	if err != nil && !errors.Is(err, context.Canceled) {
 		return fmt.Errorf("MCP server error: %w", err)
 	}
 
 	return nil
 }
 
+// shutdownTimeout bounds how long in-flight HTTP requests may take to finish
+const shutdownTimeout = 5 * time.Second
+
+// serveHTTP runs an HTTP based transport until it fails or ctx is cancelled,
+// then shuts it down gracefully
+func serveHTTP(ctx context.Context, start func() error, shutdown func(context.Context) error) error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- start()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		fmt.Fprintf(os.Stderr, "[INFO] Shutting down MCP server...\n")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := shutdown(shutdownCtx); err != nil {
+			return fmt.Errorf("failed to shut down: %w", err)
+		}
+		if err := <-errCh; err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	}
+}
+
+// streamNotifications makes streamable HTTP sessions answer with an event stream
+// Resource notifications can be sent while any request is in flight, and a plain
+// JSON response cannot carry them. Initialize is answered as JSON because the
+// session ID header is only sent with JSON responses.
+func streamNotifications(ctx context.Context, id any, method mcp.MCPMethod, message any) {
+	if method == mcp.MethodInitialize {
+		return
+	}
+	if session, ok := server.ClientSessionFromContext(ctx).(server.SessionWithStreamableHTTPConfig); ok {
+		session.UpgradeToSSEWhenReceiveNotification()
+	}
+}
+
+// readOnlyTools lists tools that do not modify the index
+// Every other tool is treated as mutating and recorded in the audit log
+var readOnlyTools = map[string]bool{
+	"search":          true,
+	"list_documents":  true,
+	"check_index":     true,
+	"query_audit_log": true,
+	"get_document":    true,
+	"get_chunk":       true,
+}
+
+// serializeTools runs tool calls under the indexer lock so that they do not
+// interleave with each other or with the filesystem watcher
+// Read-only tools share the lock; mutating tools hold it exclusively
+func (s *MCPServer) serializeTools(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if readOnlyTools[request.Params.Name] {
+			s.indexer.RLock()
+			defer s.indexer.RUnlock()
+		} else {
+			s.indexer.Lock()
+			defer s.indexer.Unlock()
+		}
+		return next(ctx, request)
+	}
+}
+
 // registerTools registers all MCP tools
+// In read-only mode only the tools listed in readOnlyTools are registered
 func (s *MCPServer) registerTools() {
 	s.registerSearchTool()
-	s.registerIndexMarkdownTool()
 	s.registerListDocumentsTool()
+	s.registerCheckIndexTool()
+	s.registerQueryAuditLogTool()
+	s.registerGetDocumentTool()
+	s.registerGetChunkTool()
+
+	if s.config.ReadOnly {
+		fmt.Fprintf(os.Stderr, "[INFO] Registered %d read-only MCP tools\n", len(s.server.ListTools()))
+		return
+	}
+
+	s.registerIndexMarkdownTool()
 	s.registerDeleteDocumentTool()
 	s.registerReindexDocumentTool()
 	s.registerAddFrontmatterTool()
 	s.registerUpdateFrontmatterTool()
+	s.registerRepairIndexTool()
 
-	fmt.Fprintf(os.Stderr, "[INFO] Registered 7 MCP tools\n")
+	fmt.Fprintf(os.Stderr, "[INFO] Registered %d MCP tools\n", len(s.server.ListTools()))
 }
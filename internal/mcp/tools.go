@@ -2,13 +2,17 @@ package mcp
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/tomohiro-owada/devrag/internal/frontmatter"
+	"github.com/tomohiro-owada/devrag/internal/indexer"
+	"github.com/tomohiro-owada/devrag/internal/vectordb"
 )
 
 // Tool 1: search
@@ -23,6 +27,17 @@ func (s *MCPServer) registerSearchTool() {
 		mcp.WithNumber("top_k",
 			mcp.Description("検索結果の最大件数"),
 		),
+		mcp.WithString("mode",
+			mcp.Description("検索モード: vector（意味検索） | keyword（BM25キーワード検索） | hybrid（両方を統合）"),
+			mcp.Enum(string(vectordb.ModeVector), string(vectordb.ModeKeyword), string(vectordb.ModeHybrid)),
+		),
+		mcp.WithArray("filters",
+			mcp.Description("frontmatterによる絞り込み（AND条件）: \"domain=backend\", \"docType in (spec, api)\", \"tags contains auth\""),
+			mcp.WithStringItems(),
+		),
+		mcp.WithNumber("context_window",
+			mcp.Description("ヒットしたチャンクの前後に含めるチャンク数（同じドキュメント内で重なる範囲は1つにまとめる）"),
+		),
 	)
 
 	s.server.AddTool(tool, s.handleSearch)
@@ -36,18 +51,37 @@ func (s *MCPServer) handleSearch(ctx context.Context, request mcp.CallToolReques
 
 	topK := request.GetInt("top_k", s.config.SearchTopK)
 
-	fmt.Fprintf(os.Stderr, "[INFO] Search query: %s (top_k=%d)\n", query, topK)
+	contextWindow := request.GetInt("context_window", 0)
+	if contextWindow < 0 {
+		return mcp.NewToolResultError("context_window must not be negative"), nil
+	}
 
-	// Vectorize query
-	queryVector, err := s.embedder.Embed(query)
+	mode, err := vectordb.ParseSearchMode(request.GetString("mode", ""))
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("failed to vectorize query: %v", err)), nil
+		return mcp.NewToolResultError(err.Error()), nil
 	}
 
-	// Search
-	results, err := s.db.Search(queryVector, topK)
+	// Parse metadata filters
+	var filters []vectordb.Filter
+	for _, expr := range request.GetStringSlice("filters", nil) {
+		filter, err := vectordb.ParseFilter(expr)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		filters = append(filters, filter)
+	}
+
+	fmt.Fprintf(os.Stderr, "[INFO] Search query: %s (mode=%s, top_k=%d, filters=%d)\n", query, mode, topK, len(filters))
+
+	results, err := s.indexer.Search(vectordb.SearchQuery{
+		Text:          query,
+		TopK:          topK,
+		Mode:          mode,
+		Filters:       filters,
+		ContextWindow: contextWindow,
+	})
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("search failed: %v", err)), nil
+		return mcp.NewToolResultError(err.Error()), nil
 	}
 
 	fmt.Fprintf(os.Stderr, "[INFO] Found %d results\n", len(results))
@@ -78,19 +112,25 @@ func (s *MCPServer) handleIndexMarkdown(ctx context.Context, request mcp.CallToo
 		return mcp.NewToolResultError("filepath is required"), nil
 	}
 
-	// Validate path (prevent path traversal)
-	if err := validatePath(filePath, s.config.DocumentsDir); err != nil {
+	// Validate path (prevent path traversal and files outside the sources)
+	filePath, err := s.documentPath(filePath)
+	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("invalid path: %v", err)), nil
 	}
+	auditEntryFromContext(ctx).Document = filePath
 
 	// Index file
-	if err := s.indexer.IndexFile(filePath); err != nil {
+	result, err := s.indexer.IndexFile(filePath)
+	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("indexing failed: %v", err)), nil
 	}
 
 	return mcp.NewToolResultJSON(map[string]interface{}{
-		"success": true,
-		"message": "File indexed successfully",
+		"success":  true,
+		"message":  "File indexed successfully",
+		"chunks":   result.Chunks,
+		"reused":   result.Reused,
+		"embedded": result.Embedded,
 	})
 }
 
@@ -131,7 +171,7 @@ func (s *MCPServer) registerDeleteDocumentTool() {
 		mcp.WithDescription("ドキュメントをDBとファイルシステムの両方から削除"),
 		mcp.WithString("filename",
 			mcp.Required(),
-			mcp.Description("削除するファイル名"),
+			mcp.Description("削除するファイル名（list_documentsやsearchが返す名前、またはドキュメントディレクトリからの相対パス）"),
 		),
 	)
 
@@ -144,13 +184,23 @@ func (s *MCPServer) handleDeleteDocument(ctx context.Context, request mcp.CallTo
 		return mcp.NewToolResultError("filename is required"), nil
 	}
 
-	// Delete from database
-	if err := s.db.DeleteDocument(filename); err != nil {
+	// Validate path (prevent path traversal and files outside the sources)
+	filePath, err := s.indexedDocumentPath(filename)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("invalid path: %v", err)), nil
+	}
+
+	// Record what is deleted so that the file can be traced in the audit log
+	entry := auditEntryFromContext(ctx)
+	entry.Document = filePath
+	entry.ContentHash, _ = indexer.HashFile(filePath)
+
+	// Delete from database (documents are indexed by their path, as in reindex_document)
+	if err := s.indexer.DeleteDocument(filePath); err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("failed to delete from database: %v", err)), nil
 	}
 
 	// Delete file
-	filePath := filepath.Join(s.config.DocumentsDir, filename)
 	if err := os.Remove(filePath); err != nil {
 		fmt.Fprintf(os.Stderr, "[WARN] Failed to delete file: %v\n", err)
 	}
@@ -168,7 +218,7 @@ func (s *MCPServer) registerReindexDocumentTool() {
 		mcp.WithDescription("ドキュメントを削除して再インデックス化"),
 		mcp.WithString("filename",
 			mcp.Required(),
-			mcp.Description("再インデックス化するファイル名"),
+			mcp.Description("再インデックス化するファイル名（list_documentsやsearchが返す名前、またはドキュメントディレクトリからの相対パス）"),
 		),
 	)
 
@@ -181,20 +231,29 @@ func (s *MCPServer) handleReindexDocument(ctx context.Context, request mcp.CallT
 		return mcp.NewToolResultError("filename is required"), nil
 	}
 
-	// Delete from database
-	if err := s.db.DeleteDocument(filename); err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("failed to delete document: %v", err)), nil
+	// Validate path (prevent path traversal and files outside the sources)
+	filePath, err := s.indexedDocumentPath(filename)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("invalid path: %v", err)), nil
 	}
 
-	// Reindex
-	filePath := filepath.Join(s.config.DocumentsDir, filename)
-	if err := s.indexer.IndexFile(filePath); err != nil {
+	// Record the content that is indexed
+	entry := auditEntryFromContext(ctx)
+	entry.Document = filePath
+	entry.ContentHash, _ = indexer.HashFile(filePath)
+
+	// Reindex (the stored version is replaced and vectors of unchanged chunks are reused)
+	result, err := s.indexer.IndexFile(filePath)
+	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("failed to reindex: %v", err)), nil
 	}
 
 	return mcp.NewToolResultJSON(map[string]interface{}{
-		"success": true,
-		"message": "Document reindexed successfully",
+		"success":  true,
+		"message":  "Document reindexed successfully",
+		"chunks":   result.Chunks,
+		"reused":   result.Reused,
+		"embedded": result.Embedded,
 	})
 }
 
@@ -234,7 +293,8 @@ func (s *MCPServer) handleAddFrontmatter(ctx context.Context, request mcp.CallTo
 	}
 
 	// Validate path
-	if err := validatePath(filePath, s.config.DocumentsDir); err != nil {
+	filePath, err := s.documentPath(filePath)
+	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("invalid path: %v", err)), nil
 	}
 
@@ -260,9 +320,13 @@ func (s *MCPServer) handleAddFrontmatter(ctx context.Context, request mcp.CallTo
 	}
 
 	// Add frontmatter
+	entry := auditEntryFromContext(ctx)
+	entry.Document = filePath
+	entry.Before = frontmatterJSON(filePath)
 	if err := frontmatter.AddFrontmatter(filePath, metadata); err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("failed to add frontmatter: %v", err)), nil
 	}
+	entry.After = frontmatterJSON(filePath)
 
 	return mcp.NewToolResultJSON(map[string]interface{}{
 		"success": true,
@@ -306,7 +370,8 @@ func (s *MCPServer) handleUpdateFrontmatter(ctx context.Context, request mcp.Cal
 	}
 
 	// Validate path
-	if err := validatePath(filePath, s.config.DocumentsDir); err != nil {
+	filePath, err := s.documentPath(filePath)
+	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("invalid path: %v", err)), nil
 	}
 
@@ -332,9 +397,13 @@ func (s *MCPServer) handleUpdateFrontmatter(ctx context.Context, request mcp.Cal
 	}
 
 	// Update frontmatter
+	entry := auditEntryFromContext(ctx)
+	entry.Document = filePath
+	entry.Before = frontmatterJSON(filePath)
 	if err := frontmatter.UpdateFrontmatter(filePath, metadata); err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("failed to update frontmatter: %v", err)), nil
 	}
+	entry.After = frontmatterJSON(filePath)
 
 	return mcp.NewToolResultJSON(map[string]interface{}{
 		"success": true,
@@ -342,27 +411,258 @@ func (s *MCPServer) handleUpdateFrontmatter(ctx context.Context, request mcp.Cal
 	})
 }
 
-// validatePath prevents path traversal attacks
-func validatePath(filePath, baseDir string) error {
-	absPath, err := filepath.Abs(filePath)
+// Tool 8: check_index
+func (s *MCPServer) registerCheckIndexTool() {
+	tool := mcp.NewTool(
+		"check_index",
+		mcp.WithDescription("インデックスの整合性を検査（孤立したベクトル・チャンク、ベクトルのないチャンク、チャンクのないドキュメント）"),
+	)
+
+	s.server.AddTool(tool, s.handleCheckIndex)
+}
+
+func (s *MCPServer) handleCheckIndex(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	report, err := s.db.CheckIntegrity()
 	if err != nil {
-		return err
+		return mcp.NewToolResultError(fmt.Sprintf("integrity check failed: %v", err)), nil
+	}
+
+	return mcp.NewToolResultJSON(integrityReportJSON(report))
+}
+
+// Tool 9: repair_index
+func (s *MCPServer) registerRepairIndexTool() {
+	tool := mcp.NewTool(
+		"repair_index",
+		mcp.WithDescription("インデックスの不整合を修復し、影響を受けたドキュメントを再インデックス化"),
+	)
+
+	s.server.AddTool(tool, s.handleRepairIndex)
+}
+
+func (s *MCPServer) handleRepairIndex(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	report, reindexed, err := s.indexer.RepairIndex()
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("repair failed: %v", err)), nil
+	}
+
+	result := integrityReportJSON(report)
+	result["success"] = true
+	result["reindexed"] = reindexed
+	return mcp.NewToolResultJSON(result)
+}
+
+// Tool 10: query_audit_log
+func (s *MCPServer) registerQueryAuditLogTool() {
+	tool := mcp.NewTool(
+		"query_audit_log",
+		mcp.WithDescription("ファイルやインデックスを変更したツール呼び出しの監査ログを取得"),
+		mcp.WithString("tool",
+			mcp.Description("ツール名で絞り込み（例: delete_document）"),
+		),
+		mcp.WithString("document",
+			mcp.Description("対象ファイルで絞り込み"),
+		),
+		mcp.WithString("session",
+			mcp.Description("呼び出し元セッションIDで絞り込み"),
+		),
+		mcp.WithString("since",
+			mcp.Description("この日時以降の記録のみ（RFC3339形式、例: 2025-01-01T00:00:00Z）"),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("返す最新の記録数（デフォルト: 50）"),
+		),
+	)
+
+	s.server.AddTool(tool, s.handleQueryAuditLog)
+}
+
+func (s *MCPServer) handleQueryAuditLog(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	query := vectordb.AuditQuery{
+		Tool:     request.GetString("tool", ""),
+		Document: request.GetString("document", ""),
+		Session:  request.GetString("session", ""),
+		Limit:    request.GetInt("limit", 50),
+	}
+	if since := request.GetString("since", ""); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("invalid since: %v", err)), nil
+		}
+		query.Since = t
+	}
+
+	entries, err := s.db.QueryAudit(query)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("failed to query audit log: %v", err)), nil
+	}
+
+	return mcp.NewToolResultJSON(map[string]interface{}{
+		"entries": entries,
+	})
+}
+
+// Tool 11: get_document
+func (s *MCPServer) registerGetDocumentTool() {
+	tool := mcp.NewTool(
+		"get_document",
+		mcp.WithDescription("インデックス済みドキュメントのメタデータとチャンクをDBから取得（ファイルを直接読むより省トークン）"),
+		mcp.WithString("filename",
+			mcp.Required(),
+			mcp.Description("ドキュメント名（search結果のDocumentNameまたはlist_documentsのfilename）"),
+		),
+		mcp.WithNumber("start_position",
+			mcp.Description("取得する最初のチャンク位置（デフォルト: 0）"),
+		),
+		mcp.WithNumber("end_position",
+			mcp.Description("取得する最後のチャンク位置（デフォルト: 最後のチャンク）"),
+		),
+	)
+
+	s.server.AddTool(tool, s.handleGetDocument)
+}
+
+func (s *MCPServer) handleGetDocument(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	filename := request.GetString("filename", "")
+	if filename == "" {
+		return mcp.NewToolResultError("filename is required"), nil
 	}
 
-	absBase, err := filepath.Abs(baseDir)
+	doc, err := s.db.GetDocument(filename)
 	if err != nil {
-		return err
+		return mcp.NewToolResultError(fmt.Sprintf("failed to get document: %v", err)), nil
+	}
+
+	chunks := []vectordb.DocumentChunk{}
+	if doc.ChunkCount > 0 {
+		start := request.GetInt("start_position", 0)
+		end := request.GetInt("end_position", doc.ChunkCount-1)
+		chunks, err = s.db.GetDocumentChunks(filename, start, end)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("failed to get chunks: %v", err)), nil
+		}
 	}
 
-	relPath, err := filepath.Rel(absBase, absPath)
+	var metadata map[string]interface{}
+	if doc.Metadata != nil {
+		metadata = map[string]interface{}{
+			"domain":   doc.Metadata.Domain,
+			"docType":  doc.Metadata.DocType,
+			"language": doc.Metadata.Language,
+			"project":  doc.Metadata.Project,
+			"tags":     doc.Metadata.Tags,
+		}
+	}
+
+	chunkList := []map[string]interface{}{}
+	for _, chunk := range chunks {
+		chunkList = append(chunkList, chunkJSON(chunk))
+	}
+
+	return mcp.NewToolResultJSON(map[string]interface{}{
+		"filename":     doc.Filename,
+		"modified_at":  doc.ModifiedAt.Format("2006-01-02T15:04:05Z"),
+		"indexed_at":   doc.IndexedAt.Format("2006-01-02T15:04:05Z"),
+		"content_hash": doc.ContentHash,
+		"metadata":     metadata,
+		"chunk_count":  doc.ChunkCount,
+		"chunks":       chunkList,
+	})
+}
+
+// Tool 12: get_chunk
+func (s *MCPServer) registerGetChunkTool() {
+	tool := mcp.NewTool(
+		"get_chunk",
+		mcp.WithDescription("チャンクをIDまたはドキュメント名と位置でDBから取得"),
+		mcp.WithNumber("chunk_id",
+			mcp.Description("チャンクID（search結果のChunkID）"),
+		),
+		mcp.WithString("filename",
+			mcp.Description("ドキュメント名（chunk_idを指定しない場合）"),
+		),
+		mcp.WithNumber("position",
+			mcp.Description("ドキュメント内のチャンク位置（chunk_idを指定しない場合）"),
+		),
+	)
+
+	s.server.AddTool(tool, s.handleGetChunk)
+}
+
+func (s *MCPServer) handleGetChunk(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if id := request.GetInt("chunk_id", 0); id > 0 {
+		chunk, err := s.db.GetChunk(int64(id))
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("failed to get chunk: %v", err)), nil
+		}
+		return mcp.NewToolResultJSON(chunkJSON(*chunk))
+	}
+
+	filename := request.GetString("filename", "")
+	position := request.GetInt("position", -1)
+	if filename == "" || position < 0 {
+		return mcp.NewToolResultError("chunk_id, or filename and position, are required"), nil
+	}
+
+	chunks, err := s.db.GetDocumentChunks(filename, position, position)
 	if err != nil {
-		return err
+		return mcp.NewToolResultError(fmt.Sprintf("failed to get chunk: %v", err)), nil
+	}
+	if len(chunks) == 0 {
+		return mcp.NewToolResultError(fmt.Sprintf("chunk not found: %s at position %d", filename, position)), nil
+	}
+	return mcp.NewToolResultJSON(chunkJSON(chunks[0]))
+}
+
+// chunkJSON formats a stored chunk for tool results
+func chunkJSON(chunk vectordb.DocumentChunk) map[string]interface{} {
+	return map[string]interface{}{
+		"chunk_id":     chunk.ID,
+		"document":     chunk.Document,
+		"position":     chunk.Position,
+		"heading_path": chunk.HeadingPath,
+		"content":      chunk.Content,
+	}
+}
+
+// integrityReportJSON formats an integrity report for tool results
+func integrityReportJSON(report *vectordb.IntegrityReport) map[string]interface{} {
+	chunks := []map[string]interface{}{}
+	for _, chunk := range report.ChunksWithoutVectors {
+		chunks = append(chunks, map[string]interface{}{
+			"chunk_id": chunk.ID,
+			"document": chunk.Document,
+		})
 	}
 
-	// Check if path escapes base directory
-	if len(relPath) > 0 && relPath[0] == '.' {
-		return fmt.Errorf("path traversal detected: %s", filePath)
+	return map[string]interface{}{
+		"ok":                       report.OK(),
+		"orphan_vectors":           report.OrphanVectors,
+		"orphan_chunks":            report.OrphanChunks,
+		"chunks_without_vectors":   chunks,
+		"documents_without_chunks": report.DocumentsWithoutChunks,
 	}
+}
 
-	return nil
+// documentPath checks that a file belongs to a document source and returns it
+// in the form Sync indexes it
+func (s *MCPServer) documentPath(filePath string) (string, error) {
+	path, ok := s.indexer.Sources().Locate(filePath)
+	if !ok {
+		return "", fmt.Errorf("%s is outside the document sources", filePath)
+	}
+	if !s.indexer.Sources().Match(path) {
+		return "", fmt.Errorf("%s is excluded from the document sources", filePath)
+	}
+	return path, nil
+}
+
+// indexedDocumentPath resolves a document name as list_documents and search
+// return it, or else relative to the directory containing the sources, and
+// checks that it belongs to a document source
+func (s *MCPServer) indexedDocumentPath(filename string) (string, error) {
+	if _, err := s.db.GetDocument(filename); errors.Is(err, vectordb.ErrDocumentNotFound) {
+		filename = filepath.Join(s.indexer.Sources().Base(), filename)
+	}
+	return s.documentPath(filename)
 }
@@ -0,0 +1,175 @@
+package sources
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// IgnoreFiles are read in every directory of a source, with .gitignore syntax
+var IgnoreFiles = []string{".gitignore", ".devragignore"}
+
+// ValidPattern reports whether a glob pattern is well formed
+// Patterns are matched segment by segment with path.Match, and "**" matches any
+// number of directories
+func ValidPattern(pattern string) bool {
+	if pattern == "" {
+		return false
+	}
+	for _, segment := range strings.Split(pattern, "/") {
+		if _, err := path.Match(segment, ""); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// matchAny reports whether a slash-separated path matches one of the patterns
+func matchAny(patterns []string, rel string) bool {
+	name := strings.Split(rel, "/")
+	for _, pattern := range patterns {
+		if matchSegments(strings.Split(pattern, "/"), name) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSegments matches path segments against pattern segments, where "**"
+// matches any number of segments, including none
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			pattern = pattern[1:]
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// ignoreRule is a line of an ignore file
+type ignoreRule struct {
+	base     string   // directory of the ignore file, relative to the source root
+	segments []string // pattern split at "/"
+	negate   bool     // "!pattern" re-includes a path
+	dirOnly  bool     // "pattern/" matches directories only
+	anchored bool     // patterns containing "/" match relative to base, others match names
+}
+
+// ignoreRules are the rules that apply in a directory, parents' rules first
+type ignoreRules []ignoreRule
+
+// ignored reports whether the last rule matching a path ignores it
+func (r ignoreRules) ignored(rel string, isDir bool) bool {
+	ignored := false
+	for _, rule := range r {
+		if rule.match(rel, isDir) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+func (r ignoreRule) match(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.base != "." {
+		var ok bool
+		if rel, ok = strings.CutPrefix(rel, r.base+"/"); !ok {
+			return false
+		}
+	}
+	if !r.anchored {
+		return matchSegments(r.segments, []string{path.Base(rel)})
+	}
+	return matchSegments(r.segments, strings.Split(rel, "/"))
+}
+
+// loadIgnoreFiles adds the rules of the ignore files in dir to those of its parent
+func loadIgnoreFiles(dir, rel string, parent ignoreRules) ignoreRules {
+	rules := parent
+	for _, name := range IgnoreFiles {
+		lines, err := readLines(filepath.Join(dir, name))
+		if err != nil {
+			if !os.IsNotExist(err) {
+				fmt.Fprintf(os.Stderr, "[WARN] Failed to read %s: %v\n", filepath.Join(dir, name), err)
+			}
+			continue
+		}
+		for _, line := range lines {
+			if rule, ok := parseIgnoreRule(line, rel); ok {
+				// Copy so that sibling directories do not share appended rules
+				rules = append(rules[:len(rules):len(rules)], rule)
+			}
+		}
+	}
+	return rules
+}
+
+// parseIgnoreRule parses a line of an ignore file in the directory base
+func parseIgnoreRule(line, base string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	rule := ignoreRule{base: base}
+	if rest, ok := strings.CutPrefix(line, "!"); ok {
+		rule.negate = true
+		line = rest
+	} else if strings.HasPrefix(line, `\#`) || strings.HasPrefix(line, `\!`) {
+		line = line[1:]
+	}
+	if rest, ok := strings.CutSuffix(line, "/"); ok {
+		rule.dirOnly = true
+		line = rest
+	}
+	if rest, ok := strings.CutPrefix(line, "/"); ok {
+		rule.anchored = true
+		line = rest
+	}
+	if strings.Contains(line, "/") {
+		rule.anchored = true
+	}
+	if !ValidPattern(line) {
+		return ignoreRule{}, false
+	}
+	rule.segments = strings.Split(line, "/")
+	return rule, true
+}
+
+// readLines reads a text file line by line
+func readLines(name string) ([]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
@@ -0,0 +1,260 @@
+package sources
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultInclude selects the files of a source without include patterns
+var DefaultInclude = []string{"**/*.md"}
+
+// Source is a directory tree of documents to index, as configured in sources
+type Source struct {
+	Path    string   `json:"path"`
+	Include []string `json:"include,omitempty"` // globs relative to path; all markdown files if empty
+	Exclude []string `json:"exclude,omitempty"` // globs relative to path
+}
+
+// Set selects the documents to index from the configured sources
+// A file belongs to a source if it is below the source root, matches one of its
+// include patterns, matches none of its exclude patterns, and is not ignored by
+// a .gitignore or .devragignore file between the root and the file
+type Set struct {
+	sources []*source
+	base    string
+}
+
+// source is a compiled Source
+type source struct {
+	root    string // cleaned root path; indexed filenames start with it
+	absRoot string
+	include []string
+	exclude []string
+}
+
+// WalkFunc is called by Walk for every directory that is not excluded and every
+// matching file
+type WalkFunc func(path string, info os.FileInfo) error
+
+// New compiles the sources of a configuration
+// Patterns are checked with ValidPattern by config.Validate; malformed patterns never match
+func New(specs []Source) *Set {
+	s := &Set{}
+	for _, spec := range specs {
+		root := filepath.Clean(spec.Path)
+		absRoot, err := filepath.Abs(root)
+		if err != nil {
+			absRoot = root
+		}
+		include := spec.Include
+		if len(include) == 0 {
+			include = DefaultInclude
+		}
+		s.sources = append(s.sources, &source{
+			root:    root,
+			absRoot: absRoot,
+			include: include,
+			exclude: spec.Exclude,
+		})
+	}
+	s.base = s.commonRoot()
+	return s
+}
+
+// Roots returns the root directories of the sources
+func (s *Set) Roots() []string {
+	roots := make([]string, len(s.sources))
+	for i, src := range s.sources {
+		roots[i] = src.root
+	}
+	return roots
+}
+
+// Base returns the deepest directory containing every source root
+// Document names relative to it are unique across sources
+func (s *Set) Base() string {
+	return s.base
+}
+
+// commonRoot finds the deepest directory containing every source root, relative
+// unless one of the roots is absolute
+func (s *Set) commonRoot() string {
+	if len(s.sources) == 0 {
+		return "."
+	}
+	if len(s.sources) == 1 {
+		return s.sources[0].root
+	}
+
+	base := s.sources[0].absRoot
+	for _, src := range s.sources[1:] {
+		for !within(base, src.absRoot) {
+			parent := filepath.Dir(base)
+			if parent == base {
+				break
+			}
+			base = parent
+		}
+	}
+
+	for _, src := range s.sources {
+		if filepath.IsAbs(src.root) {
+			return base
+		}
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return base
+	}
+	if rel, err := filepath.Rel(wd, base); err == nil {
+		return rel
+	}
+	return base
+}
+
+// Locate returns path in the form its source indexes it, the source root joined
+// with the path relative to it. ok is false if path is below no source root.
+func (s *Set) Locate(p string) (string, bool) {
+	for _, src := range s.sources {
+		if rel, ok := src.rel(p); ok {
+			return filepath.Join(src.root, filepath.FromSlash(rel)), true
+		}
+	}
+	return "", false
+}
+
+// Match reports whether a file belongs to one of the sources
+func (s *Set) Match(p string) bool {
+	for _, src := range s.sources {
+		rel, ok := src.rel(p)
+		if !ok || rel == "." {
+			continue
+		}
+		rules, ok := src.rulesFor(path.Dir(rel))
+		if ok && src.matchFile(rel, rules) {
+			return true
+		}
+	}
+	return false
+}
+
+// Walk calls fn for the directories and matching files of every source below
+// dir, or of all sources if dir is empty. Files are visited once even if
+// sources overlap. Errors accessing a path are logged and skipped.
+func (s *Set) Walk(dir string, fn WalkFunc) error {
+	seen := make(map[string]bool)
+	for _, src := range s.sources {
+		start := src.root
+		if dir != "" {
+			rel, ok := src.rel(dir)
+			switch {
+			case ok:
+				start = filepath.Join(src.root, filepath.FromSlash(rel))
+			case !within(absPath(dir), src.absRoot):
+				continue
+			}
+		}
+		if err := src.walk(start, seen, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walk visits the directories and matching files below start, a directory
+// below the source root
+func (src *source) walk(start string, seen map[string]bool, fn WalkFunc) error {
+	// Below the root, start with the rules of the directories above start
+	var parentRules ignoreRules
+	if startRel, _ := src.rel(start); startRel != "." {
+		var ok bool
+		parentRules, ok = src.rulesFor(path.Dir(startRel))
+		if !ok || src.skipDir(startRel, parentRules) {
+			return nil
+		}
+	}
+
+	rules := map[string]ignoreRules{}
+	return filepath.Walk(start, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[WARN] Error accessing %s: %v\n", p, err)
+			return nil // Continue walking despite errors
+		}
+
+		rel, _ := src.rel(p)
+		parent, ok := rules[filepath.Dir(p)]
+		if !ok {
+			parent = parentRules
+		}
+
+		if info.IsDir() {
+			if p != start && src.skipDir(rel, parent) {
+				return filepath.SkipDir
+			}
+			rules[p] = loadIgnoreFiles(p, rel, parent)
+			return fn(p, info)
+		}
+
+		if seen[p] || !src.matchFile(rel, parent) {
+			return nil
+		}
+		seen[p] = true
+		return fn(p, info)
+	})
+}
+
+// rel returns the slash-separated path of p relative to the source root
+func (src *source) rel(p string) (string, bool) {
+	rel, err := filepath.Rel(src.absRoot, absPath(p))
+	if err != nil || (rel != "." && !filepath.IsLocal(rel)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// rulesFor loads the ignore files from the source root down to dir, a
+// slash-separated path relative to the root. ok is false if dir or one of
+// its parents is excluded.
+func (src *source) rulesFor(dir string) (ignoreRules, bool) {
+	rules := loadIgnoreFiles(src.root, ".", nil)
+	if dir == "." {
+		return rules, true
+	}
+
+	current := ""
+	for _, name := range strings.Split(dir, "/") {
+		current = path.Join(current, name)
+		if src.skipDir(current, rules) {
+			return nil, false
+		}
+		rules = loadIgnoreFiles(filepath.Join(src.root, filepath.FromSlash(current)), current, rules)
+	}
+	return rules, true
+}
+
+// skipDir reports whether a directory is excluded with everything below it
+func (src *source) skipDir(rel string, rules ignoreRules) bool {
+	return path.Base(rel) == ".git" || rules.ignored(rel, true) || matchAny(src.exclude, rel)
+}
+
+// matchFile reports whether a file in a directory that is not excluded belongs to the source
+func (src *source) matchFile(rel string, rules ignoreRules) bool {
+	return matchAny(src.include, rel) && !matchAny(src.exclude, rel) && !rules.ignored(rel, false)
+}
+
+// within reports whether p is dir or below it
+func within(dir, p string) bool {
+	rel, err := filepath.Rel(dir, p)
+	return err == nil && (rel == "." || filepath.IsLocal(rel))
+}
+
+// absPath returns the absolute form of p, or p if it cannot be determined
+func absPath(p string) string {
+	if abs, err := filepath.Abs(p); err == nil {
+		return abs
+	}
+	return p
+}
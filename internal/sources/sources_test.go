@@ -0,0 +1,204 @@
+package sources
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// writeFiles creates files below dir, creating their directories
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// walkFiles returns the files visited by Walk relative to dir, sorted
+func walkFiles(t *testing.T, set *Set, dir, start string) []string {
+	t.Helper()
+	files := []string{}
+	err := set.Walk(start, func(path string, info os.FileInfo) error {
+		if !info.IsDir() {
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	return files
+}
+
+func TestMatchSegments(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"**/*.md", "a.md", true},
+		{"**/*.md", "docs/sub/a.md", true},
+		{"**/*.md", "docs/a.txt", false},
+		{"*.md", "docs/a.md", false},
+		{"*/README.md", "service/README.md", true},
+		{"*/README.md", "service/sub/README.md", false},
+		{"docs/**", "docs", true},
+		{"docs/**", "docs/a/b.md", true},
+		{"**/node_modules/**", "web/node_modules/pkg/README.md", true},
+		{"adr/*-[0-9][0-9].md", "adr/decision-01.md", true},
+	}
+	for _, tt := range tests {
+		if got := matchAny([]string{tt.pattern}, tt.path); got != tt.want {
+			t.Errorf("match(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestValidPattern(t *testing.T) {
+	for pattern, want := range map[string]bool{
+		"**/*.md":      true,
+		"docs/[a-z]*":  true,
+		"":             false,
+		"docs/[a-z.md": false,
+		"**/\\":        false,
+	} {
+		if got := ValidPattern(pattern); got != want {
+			t.Errorf("ValidPattern(%q) = %v, want %v", pattern, got, want)
+		}
+	}
+}
+
+func TestWalk_IncludeExclude(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"docs/guide.md":                    "",
+		"docs/notes.txt":                   "",
+		"adr/001.md":                       "",
+		"api/README.md":                    "",
+		"api/internal/README.md":           "",
+		"web/README.md":                    "",
+		"web/node_modules/pkg/README.md":   "",
+		"web/node_modules/pkg/CHANGES.md":  "",
+		"CHANGELOG.md":                     "",
+		"docs/drafts/unfinished.md":        "",
+		"docs/drafts/nested/unfinished.md": "",
+	})
+
+	set := New([]Source{
+		{Path: filepath.Join(dir, "docs"), Exclude: []string{"drafts/**"}},
+		{Path: filepath.Join(dir, "adr")},
+		{Path: dir, Include: []string{"*/README.md"}, Exclude: []string{"**/node_modules/**"}},
+	})
+
+	want := []string{"adr/001.md", "api/README.md", "docs/guide.md", "web/README.md"}
+	if got := walkFiles(t, set, dir, ""); !reflect.DeepEqual(got, want) {
+		t.Errorf("Walk visited %v, want %v", got, want)
+	}
+
+	for _, name := range want {
+		if !set.Match(filepath.Join(dir, name)) {
+			t.Errorf("Expected %s to match", name)
+		}
+	}
+	for _, name := range []string{"docs/notes.txt", "docs/drafts/unfinished.md", "web/node_modules/pkg/README.md", "CHANGELOG.md"} {
+		if set.Match(filepath.Join(dir, name)) {
+			t.Errorf("Expected %s not to match", name)
+		}
+	}
+
+	if base := set.Base(); base != dir {
+		t.Errorf("Expected base %s, got %s", dir, base)
+	}
+}
+
+func TestWalk_IgnoreFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		".gitignore":            "# build output\nbuild/\n*.generated.md\n!keep.generated.md\n",
+		"guide.md":              "",
+		"build/out.md":          "",
+		"api.generated.md":      "",
+		"keep.generated.md":     "",
+		"sub/.devragignore":     "/private.md\n",
+		"sub/private.md":        "",
+		"sub/public.md":         "",
+		"sub/deeper/private.md": "",
+		".git/description.md":   "",
+	})
+
+	set := New([]Source{{Path: dir}})
+
+	want := []string{"guide.md", "keep.generated.md", "sub/deeper/private.md", "sub/public.md"}
+	if got := walkFiles(t, set, dir, ""); !reflect.DeepEqual(got, want) {
+		t.Errorf("Walk visited %v, want %v", got, want)
+	}
+
+	// Walking a subdirectory applies the ignore files above it
+	if got := walkFiles(t, set, dir, filepath.Join(dir, "sub")); !reflect.DeepEqual(got, want[2:]) {
+		t.Errorf("Walk of sub visited %v, want %v", got, want[2:])
+	}
+	if got := walkFiles(t, set, dir, filepath.Join(dir, "build")); len(got) != 0 {
+		t.Errorf("Expected an ignored directory to be skipped, got %v", got)
+	}
+
+	for _, name := range []string{"build/out.md", "api.generated.md", "sub/private.md"} {
+		if set.Match(filepath.Join(dir, name)) {
+			t.Errorf("Expected %s to be ignored", name)
+		}
+	}
+}
+
+func TestWalk_OverlappingSources(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"docs/a.md": "", "b.md": ""})
+
+	set := New([]Source{{Path: dir}, {Path: filepath.Join(dir, "docs")}})
+
+	want := []string{"b.md", "docs/a.md"}
+	if got := walkFiles(t, set, dir, ""); !reflect.DeepEqual(got, want) {
+		t.Errorf("Walk visited %v, want %v", got, want)
+	}
+}
+
+func TestLocate(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := New([]Source{{Path: "./docs"}, {Path: "adr"}})
+
+	tests := []struct {
+		path string
+		want string
+		ok   bool
+	}{
+		{"docs/guide.md", "docs/guide.md", true},
+		{filepath.Join(wd, "adr", "001.md"), "adr/001.md", true},
+		{"./docs/sub/../guide.md", "docs/guide.md", true},
+		{"other/guide.md", "", false},
+		{"docs/../../guide.md", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := set.Locate(tt.path)
+		if ok != tt.ok || got != filepath.FromSlash(tt.want) {
+			t.Errorf("Locate(%q) = %q, %v, want %q, %v", tt.path, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	if base := set.Base(); base != "." {
+		t.Errorf("Expected base . for docs and adr, got %s", base)
+	}
+}
@@ -0,0 +1,115 @@
+package vectordb
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AuditEntry records one call of a tool that changed files or the index
+type AuditEntry struct {
+	ID          int64     `json:"id"`
+	Time        time.Time `json:"time"`
+	Tool        string    `json:"tool"`
+	Session     string    `json:"session"`
+	Arguments   string    `json:"arguments"`    // tool arguments as JSON
+	Document    string    `json:"document"`     // indexed path of the file the call acted on, if any
+	Before      string    `json:"before"`       // frontmatter before the call, as JSON
+	After       string    `json:"after"`        // frontmatter after the call, as JSON
+	ContentHash string    `json:"content_hash"` // SHA-256 of a deleted or re-indexed file
+	Error       string    `json:"error"`        // empty if the call succeeded
+}
+
+// AuditQuery filters audit log entries; zero values match everything
+type AuditQuery struct {
+	Tool     string
+	Document string
+	Session  string
+	Since    time.Time
+	Limit    int // most recent entries to return, 0 for all
+}
+
+// AppendAudit adds an entry to the audit log
+// The log is append-only: the database rejects updates and deletes
+func (db *DB) AppendAudit(entry *AuditEntry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	if entry.Arguments == "" {
+		entry.Arguments = "{}"
+	}
+
+	result, err := db.conn.Exec(
+		`INSERT INTO audit_log (created_at, tool, session, arguments, document, before, after, content_hash, error)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		entry.Time.UTC(), entry.Tool, entry.Session, entry.Arguments, entry.Document,
+		entry.Before, entry.After, entry.ContentHash, entry.Error,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to append audit entry: %w", err)
+	}
+
+	entry.ID, err = result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get audit entry ID: %w", err)
+	}
+	return nil
+}
+
+// QueryAudit returns audit log entries matching the query in chronological order
+func (db *DB) QueryAudit(query AuditQuery) ([]AuditEntry, error) {
+	var conditions []string
+	var args []interface{}
+	if query.Tool != "" {
+		conditions = append(conditions, "tool = ?")
+		args = append(args, query.Tool)
+	}
+	if query.Document != "" {
+		conditions = append(conditions, "document = ?")
+		args = append(args, query.Document)
+	}
+	if query.Session != "" {
+		conditions = append(conditions, "session = ?")
+		args = append(args, query.Session)
+	}
+	if !query.Since.IsZero() {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, query.Since.UTC())
+	}
+
+	sqlQuery := `SELECT id, created_at, tool, session, arguments, document, before, after, content_hash, error
+		FROM audit_log`
+	if len(conditions) > 0 {
+		sqlQuery += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	sqlQuery += " ORDER BY id DESC"
+	if query.Limit > 0 {
+		sqlQuery += " LIMIT ?"
+		args = append(args, query.Limit)
+	}
+
+	rows, err := db.conn.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var entry AuditEntry
+		if err := rows.Scan(&entry.ID, &entry.Time, &entry.Tool, &entry.Session, &entry.Arguments,
+			&entry.Document, &entry.Before, &entry.After, &entry.ContentHash, &entry.Error); err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	// Entries were read newest first so that the limit keeps the most recent ones
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries, nil
+}
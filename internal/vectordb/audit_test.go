@@ -0,0 +1,107 @@
+package vectordb
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAuditLog_AppendAndQuery(t *testing.T) {
+	db, err := Init(t.TempDir()+"/test.db", 384)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	start := time.Now().Add(-time.Hour)
+	entries := []*AuditEntry{
+		{Time: start, Tool: "delete_document", Session: "a", Document: "old.md", ContentHash: "abc"},
+		{Time: start.Add(time.Minute), Tool: "update_frontmatter", Session: "a", Document: "guide.md",
+			Arguments: `{"filepath":"guide.md"}`, Before: `{"domain":"frontend"}`, After: `{"domain":"backend"}`},
+		{Time: start.Add(2 * time.Minute), Tool: "update_frontmatter", Session: "b", Document: "api.md", Error: "failed"},
+	}
+	for _, entry := range entries {
+		if err := db.AppendAudit(entry); err != nil {
+			t.Fatalf("AppendAudit failed: %v", err)
+		}
+		if entry.ID == 0 {
+			t.Error("Expected AppendAudit to set the entry ID")
+		}
+	}
+
+	all, err := db.QueryAudit(AuditQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 3 || all[0].Tool != "delete_document" || all[2].Document != "api.md" {
+		t.Fatalf("Expected all entries in chronological order, got %+v", all)
+	}
+	if all[0].Arguments != "{}" {
+		t.Errorf("Expected empty arguments to be stored as {}, got %q", all[0].Arguments)
+	}
+	if all[1].Before != `{"domain":"frontend"}` || all[1].After != `{"domain":"backend"}` {
+		t.Errorf("Expected before/after frontmatter to round-trip, got %+v", all[1])
+	}
+
+	tests := []struct {
+		name     string
+		query    AuditQuery
+		expected []string // documents in order
+	}{
+		{"by tool", AuditQuery{Tool: "update_frontmatter"}, []string{"guide.md", "api.md"}},
+		{"by document", AuditQuery{Document: "old.md"}, []string{"old.md"}},
+		{"by session", AuditQuery{Session: "b"}, []string{"api.md"}},
+		{"since", AuditQuery{Since: start.Add(30 * time.Second)}, []string{"guide.md", "api.md"}},
+		{"limit keeps most recent", AuditQuery{Limit: 2}, []string{"guide.md", "api.md"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := db.QueryAudit(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var docs []string
+			for _, entry := range result {
+				docs = append(docs, entry.Document)
+			}
+			if len(docs) != len(tt.expected) {
+				t.Fatalf("Expected %v, got %v", tt.expected, docs)
+			}
+			for i := range docs {
+				if docs[i] != tt.expected[i] {
+					t.Errorf("Expected %v, got %v", tt.expected, docs)
+				}
+			}
+		})
+	}
+}
+
+func TestAuditLog_AppendOnly(t *testing.T) {
+	db, err := Init(t.TempDir()+"/test.db", 384)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err := db.AppendAudit(&AuditEntry{Tool: "delete_document", Document: "doc.md"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.conn.Exec("UPDATE audit_log SET document = 'other.md'"); err == nil {
+		t.Error("Expected updating the audit log to fail")
+	}
+	if _, err := db.conn.Exec("DELETE FROM audit_log"); err == nil {
+		t.Error("Expected deleting from the audit log to fail")
+	}
+
+	// Resetting the index keeps the audit trail
+	if err := db.ResetIndex(384); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := db.QueryAudit(AuditQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected the audit log to survive ResetIndex, got %d entries", len(entries))
+	}
+}
@@ -0,0 +1,127 @@
+package vectordb
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DocumentChunk is a stored chunk of a document
+type DocumentChunk struct {
+	ID          int64
+	Document    string
+	Position    int
+	Content     string
+	HeadingPath string
+}
+
+// GetNeighborChunks returns the chunk at position and up to window chunks before
+// and after it within the same document, ordered by position
+func (db *DB) GetNeighborChunks(filename string, position, window int) ([]DocumentChunk, error) {
+	if window < 0 {
+		return nil, fmt.Errorf("window must not be negative, got %d", window)
+	}
+	return db.getChunkRange(filename, position-window, position+window)
+}
+
+// getChunkRange returns the chunks of a document with positions in [start, end]
+func (db *DB) getChunkRange(filename string, start, end int) ([]DocumentChunk, error) {
+	rows, err := db.conn.Query(`
+		SELECT c.id, d.filename, c.position, c.content, c.heading_path
+		FROM chunks c
+		JOIN documents d ON c.document_id = d.id
+		WHERE d.filename = ? AND c.position BETWEEN ? AND ?
+		ORDER BY c.position
+	`, filename, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query chunks: %w", err)
+	}
+	defer rows.Close()
+
+	var chunks []DocumentChunk
+	for rows.Next() {
+		var chunk DocumentChunk
+		if err := rows.Scan(&chunk.ID, &chunk.Document, &chunk.Position, &chunk.Content, &chunk.HeadingPath); err != nil {
+			return nil, fmt.Errorf("failed to scan chunk: %w", err)
+		}
+		chunks = append(chunks, chunk)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating chunks: %w", err)
+	}
+
+	return chunks, nil
+}
+
+// expandContext attaches neighboring chunks to each result
+// Hits in the same document whose windows overlap or touch are merged into the
+// passage of the highest-ranked hit, so no text is returned twice
+func (db *DB) expandContext(results []SearchResult, window int) ([]SearchResult, error) {
+	var expanded []SearchResult
+	for _, r := range results {
+		start, end := r.Position-window, r.Position+window
+
+		merged := false
+		for i := range expanded {
+			if mergeWindow(&expanded[i], r.DocumentName, start, end) {
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			r.ContextStart, r.ContextEnd = start, end
+			expanded = append(expanded, r)
+		}
+	}
+
+	// A widened window can reach a lower-ranked one; merge until no windows overlap
+	for merged := true; merged; {
+		merged = false
+		for i := 0; i < len(expanded) && !merged; i++ {
+			for j := i + 1; j < len(expanded); j++ {
+				if mergeWindow(&expanded[i], expanded[j].DocumentName, expanded[j].ContextStart, expanded[j].ContextEnd) {
+					expanded = append(expanded[:j], expanded[j+1:]...)
+					merged = true
+					break
+				}
+			}
+		}
+	}
+
+	for i := range expanded {
+		r := &expanded[i]
+		chunks, err := db.getChunkRange(r.DocumentName, r.ContextStart, r.ContextEnd)
+		if err != nil {
+			return nil, err
+		}
+		if len(chunks) == 0 {
+			r.Context, r.ContextStart, r.ContextEnd = r.ChunkContent, r.Position, r.Position
+			continue
+		}
+
+		parts := make([]string, len(chunks))
+		for j, chunk := range chunks {
+			parts[j] = chunk.Content
+		}
+		r.Context = strings.Join(parts, "\n\n")
+		r.ContextStart = chunks[0].Position
+		r.ContextEnd = chunks[len(chunks)-1].Position
+	}
+
+	return expanded, nil
+}
+
+// mergeWindow widens the window of r to include [start, end] when both are in
+// the same document and overlap or touch, reporting whether it did
+func mergeWindow(r *SearchResult, documentName string, start, end int) bool {
+	if r.DocumentName != documentName || start > r.ContextEnd+1 || end < r.ContextStart-1 {
+		return false
+	}
+	if start < r.ContextStart {
+		r.ContextStart = start
+	}
+	if end > r.ContextEnd {
+		r.ContextEnd = end
+	}
+	return true
+}
@@ -0,0 +1,126 @@
+package vectordb
+
+import (
+	"testing"
+	"time"
+)
+
+// insertContextFixture inserts a document with ten chunks whose embeddings are
+// orthogonal, so each chunk can be hit individually
+func insertContextFixture(t *testing.T, db *DB) [][]float32 {
+	t.Helper()
+
+	const n = 10
+	chunks := make([]ChunkInterface, n)
+	embeddings := make([][]float32, n)
+	for i := 0; i < n; i++ {
+		chunks[i] = testChunk{content: string(rune('a' + i)), position: i}
+		embeddings[i] = make([]float32, 384)
+		embeddings[i][i] = 1
+	}
+	if err := db.InsertDocument("doc.md", time.Now(), "", nil, chunks, embeddings); err != nil {
+		t.Fatal(err)
+	}
+	return embeddings
+}
+
+func TestGetNeighborChunks(t *testing.T) {
+	db, err := Init(t.TempDir()+"/test.db", 384)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	insertContextFixture(t, db)
+
+	tests := []struct {
+		position, window int
+		want             string
+	}{
+		{5, 2, "defgh"},
+		{0, 2, "abc"},
+		{9, 1, "ij"},
+		{4, 0, "e"},
+	}
+
+	for _, tt := range tests {
+		chunks, err := db.GetNeighborChunks("doc.md", tt.position, tt.window)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := ""
+		for _, c := range chunks {
+			got += c.Content
+		}
+		if got != tt.want {
+			t.Errorf("GetNeighborChunks(%d, %d) = %q, want %q", tt.position, tt.window, got, tt.want)
+		}
+	}
+
+	if chunks, err := db.GetNeighborChunks("missing.md", 0, 1); err != nil || len(chunks) != 0 {
+		t.Errorf("Expected no chunks for unknown document, got %v, %v", chunks, err)
+	}
+	if _, err := db.GetNeighborChunks("doc.md", 0, -1); err == nil {
+		t.Error("Expected error for negative window")
+	}
+}
+
+func TestSearch_ContextWindow(t *testing.T) {
+	db, err := Init(t.TempDir()+"/test.db", 384)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	embeddings := insertContextFixture(t, db)
+
+	results, err := db.Search(SearchQuery{Vector: embeddings[5], TopK: 1, ContextWindow: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 result, got %d", len(results))
+	}
+	r := results[0]
+	if r.ChunkContent != "f" || r.Context != "e\n\nf\n\ng" || r.ContextStart != 4 || r.ContextEnd != 6 {
+		t.Errorf("Unexpected context: %+v", r)
+	}
+}
+
+func TestExpandContext_MergesOverlappingWindows(t *testing.T) {
+	db, err := Init(t.TempDir()+"/test.db", 384)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	insertContextFixture(t, db)
+
+	// Hits at 2 and 4 overlap with window 1; 8 is separate
+	hits := []SearchResult{
+		{DocumentName: "doc.md", ChunkContent: "c", Position: 2},
+		{DocumentName: "doc.md", ChunkContent: "i", Position: 8},
+		{DocumentName: "doc.md", ChunkContent: "e", Position: 4},
+	}
+
+	results, err := db.expandContext(hits, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 passages, got %d: %+v", len(results), results)
+	}
+	if results[0].Position != 2 || results[0].Context != "b\n\nc\n\nd\n\ne\n\nf" {
+		t.Errorf("Unexpected first passage: %+v", results[0])
+	}
+	if results[1].Position != 8 || results[1].ContextStart != 7 || results[1].ContextEnd != 9 {
+		t.Errorf("Unexpected second passage: %+v", results[1])
+	}
+
+	// A hit at 6 widens the first window to 7, which then touches the second one
+	hits = append(hits, SearchResult{DocumentName: "doc.md", ChunkContent: "g", Position: 6})
+	results, err = db.expandContext(hits, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].ContextStart != 1 || results[0].ContextEnd != 9 {
+		t.Errorf("Expected a single passage covering 1-9, got %+v", results)
+	}
+}
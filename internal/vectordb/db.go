@@ -1,8 +1,11 @@
 package vectordb
 
 import (
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"fmt"
+	"strings"
 	"time"
 	"unsafe"
 )
@@ -32,33 +35,156 @@ func (db *DB) ListDocuments() (map[string]time.Time, error) {
 	return docs, nil
 }
 
-// DeleteDocument deletes a document and its chunks from the database
+// DocumentState is the indexed state of a document used to detect changes
+type DocumentState struct {
+	ModifiedAt  time.Time
+	ContentHash string // empty for documents indexed before hashes were recorded
+}
+
+// ListDocumentStates returns the modification time and content hash of all documents
+func (db *DB) ListDocumentStates() (map[string]DocumentState, error) {
+	rows, err := db.conn.Query("SELECT filename, modified_at, content_hash FROM documents")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query documents: %w", err)
+	}
+	defer rows.Close()
+
+	states := make(map[string]DocumentState)
+	for rows.Next() {
+		var filename string
+		var state DocumentState
+		if err := rows.Scan(&filename, &state.ModifiedAt, &state.ContentHash); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		states[filename] = state
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return states, nil
+}
+
+// UpdateModifiedAt records a new modification time for a document whose content is unchanged
+func (db *DB) UpdateModifiedAt(filename string, modifiedAt time.Time) error {
+	result, err := db.conn.Exec("UPDATE documents SET modified_at = ? WHERE filename = ?", modifiedAt, filename)
+	if err != nil {
+		return fmt.Errorf("failed to update modification time: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("%w: %s", ErrDocumentNotFound, filename)
+	}
+
+	return nil
+}
+
+// DeleteDocument deletes a document, its chunks and their vectors from the database
 func (db *DB) DeleteDocument(filename string) error {
-	// Get document ID first
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := deleteDocument(tx, filename); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// deleteDocument deletes a document within a transaction
+// Chunks and vectors are deleted explicitly because vec0 has no foreign keys;
+// metadata and tags are removed by ON DELETE CASCADE
+func deleteDocument(tx *sql.Tx, filename string) error {
 	var docID int64
-	err := db.conn.QueryRow("SELECT id FROM documents WHERE filename = ?", filename).Scan(&docID)
+	err := tx.QueryRow("SELECT id FROM documents WHERE filename = ?", filename).Scan(&docID)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return fmt.Errorf("document not found: %s", filename)
+			return fmt.Errorf("%w: %s", ErrDocumentNotFound, filename)
 		}
 		return fmt.Errorf("failed to query document: %w", err)
 	}
 
-	// Delete document (chunks will be cascade deleted due to FOREIGN KEY)
-	result, err := db.conn.Exec("DELETE FROM documents WHERE id = ?", docID)
-	if err != nil {
+	if err := deleteChunks(tx, docID); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM documents WHERE id = ?", docID); err != nil {
 		return fmt.Errorf("failed to delete document: %w", err)
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	return nil
+}
+
+// ResetIndex removes all documents, chunks and vectors while keeping index metadata
+// The vector table is recreated at the given embedding dimension
+func (db *DB) ResetIndex(dimensions int) error {
+	if dimensions <= 0 {
+		return fmt.Errorf("dimensions must be positive, got %d", dimensions)
+	}
+
+	tx, err := db.conn.Begin()
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("document not deleted: %s", filename)
+	for _, table := range []string{"chunks", "document_tags", "document_metadata", "documents"} {
+		if _, err := tx.Exec("DELETE FROM " + table); err != nil {
+			return fmt.Errorf("failed to clear %s: %w", table, err)
+		}
 	}
 
+	// vec0 tables have a fixed dimension, so the table is dropped rather than emptied
+	if _, err := tx.Exec("DROP TABLE vec_chunks"); err != nil {
+		return fmt.Errorf("failed to drop vec_chunks: %w", err)
+	}
+	if _, err := tx.Exec(vecTableSQL(dimensions)); err != nil {
+		return fmt.Errorf("failed to create vec_chunks: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	db.dimensions = dimensions
+	return nil
+}
+
+// GetIndexMetadata returns a value recorded about how the index was built
+// The second return value is false if the key has never been set
+func (db *DB) GetIndexMetadata(key string) (string, bool, error) {
+	var value string
+	err := db.conn.QueryRow("SELECT value FROM index_metadata WHERE key = ?", key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to query index metadata: %w", err)
+	}
+	return value, true, nil
+}
+
+// SetIndexMetadata records a value about how the index was built
+func (db *DB) SetIndexMetadata(key, value string) error {
+	_, err := db.conn.Exec(
+		"INSERT INTO index_metadata (key, value) VALUES (?, ?) ON CONFLICT(key) DO UPDATE SET value = excluded.value",
+		key, value,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set index metadata: %w", err)
+	}
 	return nil
 }
 
@@ -66,14 +192,22 @@ func (db *DB) DeleteDocument(filename string) error {
 type ChunkInterface interface {
 	GetContent() string
 	GetPosition() int
+	GetHeadingPath() string
 }
 
-// InsertDocument inserts or updates a document and its chunks
-func (db *DB) InsertDocument(filename string, modifiedAt time.Time, chunks []ChunkInterface, embeddings [][]float32) error {
-	if len(chunks) != len(embeddings) {
-		return fmt.Errorf("chunks count (%d) does not match embeddings count (%d)", len(chunks), len(embeddings))
-	}
+// DocumentMetadata holds the frontmatter fields stored per document
+type DocumentMetadata struct {
+	Domain   string
+	DocType  string
+	Language string
+	Project  string
+	Tags     []string
+}
 
+// InsertDocument inserts or updates a document and its chunks
+// contentHash identifies the file content the chunks were built from
+// metadata may be nil for documents without frontmatter
+func (db *DB) InsertDocument(filename string, modifiedAt time.Time, contentHash string, metadata *DocumentMetadata, chunks []ChunkInterface, embeddings [][]float32) error {
 	// Begin transaction
 	tx, err := db.conn.Begin()
 	if err != nil {
@@ -81,37 +215,87 @@ func (db *DB) InsertDocument(filename string, modifiedAt time.Time, chunks []Chu
 	}
 	defer tx.Rollback() // Will be no-op if tx.Commit() succeeds
 
-	// Insert or replace document
-	result, err := tx.Exec(
-		"INSERT OR REPLACE INTO documents (filename, modified_at, indexed_at) VALUES (?, ?, CURRENT_TIMESTAMP)",
-		filename, modifiedAt,
-	)
-	if err != nil {
-		return fmt.Errorf("failed to insert document: %w", err)
+	if err := db.insertDocument(tx, DocumentRecord{filename, modifiedAt, contentHash, metadata, chunks, embeddings}); err != nil {
+		return err
 	}
 
-	docID, err := result.LastInsertId()
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// DocumentRecord is a document with its chunks and their vectors, as stored by InsertDocuments
+type DocumentRecord struct {
+	Filename    string
+	ModifiedAt  time.Time
+	ContentHash string
+	Metadata    *DocumentMetadata // nil for documents without frontmatter
+	Chunks      []ChunkInterface
+	Embeddings  [][]float32
+}
+
+// InsertDocuments inserts or updates several documents in a single transaction
+// Either all documents are stored or, on error, none of them
+func (db *DB) InsertDocuments(docs []DocumentRecord) error {
+	tx, err := db.conn.Begin()
 	if err != nil {
-		// If INSERT OR REPLACE updated an existing row, we need to get the document ID
-		err = tx.QueryRow("SELECT id FROM documents WHERE filename = ?", filename).Scan(&docID)
-		if err != nil {
-			return fmt.Errorf("failed to get document ID: %w", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Will be no-op if tx.Commit() succeeds
+
+	for _, doc := range docs {
+		if err := db.insertDocument(tx, doc); err != nil {
+			return fmt.Errorf("failed to insert %s: %w", doc.Filename, err)
 		}
 	}
 
-	// Delete existing chunks for this document (if any)
-	// This is necessary when re-indexing
-	_, err = tx.Exec("DELETE FROM chunks WHERE document_id = ?", docID)
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// insertDocument inserts or updates a document and its chunks within a transaction
+func (db *DB) insertDocument(tx *sql.Tx, doc DocumentRecord) error {
+	if len(doc.Chunks) != len(doc.Embeddings) {
+		return fmt.Errorf("chunks count (%d) does not match embeddings count (%d)", len(doc.Chunks), len(doc.Embeddings))
+	}
+
+	// Insert the document, or update it in place so that its ID stays stable
+	var docID int64
+	err := tx.QueryRow(
+		`INSERT INTO documents (filename, modified_at, content_hash, indexed_at) VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(filename) DO UPDATE SET
+			modified_at = excluded.modified_at,
+			content_hash = excluded.content_hash,
+			indexed_at = excluded.indexed_at
+		RETURNING id`,
+		doc.Filename, doc.ModifiedAt, doc.ContentHash,
+	).Scan(&docID)
 	if err != nil {
-		return fmt.Errorf("failed to delete old chunks: %w", err)
+		return fmt.Errorf("failed to insert document: %w", err)
+	}
+
+	// Delete existing chunks and their vectors for this document (if any)
+	// This is necessary when re-indexing
+	if err := deleteChunks(tx, docID); err != nil {
+		return err
+	}
+
+	// Replace stored metadata for this document
+	if err := insertMetadata(tx, docID, doc.Metadata); err != nil {
+		return err
 	}
 
 	// Insert chunks and their vectors
-	for i, chunk := range chunks {
+	for i, chunk := range doc.Chunks {
 		// Insert chunk
 		result, err := tx.Exec(
-			"INSERT INTO chunks (document_id, position, content) VALUES (?, ?, ?)",
-			docID, chunk.GetPosition(), chunk.GetContent(),
+			"INSERT INTO chunks (document_id, position, content, heading_path, content_hash) VALUES (?, ?, ?, ?, ?)",
+			docID, chunk.GetPosition(), chunk.GetContent(), chunk.GetHeadingPath(), ChunkHash(chunk.GetContent()),
 		)
 		if err != nil {
 			return fmt.Errorf("failed to insert chunk %d: %w", i, err)
@@ -124,10 +308,13 @@ func (db *DB) InsertDocument(filename string, modifiedAt time.Time, chunks []Chu
 
 		// Insert embedding into vec_chunks virtual table
 		// vec0 expects vectors as a blob of float32 values
-		embedding := embeddings[i]
+		embedding := doc.Embeddings[i]
 		if len(embedding) == 0 {
 			return fmt.Errorf("empty embedding for chunk %d", i)
 		}
+		if len(embedding) != db.dimensions {
+			return fmt.Errorf("embedding for chunk %d has %d dimensions, index expects %d", i, len(embedding), db.dimensions)
+		}
 
 		// Convert []float32 to a format suitable for vec0
 		// vec0 expects a serialized format - we'll insert directly as a blob
@@ -143,9 +330,123 @@ func (db *DB) InsertDocument(filename string, modifiedAt time.Time, chunks []Chu
 		}
 	}
 
-	// Commit transaction
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+	return nil
+}
+
+// deleteChunks removes the chunks of a document and their vectors within a transaction
+// vec0 has no foreign keys, so vectors are deleted by rowid before their chunks
+func deleteChunks(tx *sql.Tx, docID int64) error {
+	rows, err := tx.Query("SELECT id FROM chunks WHERE document_id = ?", docID)
+	if err != nil {
+		return fmt.Errorf("failed to query old chunks: %w", err)
+	}
+	var chunkIDs []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan chunk ID: %w", err)
+		}
+		chunkIDs = append(chunkIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to query old chunks: %w", err)
+	}
+
+	for _, id := range chunkIDs {
+		if _, err := tx.Exec("DELETE FROM vec_chunks WHERE rowid = ?", id); err != nil {
+			return fmt.Errorf("failed to delete vector of chunk %d: %w", id, err)
+		}
+	}
+
+	if _, err := tx.Exec("DELETE FROM chunks WHERE document_id = ?", docID); err != nil {
+		return fmt.Errorf("failed to delete old chunks: %w", err)
+	}
+	return nil
+}
+
+// ChunkHash returns the hex-encoded SHA-256 of chunk content
+// Chunks with the same hash share the same embedding, so their vectors can be reused
+func ChunkHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// GetEmbeddingsByHash returns stored vectors for the given chunk hashes
+// Hashes without a stored chunk are absent from the result
+func (db *DB) GetEmbeddingsByHash(hashes []string) (map[string][]float32, error) {
+	embeddings := make(map[string][]float32)
+
+	// Stay well below SQLite's bound parameter limit
+	const batchSize = 500
+	for start := 0; start < len(hashes); start += batchSize {
+		end := start + batchSize
+		if end > len(hashes) {
+			end = len(hashes)
+		}
+		batch := hashes[start:end]
+
+		args := make([]interface{}, len(batch))
+		for i, hash := range batch {
+			args[i] = hash
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(batch)), ", ")
+
+		rows, err := db.conn.Query(`
+			SELECT c.content_hash, v.embedding
+			FROM chunks c
+			JOIN vec_chunks v ON v.rowid = c.id
+			WHERE c.content_hash IN (`+placeholders+`)`, args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query embeddings: %w", err)
+		}
+
+		for rows.Next() {
+			var hash string
+			var blob []byte
+			if err := rows.Scan(&hash, &blob); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan embedding: %w", err)
+			}
+			if _, ok := embeddings[hash]; !ok {
+				embeddings[hash] = deserializeVector(blob)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("error iterating embeddings: %w", err)
+		}
+	}
+
+	return embeddings, nil
+}
+
+// insertMetadata replaces the metadata and tags of a document within a transaction
+func insertMetadata(tx *sql.Tx, docID int64, metadata *DocumentMetadata) error {
+	if _, err := tx.Exec("DELETE FROM document_metadata WHERE document_id = ?", docID); err != nil {
+		return fmt.Errorf("failed to delete old metadata: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM document_tags WHERE document_id = ?", docID); err != nil {
+		return fmt.Errorf("failed to delete old tags: %w", err)
+	}
+
+	if metadata == nil {
+		return nil
+	}
+
+	_, err := tx.Exec(
+		"INSERT INTO document_metadata (document_id, domain, doc_type, language, project) VALUES (?, ?, ?, ?, ?)",
+		docID, metadata.Domain, metadata.DocType, metadata.Language, metadata.Project,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert metadata: %w", err)
+	}
+
+	for _, tag := range metadata.Tags {
+		if _, err := tx.Exec("INSERT OR IGNORE INTO document_tags (document_id, tag) VALUES (?, ?)", docID, tag); err != nil {
+			return fmt.Errorf("failed to insert tag %q: %w", tag, err)
+		}
 	}
 
 	return nil
@@ -166,3 +467,13 @@ func serializeVector(vec []float32) []byte {
 	}
 	return result
 }
+
+// deserializeVector converts a blob written by serializeVector back to float32 values
+func deserializeVector(blob []byte) []float32 {
+	result := make([]float32, len(blob)/4)
+	for i := range result {
+		bits := uint32(blob[i*4]) | uint32(blob[i*4+1])<<8 | uint32(blob[i*4+2])<<16 | uint32(blob[i*4+3])<<24
+		result[i] = *(*float32)(unsafe.Pointer(&bits))
+	}
+	return result
+}
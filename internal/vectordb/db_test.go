@@ -1,6 +1,11 @@
 package vectordb
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"strings"
 	"testing"
 	"time"
 )
@@ -8,7 +13,7 @@ import (
 func TestInit(t *testing.T) {
 	dbPath := t.TempDir() + "/test.db"
 
-	db, err := Init(dbPath)
+	db, err := Init(dbPath, 384)
 	if err != nil {
 		t.Fatalf("Init failed: %v", err)
 	}
@@ -45,7 +50,7 @@ func TestInit(t *testing.T) {
 func TestListDocuments_Empty(t *testing.T) {
 	dbPath := t.TempDir() + "/test.db"
 
-	db, err := Init(dbPath)
+	db, err := Init(dbPath, 384)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -64,7 +69,7 @@ func TestListDocuments_Empty(t *testing.T) {
 func TestListDocuments_WithData(t *testing.T) {
 	dbPath := t.TempDir() + "/test.db"
 
-	db, err := Init(dbPath)
+	db, err := Init(dbPath, 384)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -109,7 +114,7 @@ func TestListDocuments_WithData(t *testing.T) {
 func TestDeleteDocument(t *testing.T) {
 	dbPath := t.TempDir() + "/test.db"
 
-	db, err := Init(dbPath)
+	db, err := Init(dbPath, 384)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -141,7 +146,7 @@ func TestDeleteDocument(t *testing.T) {
 func TestDeleteDocument_NotFound(t *testing.T) {
 	dbPath := t.TempDir() + "/test.db"
 
-	db, err := Init(dbPath)
+	db, err := Init(dbPath, 384)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -155,8 +160,9 @@ func TestDeleteDocument_NotFound(t *testing.T) {
 }
 
 type testChunk struct {
-	content  string
-	position int
+	content     string
+	position    int
+	headingPath string
 }
 
 func (c testChunk) GetContent() string {
@@ -167,10 +173,14 @@ func (c testChunk) GetPosition() int {
 	return c.position
 }
 
+func (c testChunk) GetHeadingPath() string {
+	return c.headingPath
+}
+
 func TestInsertDocument(t *testing.T) {
 	dbPath := t.TempDir() + "/test.db"
 
-	db, err := Init(dbPath)
+	db, err := Init(dbPath, 384)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -192,7 +202,7 @@ func TestInsertDocument(t *testing.T) {
 	}
 
 	// Insert document
-	err = db.InsertDocument("test.md", time.Now(), chunks, embeddings)
+	err = db.InsertDocument("test.md", time.Now(), "", nil, chunks, embeddings)
 	if err != nil {
 		t.Fatalf("InsertDocument failed: %v", err)
 	}
@@ -230,10 +240,47 @@ func TestInsertDocument(t *testing.T) {
 	}
 }
 
+func TestInsertDocuments(t *testing.T) {
+	dbPath := t.TempDir() + "/test.db"
+
+	db, err := Init(dbPath, 384)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	record := func(filename string, dims int) DocumentRecord {
+		return DocumentRecord{
+			Filename:   filename,
+			ModifiedAt: time.Now(),
+			Chunks:     []ChunkInterface{testChunk{content: "Chunk of " + filename}},
+			Embeddings: [][]float32{make([]float32, dims)},
+		}
+	}
+
+	if err := db.InsertDocuments([]DocumentRecord{record("a.md", 384), record("b.md", 384)}); err != nil {
+		t.Fatalf("InsertDocuments failed: %v", err)
+	}
+
+	// A bad document rolls back the whole batch
+	err = db.InsertDocuments([]DocumentRecord{record("c.md", 384), record("d.md", 128)})
+	if err == nil || !strings.Contains(err.Error(), "d.md") {
+		t.Errorf("Expected an error naming d.md, got %v", err)
+	}
+
+	docs, err := db.ListDocuments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := docs["c.md"]; len(docs) != 2 || ok {
+		t.Errorf("Expected only a.md and b.md to be stored, got %v", docs)
+	}
+}
+
 func TestInsertDocument_MismatchedCounts(t *testing.T) {
 	dbPath := t.TempDir() + "/test.db"
 
-	db, err := Init(dbPath)
+	db, err := Init(dbPath, 384)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -248,7 +295,7 @@ func TestInsertDocument_MismatchedCounts(t *testing.T) {
 		embeddings[i] = make([]float32, 384)
 	}
 
-	err = db.InsertDocument("test.md", time.Now(), chunks, embeddings)
+	err = db.InsertDocument("test.md", time.Now(), "", nil, chunks, embeddings)
 	if err == nil {
 		t.Error("Expected error for mismatched counts, got nil")
 	}
@@ -257,7 +304,7 @@ func TestInsertDocument_MismatchedCounts(t *testing.T) {
 func TestInsertDocument_Reindex(t *testing.T) {
 	dbPath := t.TempDir() + "/test.db"
 
-	db, err := Init(dbPath)
+	db, err := Init(dbPath, 384)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -270,7 +317,7 @@ func TestInsertDocument_Reindex(t *testing.T) {
 	embeddings1 := make([][]float32, 1)
 	embeddings1[0] = make([]float32, 384)
 
-	err = db.InsertDocument("test.md", time.Now(), chunks1, embeddings1)
+	err = db.InsertDocument("test.md", time.Now(), "", nil, chunks1, embeddings1)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -285,7 +332,7 @@ func TestInsertDocument_Reindex(t *testing.T) {
 		embeddings2[i] = make([]float32, 384)
 	}
 
-	err = db.InsertDocument("test.md", time.Now(), chunks2, embeddings2)
+	err = db.InsertDocument("test.md", time.Now(), "", nil, chunks2, embeddings2)
 	if err != nil {
 		t.Fatalf("Re-indexing failed: %v", err)
 	}
@@ -336,7 +383,7 @@ func TestSerializeVector(t *testing.T) {
 func TestClose(t *testing.T) {
 	dbPath := t.TempDir() + "/test.db"
 
-	db, err := Init(dbPath)
+	db, err := Init(dbPath, 384)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -355,7 +402,7 @@ func TestClose(t *testing.T) {
 
 func TestInit_InvalidPath(t *testing.T) {
 	// Try to create database in non-existent directory
-	_, err := Init("/nonexistent/path/test.db")
+	_, err := Init("/nonexistent/path/test.db", 384)
 	if err == nil {
 		t.Error("Expected error for invalid path, got nil")
 	}
@@ -364,7 +411,7 @@ func TestInit_InvalidPath(t *testing.T) {
 func TestDeleteDocument_CascadeChunks(t *testing.T) {
 	dbPath := t.TempDir() + "/test.db"
 
-	db, err := Init(dbPath)
+	db, err := Init(dbPath, 384)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -380,7 +427,7 @@ func TestDeleteDocument_CascadeChunks(t *testing.T) {
 		embeddings[i] = make([]float32, 384)
 	}
 
-	err = db.InsertDocument("test.md", time.Now(), chunks, embeddings)
+	err = db.InsertDocument("test.md", time.Now(), "", nil, chunks, embeddings)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -409,4 +456,406 @@ func TestDeleteDocument_CascadeChunks(t *testing.T) {
 	if chunkCount != 0 {
 		t.Errorf("Expected 0 chunks after document deletion, got %d", chunkCount)
 	}
+
+	// Verify vectors were deleted in the same transaction
+	var vectorCount int
+	if err := db.conn.QueryRow("SELECT COUNT(*) FROM vec_chunks").Scan(&vectorCount); err != nil {
+		t.Fatal(err)
+	}
+	if vectorCount != 0 {
+		t.Errorf("Expected 0 vectors after document deletion, got %d", vectorCount)
+	}
+}
+
+func TestDeleteDocument_CascadeMetadataOnEveryConnection(t *testing.T) {
+	dbPath := t.TempDir() + "/test.db"
+
+	db, err := Init(dbPath, 384)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// Hold several pooled connections at once so that each is a separate SQLite connection
+	ctx := context.Background()
+	var conns []*sql.Conn
+	for i := 0; i < 3; i++ {
+		conn, err := db.conn.Conn(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conns = append(conns, conn)
+
+		var enabled int
+		if err := conn.QueryRowContext(ctx, "PRAGMA foreign_keys").Scan(&enabled); err != nil {
+			t.Fatal(err)
+		}
+		if enabled != 1 {
+			t.Errorf("Expected foreign keys on connection %d", i)
+		}
+	}
+
+	// Delete through a connection other than the one that ran the migrations
+	chunks := []ChunkInterface{testChunk{content: "Chunk 1", position: 0}}
+	metadata := &DocumentMetadata{Domain: "backend", Tags: []string{"auth", "api"}}
+	if err := db.InsertDocument("test.md", time.Now(), "", metadata, chunks, [][]float32{make([]float32, 384)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.DeleteDocument("test.md"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, table := range []string{"document_metadata", "document_tags"} {
+		var count int
+		if err := conns[0].QueryRowContext(ctx, "SELECT COUNT(*) FROM "+table).Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+		if count != 0 {
+			t.Errorf("Expected %s to be empty after deletion, got %d rows", table, count)
+		}
+	}
+}
+
+func TestIndexMetadata(t *testing.T) {
+	dbPath := t.TempDir() + "/test.db"
+
+	db, err := Init(dbPath, 384)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, ok, err := db.GetIndexMetadata("model"); err != nil || ok {
+		t.Fatalf("Expected unset key, got ok=%v err=%v", ok, err)
+	}
+
+	if err := db.SetIndexMetadata("model", "multilingual-e5-small"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetIndexMetadata("model", "bge-m3"); err != nil {
+		t.Fatal(err)
+	}
+
+	value, ok, err := db.GetIndexMetadata("model")
+	if err != nil || !ok || value != "bge-m3" {
+		t.Errorf("Expected 'bge-m3', got %q ok=%v err=%v", value, ok, err)
+	}
+}
+
+func TestResetIndex(t *testing.T) {
+	dbPath := t.TempDir() + "/test.db"
+
+	db, err := Init(dbPath, 384)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	chunks := []ChunkInterface{
+		testChunk{content: "Chunk 1", position: 0},
+		testChunk{content: "Chunk 2", position: 1},
+	}
+	embeddings := [][]float32{make([]float32, 384), make([]float32, 384)}
+	metadata := &DocumentMetadata{Domain: "backend", Tags: []string{"auth"}}
+
+	if err := db.InsertDocument("test.md", time.Now(), "", metadata, chunks, embeddings); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetIndexMetadata("model", "bge-m3"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.ResetIndex(768); err != nil {
+		t.Fatalf("ResetIndex failed: %v", err)
+	}
+
+	for _, table := range []string{"documents", "chunks", "vec_chunks", "document_metadata", "document_tags"} {
+		var count int
+		if err := db.conn.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+		if count != 0 {
+			t.Errorf("Expected %s to be empty, got %d rows", table, count)
+		}
+	}
+
+	// Index metadata survives
+	if _, ok, _ := db.GetIndexMetadata("model"); !ok {
+		t.Error("Expected index metadata to be kept")
+	}
+
+	// The vector table is recreated at the new dimension
+	if db.Dimensions() != 768 {
+		t.Errorf("Expected 768 dimensions after reset, got %d", db.Dimensions())
+	}
+	embedding := make([]float32, 768)
+	embedding[0] = 1
+	if err := db.InsertDocument("test.md", time.Now(), "", nil, chunks[:1], [][]float32{embedding}); err != nil {
+		t.Errorf("InsertDocument after reset failed: %v", err)
+	}
+}
+
+func TestDocumentStates(t *testing.T) {
+	dbPath := t.TempDir() + "/test.db"
+
+	db, err := Init(dbPath, 384)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	modTime := time.Date(2024, 1, 1, 12, 0, 0, 123456789, time.UTC)
+	chunks := []ChunkInterface{testChunk{content: "chunk", position: 0}}
+	embeddings := [][]float32{make([]float32, 384)}
+	embeddings[0][0] = 1
+	if err := db.InsertDocument("test.md", modTime, "abc123", nil, chunks, embeddings); err != nil {
+		t.Fatal(err)
+	}
+
+	states, err := db.ListDocumentStates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, ok := states["test.md"]
+	if !ok {
+		t.Fatal("Expected test.md in document states")
+	}
+	if state.ContentHash != "abc123" {
+		t.Errorf("Expected content hash 'abc123', got %q", state.ContentHash)
+	}
+	if !state.ModifiedAt.Equal(modTime) {
+		t.Errorf("Expected modified_at %v with full precision, got %v", modTime, state.ModifiedAt)
+	}
+
+	touched := modTime.Add(500 * time.Millisecond)
+	if err := db.UpdateModifiedAt("test.md", touched); err != nil {
+		t.Fatal(err)
+	}
+	states, err = db.ListDocumentStates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !states["test.md"].ModifiedAt.Equal(touched) || states["test.md"].ContentHash != "abc123" {
+		t.Errorf("Expected updated timestamp and unchanged hash, got %+v", states["test.md"])
+	}
+
+	if err := db.UpdateModifiedAt("missing.md", touched); !errors.Is(err, ErrDocumentNotFound) {
+		t.Errorf("Expected ErrDocumentNotFound, got %v", err)
+	}
+}
+
+func TestGetEmbeddingsByHash(t *testing.T) {
+	dbPath := t.TempDir() + "/test.db"
+
+	db, err := Init(dbPath, 384)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	embedding := make([]float32, 384)
+	embedding[0] = 0.6
+	embedding[1] = -0.8
+	chunks := []ChunkInterface{testChunk{content: "Stored chunk", position: 0}}
+	if err := db.InsertDocument("test.md", time.Now(), "", nil, chunks, [][]float32{embedding}); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := db.GetEmbeddingsByHash([]string{ChunkHash("Stored chunk"), ChunkHash("Unknown chunk")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 1 {
+		t.Fatalf("Expected 1 stored embedding, got %d", len(stored))
+	}
+	got := stored[ChunkHash("Stored chunk")]
+	if len(got) != 384 || got[0] != 0.6 || got[1] != -0.8 {
+		t.Errorf("Stored embedding does not round-trip: %v", got[:2])
+	}
+}
+
+func TestInsertDocument_ReplacesVectors(t *testing.T) {
+	dbPath := t.TempDir() + "/test.db"
+
+	db, err := Init(dbPath, 384)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	embedding := make([]float32, 384)
+	embedding[0] = 1
+	for _, n := range []int{3, 2} {
+		chunks := make([]ChunkInterface, n)
+		embeddings := make([][]float32, n)
+		for i := range chunks {
+			chunks[i] = testChunk{content: "chunk", position: i}
+			embeddings[i] = embedding
+		}
+		if err := db.InsertDocument("test.md", time.Now(), "", nil, chunks, embeddings); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var chunkCount, vectorCount int
+	if err := db.conn.QueryRow("SELECT COUNT(*) FROM chunks").Scan(&chunkCount); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.conn.QueryRow("SELECT COUNT(*) FROM vec_chunks").Scan(&vectorCount); err != nil {
+		t.Fatal(err)
+	}
+	if chunkCount != 2 || vectorCount != 2 {
+		t.Errorf("Expected 2 chunks and 2 vectors after re-insert, got %d chunks and %d vectors", chunkCount, vectorCount)
+	}
+}
+
+func TestInit_CustomDimensions(t *testing.T) {
+	dbPath := t.TempDir() + "/test.db"
+
+	db, err := Init(dbPath, 768)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if db.Dimensions() != 768 {
+		t.Errorf("Expected 768 dimensions, got %d", db.Dimensions())
+	}
+
+	embedding := make([]float32, 768)
+	embedding[0] = 1
+	chunks := []ChunkInterface{testChunk{content: "chunk", position: 0}}
+	if err := db.InsertDocument("test.md", time.Now(), "", nil, chunks, [][]float32{embedding}); err != nil {
+		t.Fatalf("InsertDocument failed: %v", err)
+	}
+
+	results, err := db.Search(SearchQuery{Vector: embedding, TopK: 1})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 1 {
+		t.Errorf("Expected 1 result, got %d", len(results))
+	}
+}
+
+func TestInit_InvalidDimensions(t *testing.T) {
+	if _, err := Init(t.TempDir()+"/test.db", 0); err == nil {
+		t.Error("Expected error for zero dimensions")
+	}
+}
+
+func TestInit_KeepsExistingDimensions(t *testing.T) {
+	dbPath := t.TempDir() + "/test.db"
+
+	db, err := Init(dbPath, 768)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	db, err = Init(dbPath, 384)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if db.Dimensions() != 768 {
+		t.Errorf("Expected existing table to keep 768 dimensions, got %d", db.Dimensions())
+	}
+}
+
+func TestDimensionMismatch(t *testing.T) {
+	dbPath := t.TempDir() + "/test.db"
+
+	db, err := Init(dbPath, 384)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	chunks := []ChunkInterface{testChunk{content: "chunk", position: 0}}
+	if err := db.InsertDocument("test.md", time.Now(), "", nil, chunks, [][]float32{make([]float32, 768)}); err == nil {
+		t.Error("Expected error when inserting an embedding of the wrong dimension")
+	}
+
+	if _, err := db.Search(SearchQuery{Vector: make([]float32, 768), TopK: 1}); err == nil {
+		t.Error("Expected error when searching with a vector of the wrong dimension")
+	}
+}
+
+func TestOpenReadOnly(t *testing.T) {
+	dbPath := t.TempDir() + "/test.db"
+
+	db, err := Init(dbPath, 768)
+	if err != nil {
+		t.Fatal(err)
+	}
+	embedding := make([]float32, 768)
+	embedding[0] = 1
+	chunks := []ChunkInterface{testChunk{content: "read-only chunk", position: 0}}
+	if err := db.InsertDocument("doc.md", time.Now(), "", nil, chunks, [][]float32{embedding}); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	db, err = OpenReadOnly(dbPath)
+	if err != nil {
+		t.Fatalf("OpenReadOnly failed: %v", err)
+	}
+	defer db.Close()
+
+	if db.Dimensions() != 768 {
+		t.Errorf("Expected 768 dimensions, got %d", db.Dimensions())
+	}
+
+	results, err := db.Search(SearchQuery{Vector: embedding, TopK: 1})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 1 || results[0].ChunkContent != "read-only chunk" {
+		t.Errorf("Expected the stored chunk, got %+v", results)
+	}
+
+	if err := db.DeleteDocument("doc.md"); err == nil {
+		t.Error("Expected DeleteDocument to fail on a read-only database")
+	}
+	if err := db.InsertDocument("new.md", time.Now(), "", nil, chunks, [][]float32{embedding}); err == nil {
+		t.Error("Expected InsertDocument to fail on a read-only database")
+	}
+	if err := db.SetIndexMetadata("key", "value"); err == nil {
+		t.Error("Expected SetIndexMetadata to fail on a read-only database")
+	}
+
+	docs, err := db.ListDocuments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 1 {
+		t.Errorf("Expected the document to be unchanged, got %v", docs)
+	}
+}
+
+func TestOpenReadOnly_Missing(t *testing.T) {
+	dbPath := t.TempDir() + "/missing.db"
+
+	if _, err := OpenReadOnly(dbPath); err == nil {
+		t.Error("Expected error for a missing database")
+	}
+	if _, err := os.Stat(dbPath); !os.IsNotExist(err) {
+		t.Error("Expected OpenReadOnly not to create the database")
+	}
+}
+
+func TestOpenReadOnly_OutdatedSchema(t *testing.T) {
+	dbPath := t.TempDir() + "/test.db"
+	conn := openRaw(t, dbPath)
+	if err := migrateTo(conn, 384, latestSchemaVersion-1); err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	if _, err := OpenReadOnly(dbPath); err == nil {
+		t.Error("Expected error for a database that needs migration")
+	}
 }
@@ -0,0 +1,127 @@
+package vectordb
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Document is an indexed document as stored in the database
+type Document struct {
+	Filename    string
+	ModifiedAt  time.Time
+	IndexedAt   time.Time
+	ContentHash string
+	Metadata    *DocumentMetadata // nil for documents without frontmatter
+	ChunkCount  int
+}
+
+// GetDocument returns a document with its metadata and number of chunks
+// It returns ErrDocumentNotFound if the document is not indexed
+func (db *DB) GetDocument(filename string) (*Document, error) {
+	doc := &Document{Filename: filename}
+	var docID int64
+	err := db.conn.QueryRow(`
+		SELECT d.id, d.modified_at, d.indexed_at, d.content_hash,
+			(SELECT COUNT(*) FROM chunks c WHERE c.document_id = d.id)
+		FROM documents d
+		WHERE d.filename = ?
+	`, filename).Scan(&docID, &doc.ModifiedAt, &doc.IndexedAt, &doc.ContentHash, &doc.ChunkCount)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %s", ErrDocumentNotFound, filename)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query document: %w", err)
+	}
+
+	metadata := &DocumentMetadata{Tags: []string{}}
+	err = db.conn.QueryRow(
+		"SELECT domain, doc_type, language, project FROM document_metadata WHERE document_id = ?", docID,
+	).Scan(&metadata.Domain, &metadata.DocType, &metadata.Language, &metadata.Project)
+	if err == sql.ErrNoRows {
+		return doc, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query metadata: %w", err)
+	}
+
+	err = queryRows(db.conn, "SELECT tag FROM document_tags WHERE document_id = ? ORDER BY tag", func(rows *sql.Rows) error {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return err
+		}
+		metadata.Tags = append(metadata.Tags, tag)
+		return nil
+	}, docID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tags: %w", err)
+	}
+
+	doc.Metadata = metadata
+	return doc, nil
+}
+
+// GetDocumentChunks returns the chunks of a document with positions in [start, end],
+// ordered by position
+func (db *DB) GetDocumentChunks(filename string, start, end int) ([]DocumentChunk, error) {
+	if start > end {
+		return nil, fmt.Errorf("start position %d is after end position %d", start, end)
+	}
+	chunks, err := db.getChunkRange(filename, start, end)
+	if err != nil {
+		return nil, err
+	}
+	if chunks == nil {
+		chunks = []DocumentChunk{}
+	}
+	return chunks, nil
+}
+
+// GetChunk returns a chunk by its ID
+// It returns ErrChunkNotFound if no chunk has the ID
+func (db *DB) GetChunk(id int64) (*DocumentChunk, error) {
+	chunk := &DocumentChunk{ID: id}
+	err := db.conn.QueryRow(`
+		SELECT d.filename, c.position, c.content, c.heading_path
+		FROM chunks c
+		JOIN documents d ON c.document_id = d.id
+		WHERE c.id = ?
+	`, id).Scan(&chunk.Document, &chunk.Position, &chunk.Content, &chunk.HeadingPath)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %d", ErrChunkNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query chunk: %w", err)
+	}
+	return chunk, nil
+}
+
+// IndexStats summarizes the contents of the index
+type IndexStats struct {
+	Documents     int  `json:"documents"`
+	Chunks        int  `json:"chunks"`
+	Vectors       int  `json:"vectors"`
+	SchemaVersion int  `json:"schema_version"`
+	Dimensions    int  `json:"dimensions"`
+	KeywordIndex  bool `json:"keyword_index"`
+}
+
+// Stats counts the documents, chunks and vectors in the index
+func (db *DB) Stats() (*IndexStats, error) {
+	stats := &IndexStats{Dimensions: db.dimensions, KeywordIndex: db.fts}
+	err := db.conn.QueryRow(`
+		SELECT
+			(SELECT COUNT(*) FROM documents),
+			(SELECT COUNT(*) FROM chunks),
+			(SELECT COUNT(*) FROM vec_chunks)
+	`).Scan(&stats.Documents, &stats.Chunks, &stats.Vectors)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count index contents: %w", err)
+	}
+
+	stats.SchemaVersion, err = schemaVersion(db.conn)
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
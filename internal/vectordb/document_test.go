@@ -0,0 +1,139 @@
+package vectordb
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGetDocument(t *testing.T) {
+	db, err := Init(t.TempDir()+"/test.db", 384)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	insertContextFixture(t, db)
+	metadata := &DocumentMetadata{Domain: "backend", DocType: "api", Tags: []string{"jwt", "auth"}}
+	chunks := []ChunkInterface{testChunk{content: "tagged", position: 0}}
+	if err := db.InsertDocument("tagged.md", time.Now(), "hash", metadata, chunks, [][]float32{make([]float32, 384)}); err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := db.GetDocument("doc.md")
+	if err != nil {
+		t.Fatalf("GetDocument failed: %v", err)
+	}
+	if doc.ChunkCount != 10 {
+		t.Errorf("Expected 10 chunks, got %d", doc.ChunkCount)
+	}
+	if doc.Metadata != nil {
+		t.Errorf("Expected no metadata, got %+v", doc.Metadata)
+	}
+
+	doc, err = db.GetDocument("tagged.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.ContentHash != "hash" || doc.Metadata == nil || doc.Metadata.Domain != "backend" {
+		t.Errorf("Expected hash and metadata, got %+v", doc)
+	}
+	if len(doc.Metadata.Tags) != 2 || doc.Metadata.Tags[0] != "auth" {
+		t.Errorf("Expected sorted tags, got %v", doc.Metadata.Tags)
+	}
+
+	if _, err := db.GetDocument("missing.md"); !errors.Is(err, ErrDocumentNotFound) {
+		t.Errorf("Expected ErrDocumentNotFound, got %v", err)
+	}
+}
+
+func TestGetDocumentChunks(t *testing.T) {
+	db, err := Init(t.TempDir()+"/test.db", 384)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	insertContextFixture(t, db)
+
+	chunks, err := db.GetDocumentChunks("doc.md", 3, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := ""
+	for _, c := range chunks {
+		got += c.Content
+		if c.Document != "doc.md" || c.ID == 0 {
+			t.Errorf("Expected chunk ID and document, got %+v", c)
+		}
+	}
+	if got != "def" {
+		t.Errorf("Expected chunks 3-5, got %q", got)
+	}
+
+	chunks, err = db.GetDocumentChunks("missing.md", 0, 5)
+	if err != nil || chunks == nil || len(chunks) != 0 {
+		t.Errorf("Expected an empty list for unknown document, got %v, %v", chunks, err)
+	}
+
+	if _, err := db.GetDocumentChunks("doc.md", 5, 3); err == nil {
+		t.Error("Expected error for an inverted range")
+	}
+}
+
+func TestGetChunk(t *testing.T) {
+	db, err := Init(t.TempDir()+"/test.db", 384)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	embeddings := insertContextFixture(t, db)
+
+	// Chunk IDs from search results resolve to the same chunk
+	results, err := db.Search(SearchQuery{Vector: embeddings[7], TopK: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].ChunkID == 0 {
+		t.Fatalf("Expected a result with a chunk ID, got %+v", results)
+	}
+
+	chunk, err := db.GetChunk(results[0].ChunkID)
+	if err != nil {
+		t.Fatalf("GetChunk failed: %v", err)
+	}
+	if chunk.Document != "doc.md" || chunk.Position != 7 || chunk.Content != "h" {
+		t.Errorf("Expected chunk 7 of doc.md, got %+v", chunk)
+	}
+
+	if _, err := db.GetChunk(99999); !errors.Is(err, ErrChunkNotFound) {
+		t.Errorf("Expected ErrChunkNotFound, got %v", err)
+	}
+}
+
+func TestStats(t *testing.T) {
+	db, err := Init(t.TempDir()+"/test.db", 384)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	stats, err := db.Stats()
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if stats.Documents != 0 || stats.Chunks != 0 || stats.Vectors != 0 {
+		t.Errorf("Expected an empty index, got %+v", stats)
+	}
+	if stats.SchemaVersion != latestSchemaVersion || stats.Dimensions != 384 {
+		t.Errorf("Expected schema version %d and 384 dimensions, got %+v", latestSchemaVersion, stats)
+	}
+
+	insertContextFixture(t, db)
+	stats, err = db.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Documents != 1 || stats.Chunks != 10 || stats.Vectors != 10 {
+		t.Errorf("Expected 1 document with 10 chunks and vectors, got %+v", stats)
+	}
+}
@@ -0,0 +1,134 @@
+package vectordb
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Filter operators
+const (
+	OpEquals   = "="
+	OpIn       = "in"
+	OpContains = "contains"
+)
+
+// Filter restricts search results by document metadata
+// Field is one of domain, docType, language, project or tags
+type Filter struct {
+	Field    string
+	Operator string
+	Values   []string
+}
+
+// filterColumns maps scalar filter fields to their columns in document_metadata
+var filterColumns = map[string]string{
+	"domain":   "m.domain",
+	"doctype":  "m.doc_type",
+	"doc_type": "m.doc_type",
+	"language": "m.language",
+	"project":  "m.project",
+}
+
+var filterPattern = regexp.MustCompile(`(?i)^\s*([a-z_]+)\s*(?:(=)|\s(in|contains)\s)\s*(.*?)\s*$`)
+
+// ParseFilter parses a filter expression such as
+// "domain=backend", "docType in (spec, api)" or "tags contains auth"
+func ParseFilter(expr string) (Filter, error) {
+	m := filterPattern.FindStringSubmatch(expr)
+	if m == nil {
+		return Filter{}, fmt.Errorf("invalid filter expression: %q", expr)
+	}
+
+	filter := Filter{Field: m[1], Operator: OpEquals}
+	if m[3] != "" {
+		filter.Operator = strings.ToLower(m[3])
+	}
+
+	value := m[4]
+	if filter.Operator == OpIn {
+		if !strings.HasPrefix(value, "(") || !strings.HasSuffix(value, ")") {
+			return Filter{}, fmt.Errorf("invalid filter expression: %q (in requires a parenthesized list)", expr)
+		}
+		for _, v := range strings.Split(value[1:len(value)-1], ",") {
+			if v = unquote(v); v != "" {
+				filter.Values = append(filter.Values, v)
+			}
+		}
+	} else if v := unquote(value); v != "" {
+		filter.Values = []string{v}
+	}
+
+	if len(filter.Values) == 0 {
+		return Filter{}, fmt.Errorf("invalid filter expression: %q (missing value)", expr)
+	}
+
+	if err := filter.validate(); err != nil {
+		return Filter{}, err
+	}
+
+	return filter, nil
+}
+
+// validate checks that the field and operator are supported
+func (f Filter) validate() error {
+	if f.Operator != OpEquals && f.Operator != OpIn && f.Operator != OpContains {
+		return fmt.Errorf("unknown filter operator: %s", f.Operator)
+	}
+
+	field := strings.ToLower(f.Field)
+	if field == "tags" {
+		return nil
+	}
+	if _, ok := filterColumns[field]; !ok {
+		return fmt.Errorf("unknown filter field: %s", f.Field)
+	}
+	if f.Operator == OpContains {
+		return fmt.Errorf("contains is only supported for tags, got %s", f.Field)
+	}
+	return nil
+}
+
+// buildFilterClause converts filters into a SQL condition and its arguments
+// The condition expects documents aliased as d and document_metadata as m
+func buildFilterClause(filters []Filter) (string, []interface{}, error) {
+	if len(filters) == 0 {
+		return "1 = 1", nil, nil
+	}
+
+	conditions := make([]string, 0, len(filters))
+	var args []interface{}
+	for _, f := range filters {
+		if err := f.validate(); err != nil {
+			return "", nil, err
+		}
+		if len(f.Values) == 0 {
+			return "", nil, fmt.Errorf("filter on %s has no values", f.Field)
+		}
+
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(f.Values)), ", ")
+		for _, v := range f.Values {
+			args = append(args, v)
+		}
+
+		// For tags, = and contains both match a single tag and in matches any of the listed tags
+		field := strings.ToLower(f.Field)
+		if field == "tags" {
+			conditions = append(conditions, fmt.Sprintf(
+				"EXISTS (SELECT 1 FROM document_tags t WHERE t.document_id = d.id AND t.tag IN (%s))", placeholders))
+			continue
+		}
+		conditions = append(conditions, fmt.Sprintf("COALESCE(%s, '') IN (%s)", filterColumns[field], placeholders))
+	}
+
+	return strings.Join(conditions, " AND "), args, nil
+}
+
+// unquote trims whitespace and surrounding quotes from a filter value
+func unquote(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		s = s[1 : len(s)-1]
+	}
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,172 @@
+package vectordb
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		expr     string
+		field    string
+		operator string
+		values   []string
+	}{
+		{"domain=backend", "domain", OpEquals, []string{"backend"}},
+		{"domain = \"backend\"", "domain", OpEquals, []string{"backend"}},
+		{"docType in (spec, api)", "docType", OpIn, []string{"spec", "api"}},
+		{"docType IN ('spec','api')", "docType", OpIn, []string{"spec", "api"}},
+		{"tags contains auth", "tags", OpContains, []string{"auth"}},
+		{"tags in (auth, jwt)", "tags", OpIn, []string{"auth", "jwt"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			f, err := ParseFilter(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseFilter failed: %v", err)
+			}
+			if f.Field != tt.field || f.Operator != tt.operator {
+				t.Errorf("Expected %s %s, got %s %s", tt.field, tt.operator, f.Field, f.Operator)
+			}
+			if len(f.Values) != len(tt.values) {
+				t.Fatalf("Expected values %v, got %v", tt.values, f.Values)
+			}
+			for i := range tt.values {
+				if f.Values[i] != tt.values[i] {
+					t.Errorf("Expected values %v, got %v", tt.values, f.Values)
+				}
+			}
+		})
+	}
+}
+
+func TestParseFilter_Invalid(t *testing.T) {
+	exprs := []string{
+		"",
+		"domain",
+		"domain=",
+		"author=alice",
+		"domain contains back",
+		"docType in spec, api",
+		"docType in ()",
+	}
+
+	for _, expr := range exprs {
+		if _, err := ParseFilter(expr); err == nil {
+			t.Errorf("Expected error for %q, got nil", expr)
+		}
+	}
+}
+
+func TestSearch_WithFilters(t *testing.T) {
+	dbPath := t.TempDir() + "/test.db"
+
+	db, err := Init(dbPath, 384)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	docs := []struct {
+		filename string
+		metadata *DocumentMetadata
+	}{
+		{"backend-spec.md", &DocumentMetadata{Domain: "backend", DocType: "spec", Tags: []string{"auth", "jwt"}}},
+		{"backend-guide.md", &DocumentMetadata{Domain: "backend", DocType: "guide", Tags: []string{"database"}}},
+		{"frontend-api.md", &DocumentMetadata{Domain: "frontend", DocType: "api", Tags: []string{"auth"}}},
+		{"plain.md", nil},
+	}
+
+	for _, doc := range docs {
+		chunks := []ChunkInterface{testChunk{content: doc.filename, position: 0}}
+		embedding := make([]float32, 384)
+		embedding[0] = 1
+		if err := db.InsertDocument(doc.filename, time.Now(), "", doc.metadata, chunks, [][]float32{embedding}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	query := make([]float32, 384)
+	query[0] = 1
+
+	tests := []struct {
+		exprs []string
+		want  []string
+	}{
+		{nil, []string{"backend-spec.md", "backend-guide.md", "frontend-api.md", "plain.md"}},
+		{[]string{"domain=backend"}, []string{"backend-spec.md", "backend-guide.md"}},
+		{[]string{"docType in (spec, api)"}, []string{"backend-spec.md", "frontend-api.md"}},
+		{[]string{"tags contains auth"}, []string{"backend-spec.md", "frontend-api.md"}},
+		{[]string{"domain=backend", "tags contains auth"}, []string{"backend-spec.md"}},
+		{[]string{"domain=mobile"}, nil},
+	}
+
+	for _, tt := range tests {
+		var filters []Filter
+		for _, expr := range tt.exprs {
+			f, err := ParseFilter(expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			filters = append(filters, f)
+		}
+
+		results, err := db.Search(SearchQuery{Vector: query, TopK: 10, Filters: filters})
+		if err != nil {
+			t.Fatalf("Search with %v failed: %v", tt.exprs, err)
+		}
+
+		got := make(map[string]bool)
+		for _, r := range results {
+			got[r.DocumentName] = true
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("Filters %v: expected %v, got %v", tt.exprs, tt.want, got)
+			continue
+		}
+		for _, name := range tt.want {
+			if !got[name] {
+				t.Errorf("Filters %v: expected %s in results, got %v", tt.exprs, name, got)
+			}
+		}
+	}
+}
+
+func TestInsertDocument_ReplacesMetadata(t *testing.T) {
+	dbPath := t.TempDir() + "/test.db"
+
+	db, err := Init(dbPath, 384)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	chunks := []ChunkInterface{testChunk{content: "chunk", position: 0}}
+	embeddings := [][]float32{make([]float32, 384)}
+
+	err = db.InsertDocument("test.md", time.Now(), "", &DocumentMetadata{Domain: "backend", Tags: []string{"auth"}}, chunks, embeddings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.InsertDocument("test.md", time.Now(), "", &DocumentMetadata{Domain: "frontend"}, chunks, embeddings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var domain string
+	if err := db.conn.QueryRow("SELECT domain FROM document_metadata").Scan(&domain); err != nil {
+		t.Fatal(err)
+	}
+	if domain != "frontend" {
+		t.Errorf("Expected domain 'frontend', got %s", domain)
+	}
+
+	var tagCount int
+	if err := db.conn.QueryRow("SELECT COUNT(*) FROM document_tags").Scan(&tagCount); err != nil {
+		t.Fatal(err)
+	}
+	if tagCount != 0 {
+		t.Errorf("Expected old tags to be removed, got %d", tagCount)
+	}
+}
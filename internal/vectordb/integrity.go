@@ -0,0 +1,197 @@
+package vectordb
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+)
+
+// IntegrityReport lists inconsistencies between documents, chunks and vectors
+type IntegrityReport struct {
+	OrphanVectors          []int64    `json:"orphan_vectors"`           // vec_chunks rowids without a chunk
+	OrphanChunks           []int64    `json:"orphan_chunks"`            // chunk IDs whose document no longer exists
+	ChunksWithoutVectors   []ChunkRef `json:"chunks_without_vectors"`   // chunks that cannot be found by vector search
+	DocumentsWithoutChunks []string   `json:"documents_without_chunks"` // filenames of documents that have no chunks
+}
+
+// ChunkRef identifies a chunk and the document it belongs to
+type ChunkRef struct {
+	ID       int64  `json:"chunk_id"`
+	Document string `json:"document"`
+}
+
+// OK reports whether no inconsistencies were found
+func (r *IntegrityReport) OK() bool {
+	return len(r.OrphanVectors) == 0 && len(r.OrphanChunks) == 0 &&
+		len(r.ChunksWithoutVectors) == 0 && len(r.DocumentsWithoutChunks) == 0
+}
+
+// AffectedDocuments returns the documents removed by a repair, in sorted order
+// They must be re-indexed from their files to be searchable again
+func (r *IntegrityReport) AffectedDocuments() []string {
+	seen := make(map[string]bool)
+	var docs []string
+	for _, chunk := range r.ChunksWithoutVectors {
+		if !seen[chunk.Document] {
+			seen[chunk.Document] = true
+			docs = append(docs, chunk.Document)
+		}
+	}
+	for _, doc := range r.DocumentsWithoutChunks {
+		if !seen[doc] {
+			seen[doc] = true
+			docs = append(docs, doc)
+		}
+	}
+	sort.Strings(docs)
+	return docs
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx
+type querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// CheckIntegrity reports orphan vectors and chunks, chunks without vectors and
+// documents without chunks without modifying the index
+func (db *DB) CheckIntegrity() (*IntegrityReport, error) {
+	return checkIntegrity(db.conn)
+}
+
+// RepairIntegrity fixes the problems found by CheckIntegrity in one transaction
+// Orphan vectors and chunks are deleted. Documents with missing vectors or no
+// chunks are removed entirely so that the next sync re-indexes them from their
+// files. The returned report lists what was repaired.
+func (db *DB) RepairIntegrity() (*IntegrityReport, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	report, err := checkIntegrity(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range report.OrphanChunks {
+		if _, err := tx.Exec("DELETE FROM vec_chunks WHERE rowid = ?", id); err != nil {
+			return nil, fmt.Errorf("failed to delete vector of chunk %d: %w", id, err)
+		}
+		if _, err := tx.Exec("DELETE FROM chunks WHERE id = ?", id); err != nil {
+			return nil, fmt.Errorf("failed to delete orphan chunk %d: %w", id, err)
+		}
+	}
+
+	for _, id := range report.OrphanVectors {
+		if _, err := tx.Exec("DELETE FROM vec_chunks WHERE rowid = ?", id); err != nil {
+			return nil, fmt.Errorf("failed to delete orphan vector %d: %w", id, err)
+		}
+	}
+
+	for _, filename := range report.AffectedDocuments() {
+		if err := deleteDocument(tx, filename); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return report, nil
+}
+
+// checkIntegrity runs the integrity queries against a connection or transaction
+func checkIntegrity(q querier) (*IntegrityReport, error) {
+	report := &IntegrityReport{
+		OrphanVectors:          []int64{},
+		OrphanChunks:           []int64{},
+		ChunksWithoutVectors:   []ChunkRef{},
+		DocumentsWithoutChunks: []string{},
+	}
+
+	err := queryRows(q, `
+		SELECT rowid FROM vec_chunks
+		WHERE rowid NOT IN (SELECT id FROM chunks)
+		ORDER BY rowid
+	`, func(rows *sql.Rows) error {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		report.OrphanVectors = append(report.OrphanVectors, id)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find orphan vectors: %w", err)
+	}
+
+	err = queryRows(q, `
+		SELECT id FROM chunks
+		WHERE document_id NOT IN (SELECT id FROM documents)
+		ORDER BY id
+	`, func(rows *sql.Rows) error {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		report.OrphanChunks = append(report.OrphanChunks, id)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find orphan chunks: %w", err)
+	}
+
+	err = queryRows(q, `
+		SELECT c.id, d.filename
+		FROM chunks c
+		JOIN documents d ON c.document_id = d.id
+		WHERE c.id NOT IN (SELECT rowid FROM vec_chunks)
+		ORDER BY d.filename, c.position
+	`, func(rows *sql.Rows) error {
+		var chunk ChunkRef
+		if err := rows.Scan(&chunk.ID, &chunk.Document); err != nil {
+			return err
+		}
+		report.ChunksWithoutVectors = append(report.ChunksWithoutVectors, chunk)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find chunks without vectors: %w", err)
+	}
+
+	err = queryRows(q, `
+		SELECT filename FROM documents d
+		WHERE NOT EXISTS (SELECT 1 FROM chunks c WHERE c.document_id = d.id)
+		ORDER BY filename
+	`, func(rows *sql.Rows) error {
+		var filename string
+		if err := rows.Scan(&filename); err != nil {
+			return err
+		}
+		report.DocumentsWithoutChunks = append(report.DocumentsWithoutChunks, filename)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find documents without chunks: %w", err)
+	}
+
+	return report, nil
+}
+
+// queryRows runs a query and calls scan for each row
+func queryRows(q querier, query string, scan func(*sql.Rows) error, args ...interface{}) error {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,159 @@
+package vectordb
+
+import (
+	"testing"
+	"time"
+)
+
+// setupInconsistentDB creates an index with one healthy document and one of each
+// kind of inconsistency
+func setupInconsistentDB(t *testing.T) *DB {
+	t.Helper()
+	dbPath := t.TempDir() + "/test.db"
+
+	db, err := Init(dbPath, 384)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	embedding := make([]float32, 384)
+	embedding[0] = 1
+	for _, filename := range []string{"healthy.md", "missing_vector.md"} {
+		chunks := []ChunkInterface{
+			testChunk{content: filename + " chunk 1", position: 0},
+			testChunk{content: filename + " chunk 2", position: 1},
+		}
+		if err := db.InsertDocument(filename, time.Now(), "", nil, chunks, [][]float32{embedding, embedding}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var missingID int64
+	if err := db.conn.QueryRow(
+		"SELECT c.id FROM chunks c JOIN documents d ON c.document_id = d.id WHERE d.filename = 'missing_vector.md' AND c.position = 1",
+	).Scan(&missingID); err != nil {
+		t.Fatal(err)
+	}
+
+	statements := []struct {
+		query string
+		args  []interface{}
+	}{
+		{"DELETE FROM vec_chunks WHERE rowid = ?", []interface{}{missingID}},
+		{"INSERT INTO vec_chunks (rowid, embedding) VALUES (9999, ?)", []interface{}{serializeVector(embedding)}},
+		{"INSERT INTO documents (filename, modified_at) VALUES ('empty.md', ?)", []interface{}{time.Now()}},
+	}
+	for _, s := range statements {
+		if _, err := db.conn.Exec(s.query, s.args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Orphan chunks can only be created with foreign keys disabled
+	raw := openRaw(t, dbPath)
+	defer raw.Close()
+	if _, err := raw.Exec("INSERT INTO chunks (id, document_id, position, content) VALUES (8888, 7777, 0, 'orphan')"); err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func TestCheckIntegrity_Clean(t *testing.T) {
+	db, err := Init(t.TempDir()+"/test.db", 384)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	chunks := []ChunkInterface{testChunk{content: "chunk", position: 0}}
+	if err := db.InsertDocument("test.md", time.Now(), "", nil, chunks, [][]float32{make([]float32, 384)}); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := db.CheckIntegrity()
+	if err != nil {
+		t.Fatalf("CheckIntegrity failed: %v", err)
+	}
+	if !report.OK() {
+		t.Errorf("Expected a consistent index, got %+v", report)
+	}
+}
+
+func TestCheckIntegrity_FindsProblems(t *testing.T) {
+	db := setupInconsistentDB(t)
+	defer db.Close()
+
+	report, err := db.CheckIntegrity()
+	if err != nil {
+		t.Fatalf("CheckIntegrity failed: %v", err)
+	}
+
+	if report.OK() {
+		t.Fatal("Expected problems to be reported")
+	}
+	if len(report.OrphanVectors) != 1 || report.OrphanVectors[0] != 9999 {
+		t.Errorf("Expected orphan vector 9999, got %v", report.OrphanVectors)
+	}
+	if len(report.OrphanChunks) != 1 || report.OrphanChunks[0] != 8888 {
+		t.Errorf("Expected orphan chunk 8888, got %v", report.OrphanChunks)
+	}
+	if len(report.ChunksWithoutVectors) != 1 || report.ChunksWithoutVectors[0].Document != "missing_vector.md" {
+		t.Errorf("Expected one chunk without vector in missing_vector.md, got %+v", report.ChunksWithoutVectors)
+	}
+	if len(report.DocumentsWithoutChunks) != 1 || report.DocumentsWithoutChunks[0] != "empty.md" {
+		t.Errorf("Expected empty.md without chunks, got %v", report.DocumentsWithoutChunks)
+	}
+
+	affected := report.AffectedDocuments()
+	if len(affected) != 2 || affected[0] != "empty.md" || affected[1] != "missing_vector.md" {
+		t.Errorf("Expected [empty.md missing_vector.md], got %v", affected)
+	}
+
+	// Checking does not modify the index
+	docs, err := db.ListDocuments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 3 {
+		t.Errorf("Expected 3 documents after check, got %d", len(docs))
+	}
+}
+
+func TestRepairIntegrity(t *testing.T) {
+	db := setupInconsistentDB(t)
+	defer db.Close()
+
+	repaired, err := db.RepairIntegrity()
+	if err != nil {
+		t.Fatalf("RepairIntegrity failed: %v", err)
+	}
+	if repaired.OK() {
+		t.Error("Expected the repair report to list the fixed problems")
+	}
+
+	report, err := db.CheckIntegrity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() {
+		t.Errorf("Expected a consistent index after repair, got %+v", report)
+	}
+
+	// Only the healthy document is kept
+	docs, err := db.ListDocuments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := docs["healthy.md"]; !ok || len(docs) != 1 {
+		t.Errorf("Expected only healthy.md after repair, got %v", docs)
+	}
+
+	var vectorCount int
+	if err := db.conn.QueryRow("SELECT COUNT(*) FROM vec_chunks").Scan(&vectorCount); err != nil {
+		t.Fatal(err)
+	}
+	if vectorCount != 2 {
+		t.Errorf("Expected the 2 vectors of healthy.md, got %d", vectorCount)
+	}
+}
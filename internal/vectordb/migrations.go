@@ -0,0 +1,248 @@
+package vectordb
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// migration upgrades the schema by one version
+type migration struct {
+	version     int
+	description string
+	apply       func(tx *sql.Tx, dimensions int) error
+}
+
+// migrations lists every schema change in order; the last version is the current schema
+// Databases created before versioning report user_version 0 and may be at any
+// point of this history, so every migration must be safe to apply to a schema
+// that already contains its change
+var migrations = []migration{
+	{1, "create documents, chunks and vectors", func(tx *sql.Tx, dimensions int) error {
+		return execStatements(tx, `
+CREATE TABLE IF NOT EXISTS documents (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    filename TEXT NOT NULL UNIQUE,
+    indexed_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    modified_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_filename ON documents(filename);
+
+CREATE TABLE IF NOT EXISTS chunks (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    document_id INTEGER NOT NULL,
+    position INTEGER NOT NULL,
+    content TEXT NOT NULL,
+    FOREIGN KEY (document_id) REFERENCES documents(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_document_id ON chunks(document_id);
+`, fmt.Sprintf(`
+CREATE VIRTUAL TABLE IF NOT EXISTS vec_chunks USING vec0(
+    embedding FLOAT[%d]
+);
+`, dimensions))
+	}},
+	{2, "add document metadata and tags", func(tx *sql.Tx, dimensions int) error {
+		return execStatements(tx, `
+CREATE TABLE IF NOT EXISTS document_metadata (
+    document_id INTEGER PRIMARY KEY,
+    domain TEXT NOT NULL DEFAULT '',
+    doc_type TEXT NOT NULL DEFAULT '',
+    language TEXT NOT NULL DEFAULT '',
+    project TEXT NOT NULL DEFAULT '',
+    FOREIGN KEY (document_id) REFERENCES documents(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS document_tags (
+    document_id INTEGER NOT NULL,
+    tag TEXT NOT NULL,
+    PRIMARY KEY (document_id, tag),
+    FOREIGN KEY (document_id) REFERENCES documents(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_document_tags_tag ON document_tags(tag);
+`)
+	}},
+	{3, "use cosine distance for vectors", func(tx *sql.Tx, dimensions int) error {
+		return upgradeVectorTable(tx)
+	}},
+	{4, "add index metadata", func(tx *sql.Tx, dimensions int) error {
+		return execStatements(tx, `
+CREATE TABLE IF NOT EXISTS index_metadata (
+    key TEXT PRIMARY KEY,
+    value TEXT NOT NULL
+);
+`)
+	}},
+	{5, "add chunk heading paths", func(tx *sql.Tx, dimensions int) error {
+		return addColumnIfMissing(tx, "chunks", "heading_path", "TEXT NOT NULL DEFAULT ''")
+	}},
+	{6, "add document content hashes", func(tx *sql.Tx, dimensions int) error {
+		return addColumnIfMissing(tx, "documents", "content_hash", "TEXT NOT NULL DEFAULT ''")
+	}},
+	{7, "add chunk content hashes", func(tx *sql.Tx, dimensions int) error {
+		if err := addColumnIfMissing(tx, "chunks", "content_hash", "TEXT NOT NULL DEFAULT ''"); err != nil {
+			return err
+		}
+		return execStatements(tx, `
+CREATE INDEX IF NOT EXISTS idx_chunks_content_hash ON chunks(content_hash);
+`)
+	}},
+	{8, "add audit log", func(tx *sql.Tx, dimensions int) error {
+		return execStatements(tx, `
+CREATE TABLE IF NOT EXISTS audit_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME NOT NULL,
+    tool TEXT NOT NULL,
+    session TEXT NOT NULL DEFAULT '',
+    arguments TEXT NOT NULL DEFAULT '{}',
+    document TEXT NOT NULL DEFAULT '',
+    before TEXT NOT NULL DEFAULT '',
+    after TEXT NOT NULL DEFAULT '',
+    content_hash TEXT NOT NULL DEFAULT '',
+    error TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_document ON audit_log(document);
+
+CREATE TRIGGER IF NOT EXISTS audit_log_no_update BEFORE UPDATE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
+
+CREATE TRIGGER IF NOT EXISTS audit_log_no_delete BEFORE DELETE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
+`)
+	}},
+}
+
+// latestSchemaVersion is the schema version written by this build
+var latestSchemaVersion = migrations[len(migrations)-1].version
+
+// migrate applies all migrations newer than the database's schema version
+func migrate(conn *sql.DB, dimensions int) error {
+	return migrateTo(conn, dimensions, latestSchemaVersion)
+}
+
+// migrateTo applies migrations up to and including the target version
+// Each migration runs in its own transaction together with the version bump,
+// so a failed migration leaves the database at the previous version
+func migrateTo(conn *sql.DB, dimensions, target int) error {
+	current, err := schemaVersion(conn)
+	if err != nil {
+		return err
+	}
+	if current > latestSchemaVersion {
+		return fmt.Errorf("database schema version %d is newer than the supported version %d, please upgrade devrag", current, latestSchemaVersion)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current || m.version > target {
+			continue
+		}
+
+		fmt.Fprintf(os.Stderr, "[INFO] Migrating database schema to version %d: %s\n", m.version, m.description)
+
+		tx, err := conn.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+
+		if err := m.apply(tx, dimensions); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to apply migration %d (%s): %w", m.version, m.description, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", m.version)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to set schema version %d: %w", m.version, err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit migration %d: %w", m.version, err)
+		}
+	}
+
+	return nil
+}
+
+// schemaVersion returns the schema version recorded in the database
+func schemaVersion(conn *sql.DB) (int, error) {
+	var version int
+	if err := conn.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return version, nil
+}
+
+// execStatements executes SQL scripts within a migration
+func execStatements(tx *sql.Tx, scripts ...string) error {
+	for _, script := range scripts {
+		if _, err := tx.Exec(script); err != nil {
+			return fmt.Errorf("failed to execute %q: %w", strings.TrimSpace(script), err)
+		}
+	}
+	return nil
+}
+
+// upgradeVectorTable recreates vec_chunks with distance_metric=cosine
+// vec0 tables default to L2 distance and cannot be altered, so vectors are copied
+// through a temporary table
+func upgradeVectorTable(tx *sql.Tx) error {
+	var ddl string
+	if err := tx.QueryRow("SELECT sql FROM sqlite_master WHERE name = 'vec_chunks'").Scan(&ddl); err != nil {
+		return fmt.Errorf("failed to read vec_chunks schema: %w", err)
+	}
+	if strings.Contains(ddl, "distance_metric=cosine") {
+		return nil
+	}
+
+	dimensions, err := parseVecDimensions(ddl)
+	if err != nil {
+		return err
+	}
+
+	return execStatements(tx,
+		"CREATE TEMP TABLE vec_chunks_backup AS SELECT rowid AS id, embedding FROM vec_chunks",
+		"DROP TABLE vec_chunks",
+		vecTableSQL(dimensions),
+		"INSERT INTO vec_chunks (rowid, embedding) SELECT id, embedding FROM vec_chunks_backup",
+		"DROP TABLE vec_chunks_backup",
+	)
+}
+
+// addColumnIfMissing adds a column to a table created by an older schema
+func addColumnIfMissing(tx *sql.Tx, table, column, definition string) error {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("failed to read %s schema: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid, notNull, pk int
+			name, colType    string
+			dflt             sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dflt, &pk); err != nil {
+			return fmt.Errorf("failed to scan %s schema: %w", table, err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read %s schema: %w", table, err)
+	}
+	rows.Close()
+
+	if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return fmt.Errorf("failed to add column %s.%s: %w", table, column, err)
+	}
+	return nil
+}
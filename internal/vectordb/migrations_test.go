@@ -0,0 +1,199 @@
+package vectordb
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	sqlite_vec "github.com/asg017/sqlite-vec-go-bindings/cgo"
+)
+
+// openRaw opens a database without running migrations
+func openRaw(t *testing.T, dbPath string) *sql.DB {
+	t.Helper()
+	sqlite_vec.Auto()
+	conn, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+// seedFixture inserts one document with one chunk and its vector
+// using only the columns present in every schema version
+func seedFixture(t *testing.T, conn *sql.DB) []float32 {
+	t.Helper()
+	embedding := make([]float32, 384)
+	embedding[0] = 1
+
+	statements := []struct {
+		query string
+		args  []interface{}
+	}{
+		{"INSERT INTO documents (id, filename, modified_at) VALUES (1, 'doc.md', ?)", []interface{}{time.Now()}},
+		{"INSERT INTO chunks (id, document_id, position, content) VALUES (1, 1, 0, 'fixture chunk')", nil},
+		{"INSERT INTO vec_chunks (rowid, embedding) VALUES (1, ?)", []interface{}{serializeVector(embedding)}},
+	}
+	for _, s := range statements {
+		if _, err := conn.Exec(s.query, s.args...); err != nil {
+			t.Fatalf("failed to seed fixture: %v", err)
+		}
+	}
+	return embedding
+}
+
+// assertMigrated checks that a database opened by Init is at the latest schema
+// version and still serves the fixture data
+func assertMigrated(t *testing.T, db *DB, query []float32) {
+	t.Helper()
+
+	version, err := schemaVersion(db.conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != latestSchemaVersion {
+		t.Errorf("Expected schema version %d, got %d", latestSchemaVersion, version)
+	}
+
+	var ddl string
+	if err := db.conn.QueryRow("SELECT sql FROM sqlite_master WHERE name = 'vec_chunks'").Scan(&ddl); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(ddl, "distance_metric=cosine") {
+		t.Errorf("Expected cosine vector table, got %s", ddl)
+	}
+
+	results, err := db.Search(SearchQuery{Vector: query, TopK: 1})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 1 || results[0].ChunkContent != "fixture chunk" {
+		t.Errorf("Expected fixture chunk to survive migration, got %+v", results)
+	}
+
+	// Columns added by later migrations are writable
+	chunks := []ChunkInterface{testChunk{content: "new chunk", position: 0, headingPath: "Intro"}}
+	if err := db.InsertDocument("new.md", time.Now(), "hash", &DocumentMetadata{Domain: "backend"}, chunks, [][]float32{query}); err != nil {
+		t.Errorf("InsertDocument failed after migration: %v", err)
+	}
+	if err := db.SetIndexMetadata("key", "value"); err != nil {
+		t.Errorf("SetIndexMetadata failed after migration: %v", err)
+	}
+}
+
+// TestMigrate_UnversionedFixtures upgrades databases created before schema
+// versioning, one fixture for each schema that was released without a version
+func TestMigrate_UnversionedFixtures(t *testing.T) {
+	fixtures, err := filepath.Glob(filepath.Join("testdata", "unversioned_*.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixtures) == 0 {
+		t.Fatal("No fixtures found in testdata")
+	}
+
+	for _, fixture := range fixtures {
+		t.Run(filepath.Base(fixture), func(t *testing.T) {
+			schema, err := os.ReadFile(fixture)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			dbPath := t.TempDir() + "/test.db"
+			conn := openRaw(t, dbPath)
+			if _, err := conn.Exec(string(schema)); err != nil {
+				t.Fatalf("failed to create fixture: %v", err)
+			}
+			query := seedFixture(t, conn)
+			conn.Close()
+
+			db, err := Init(dbPath, 384)
+			if err != nil {
+				t.Fatalf("Init failed: %v", err)
+			}
+			defer db.Close()
+
+			assertMigrated(t, db, query)
+		})
+	}
+}
+
+// TestMigrate_FromEachVersion upgrades a database from every earlier version
+func TestMigrate_FromEachVersion(t *testing.T) {
+	for version := 1; version < latestSchemaVersion; version++ {
+		t.Run(fmt.Sprintf("v%d", version), func(t *testing.T) {
+			dbPath := t.TempDir() + "/test.db"
+			conn := openRaw(t, dbPath)
+			if err := migrateTo(conn, 384, version); err != nil {
+				t.Fatalf("failed to create version %d: %v", version, err)
+			}
+			query := seedFixture(t, conn)
+			conn.Close()
+
+			db, err := Init(dbPath, 384)
+			if err != nil {
+				t.Fatalf("Init failed: %v", err)
+			}
+			defer db.Close()
+
+			assertMigrated(t, db, query)
+		})
+	}
+}
+
+func TestMigrate_RejectsNewerVersion(t *testing.T) {
+	dbPath := t.TempDir() + "/test.db"
+	conn := openRaw(t, dbPath)
+	if _, err := conn.Exec(fmt.Sprintf("PRAGMA user_version = %d", latestSchemaVersion+1)); err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	if _, err := Init(dbPath, 384); err == nil {
+		t.Error("Expected error for a database from a newer version")
+	}
+}
+
+func TestMigrate_FailedMigrationRollsBack(t *testing.T) {
+	dbPath := t.TempDir() + "/test.db"
+	conn := openRaw(t, dbPath)
+	defer conn.Close()
+
+	saved := migrations
+	defer func() { migrations = saved }()
+	migrations = append(append([]migration{}, saved...), migration{
+		version:     latestSchemaVersion + 1,
+		description: "broken",
+		apply: func(tx *sql.Tx, dimensions int) error {
+			if _, err := tx.Exec("CREATE TABLE half_done (id INTEGER)"); err != nil {
+				return err
+			}
+			_, err := tx.Exec("THIS IS NOT SQL")
+			return err
+		},
+	})
+
+	if err := migrateTo(conn, 384, latestSchemaVersion+1); err == nil {
+		t.Fatal("Expected the broken migration to fail")
+	}
+
+	version, err := schemaVersion(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != latestSchemaVersion {
+		t.Errorf("Expected version to stay at %d, got %d", latestSchemaVersion, version)
+	}
+
+	var count int
+	if err := conn.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'half_done'").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Error("Expected the partial migration to be rolled back")
+	}
+}
@@ -1,26 +1,39 @@
 package vectordb
 
-const schemaSQL = `
-CREATE TABLE IF NOT EXISTS documents (
-    id INTEGER PRIMARY KEY AUTOINCREMENT,
-    filename TEXT NOT NULL UNIQUE,
-    indexed_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-    modified_at DATETIME NOT NULL
-);
+import "fmt"
 
-CREATE INDEX IF NOT EXISTS idx_filename ON documents(filename);
+// vecTableSQL creates the vector table at the embedding dimension of the model
+// The cosine metric is declared so that KNN queries return cosine distance
+func vecTableSQL(dimensions int) string {
+	return fmt.Sprintf(`
+CREATE VIRTUAL TABLE IF NOT EXISTS vec_chunks USING vec0(
+    embedding FLOAT[%d] distance_metric=cosine
+);
+`, dimensions)
+}
 
-CREATE TABLE IF NOT EXISTS chunks (
-    id INTEGER PRIMARY KEY AUTOINCREMENT,
-    document_id INTEGER NOT NULL,
-    position INTEGER NOT NULL,
-    content TEXT NOT NULL,
-    FOREIGN KEY (document_id) REFERENCES documents(id) ON DELETE CASCADE
+// ftsSchemaSQL mirrors chunks into an external-content FTS5 table via triggers
+// It is only applied when SQLite is built with FTS5 (go build -tags sqlite_fts5)
+const ftsSchemaSQL = `
+CREATE VIRTUAL TABLE IF NOT EXISTS chunks_fts USING fts5(
+    content,
+    content='chunks',
+    content_rowid='id'
 );
 
-CREATE INDEX IF NOT EXISTS idx_document_id ON chunks(document_id);
+CREATE TRIGGER IF NOT EXISTS chunks_fts_insert AFTER INSERT ON chunks BEGIN
+    INSERT INTO chunks_fts(rowid, content) VALUES (new.id, new.content);
+END;
 
-CREATE VIRTUAL TABLE IF NOT EXISTS vec_chunks USING vec0(
-    embedding FLOAT[384]
-);
+CREATE TRIGGER IF NOT EXISTS chunks_fts_delete AFTER DELETE ON chunks BEGIN
+    INSERT INTO chunks_fts(chunks_fts, rowid, content) VALUES ('delete', old.id, old.content);
+END;
+
+CREATE TRIGGER IF NOT EXISTS chunks_fts_update AFTER UPDATE ON chunks BEGIN
+    INSERT INTO chunks_fts(chunks_fts, rowid, content) VALUES ('delete', old.id, old.content);
+    INSERT INTO chunks_fts(rowid, content) VALUES (new.id, new.content);
+END;
 `
+
+// ftsTriggers lists the triggers that keep chunks_fts in sync with chunks
+var ftsTriggers = []string{"chunks_fts_insert", "chunks_fts_delete", "chunks_fts_update"}
@@ -2,58 +2,196 @@ package vectordb
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 )
 
+// SearchMode selects how candidate chunks are retrieved
+type SearchMode string
+
+const (
+	// ModeVector ranks chunks by cosine similarity to the query vector
+	ModeVector SearchMode = "vector"
+	// ModeKeyword ranks chunks by BM25 over the FTS5 index
+	ModeKeyword SearchMode = "keyword"
+	// ModeHybrid fuses vector and keyword rankings with reciprocal rank fusion
+	ModeHybrid SearchMode = "hybrid"
+)
+
+// rrfK is the rank constant used by reciprocal rank fusion
+const rrfK = 60
+
+// maxKNN is the largest k accepted by vec0 KNN queries
+const maxKNN = 4096
+
+// hybridCandidateFactor controls how many candidates each ranking contributes to fusion
+const hybridCandidateFactor = 4
+
+// ParseSearchMode converts a mode name to a SearchMode
+// An empty name selects vector search
+func ParseSearchMode(name string) (SearchMode, error) {
+	switch SearchMode(strings.ToLower(strings.TrimSpace(name))) {
+	case "", ModeVector:
+		return ModeVector, nil
+	case ModeKeyword:
+		return ModeKeyword, nil
+	case ModeHybrid:
+		return ModeHybrid, nil
+	default:
+		return "", fmt.Errorf("unknown search mode: %s (expected vector, keyword or hybrid)", name)
+	}
+}
+
+// SearchQuery describes a search against the index
+type SearchQuery struct {
+	Text    string    // query text, required for keyword and hybrid modes
+	Vector  []float32 // query vector, required for vector and hybrid modes
+	TopK    int
+	Mode    SearchMode // defaults to ModeVector
+	Filters []Filter   // optional metadata filters
+
+	// ContextWindow adds up to this many neighboring chunks before and after each hit
+	ContextWindow int
+}
+
 // SearchResult represents a single search result
 type SearchResult struct {
+	ChunkID      int64
 	DocumentName string
 	ChunkContent string
 	Similarity   float64
+	Score        float64
 	Position     int
+	HeadingPath  string // section breadcrumb such as "Auth > JWT > Refresh tokens"
+
+	// Set when SearchQuery.ContextWindow is positive: the hit and its neighbors,
+	// covering chunk positions ContextStart to ContextEnd
+	Context      string
+	ContextStart int
+	ContextEnd   int
 }
 
-// Search performs vector similarity search using cosine distance
-// Returns top-K most similar chunks to the query vector
-func (db *DB) Search(queryVector []float32, topK int) ([]SearchResult, error) {
-	if len(queryVector) == 0 {
-		return nil, fmt.Errorf("query vector is empty")
+// Search performs a search in the requested mode and returns the top-K chunks
+// Similarity is the cosine similarity to the query vector (0 in keyword mode)
+// Score is the ranking score of the mode: similarity, BM25 relevance or fused RRF score
+func (db *DB) Search(q SearchQuery) ([]SearchResult, error) {
+	if q.TopK <= 0 {
+		return nil, fmt.Errorf("topK must be positive, got %d", q.TopK)
 	}
-	if topK <= 0 {
-		return nil, fmt.Errorf("topK must be positive, got %d", topK)
+
+	mode := q.Mode
+	if mode == "" {
+		mode = ModeVector
+	}
+
+	if len(q.Vector) > 0 && len(q.Vector) != db.dimensions {
+		return nil, fmt.Errorf("query vector has %d dimensions, index expects %d", len(q.Vector), db.dimensions)
+	}
+
+	if q.ContextWindow < 0 {
+		return nil, fmt.Errorf("context window must not be negative, got %d", q.ContextWindow)
+	}
+
+	var results []SearchResult
+	var err error
+
+	switch mode {
+	case ModeVector:
+		if len(q.Vector) == 0 {
+			return nil, fmt.Errorf("query vector is empty")
+		}
+		results, err = db.vectorSearch(q.Vector, q.TopK, q.Filters)
+
+	case ModeKeyword:
+		if !db.fts {
+			return nil, errFTSUnavailable
+		}
+		results, err = db.keywordSearch(q.Text, nil, q.TopK, q.Filters)
+
+	case ModeHybrid:
+		if !db.fts {
+			return nil, errFTSUnavailable
+		}
+		if len(q.Vector) == 0 {
+			return nil, fmt.Errorf("query vector is empty")
+		}
+		results, err = db.hybridSearch(q.Text, q.Vector, q.TopK, q.Filters)
+
+	default:
+		return nil, fmt.Errorf("unknown search mode: %s", mode)
+	}
+
+	if err != nil || q.ContextWindow == 0 {
+		return results, err
+	}
+	return db.expandContext(results, q.ContextWindow)
+}
+
+// vectorSearch performs KNN search over the vec0 index
+// Filters are applied inside the KNN query by restricting candidate rowids,
+// so filtered searches still return up to topK matching chunks
+func (db *DB) vectorSearch(queryVector []float32, topK int, filters []Filter) ([]SearchResult, error) {
+	// vec0 rejects k values above its limit
+	k := topK
+	if k > maxKNN {
+		k = maxKNN
 	}
 
 	// Serialize query vector to format expected by sqlite-vec
-	queryBlob := serializeVector(queryVector)
+	args := []interface{}{serializeVector(queryVector), k}
 
-	// Execute similarity search query
-	// vec_distance_cosine returns distance where smaller values = more similar
+	candidateClause := ""
+	if len(filters) > 0 {
+		filterClause, filterArgs, err := buildFilterClause(filters)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter: %w", err)
+		}
+		candidateClause = `
+				AND rowid IN (
+					SELECT c.id
+					FROM chunks c
+					JOIN documents d ON c.document_id = d.id
+					LEFT JOIN document_metadata m ON m.document_id = d.id
+					WHERE ` + filterClause + `
+				)`
+		args = append(args, filterArgs...)
+	}
+
+	// vec_chunks is declared with distance_metric=cosine, so distance is cosine distance
 	// Distance range: 0 (identical) to 2 (opposite direction)
 	query := `
+		WITH knn AS (
+			SELECT rowid, distance
+			FROM vec_chunks
+			WHERE embedding MATCH ?
+				AND k = ?` + candidateClause + `
+		)
 		SELECT
+			c.id,
 			d.filename,
 			c.content,
 			c.position,
-			vec_distance_cosine(v.embedding, ?) as distance
-		FROM vec_chunks v
-		JOIN chunks c ON v.rowid = c.id
+			c.heading_path,
+			knn.distance
+		FROM knn
+		JOIN chunks c ON knn.rowid = c.id
 		JOIN documents d ON c.document_id = d.id
-		ORDER BY distance ASC
-		LIMIT ?
+		ORDER BY knn.distance ASC
 	`
 
-	rows, err := db.conn.Query(query, queryBlob, topK)
+	rows, err := db.conn.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute search query: %w", err)
 	}
 	defer rows.Close()
 
 	// Collect results
-	results := make([]SearchResult, 0, topK)
+	results := make([]SearchResult, 0, k)
 	for rows.Next() {
 		var result SearchResult
 		var distance float64
 
-		err := rows.Scan(&result.DocumentName, &result.ChunkContent, &result.Position, &distance)
+		err := rows.Scan(&result.ChunkID, &result.DocumentName, &result.ChunkContent, &result.Position, &result.HeadingPath, &distance)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan result row: %w", err)
 		}
@@ -62,6 +200,7 @@ func (db *DB) Search(queryVector []float32, topK int) ([]SearchResult, error) {
 		// Cosine distance: 0 = same direction, 2 = opposite direction
 		// Similarity: 1 - (distance/2) gives us a 0-1 range where 1 = identical
 		result.Similarity = 1.0 - (distance / 2.0)
+		result.Score = result.Similarity
 
 		results = append(results, result)
 	}
@@ -72,3 +211,150 @@ func (db *DB) Search(queryVector []float32, topK int) ([]SearchResult, error) {
 
 	return results, nil
 }
+
+// keywordSearch ranks chunks by BM25 over the FTS5 index
+// If queryVector is given, the cosine similarity of each hit is filled in as well
+func (db *DB) keywordSearch(text string, queryVector []float32, topK int, filters []Filter) ([]SearchResult, error) {
+	match := buildMatchQuery(text)
+	if match == "" {
+		return nil, fmt.Errorf("query text is empty")
+	}
+
+	filterClause, filterArgs, err := buildFilterClause(filters)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter: %w", err)
+	}
+
+	// bm25() returns lower values for better matches
+	distanceExpr := "NULL"
+	var args []interface{}
+	if len(queryVector) > 0 {
+		distanceExpr = "vec_distance_cosine(v.embedding, ?)"
+		args = append(args, serializeVector(queryVector))
+	}
+
+	query := `
+		SELECT
+			c.id,
+			d.filename,
+			c.content,
+			c.position,
+			c.heading_path,
+			bm25(chunks_fts) as rank,
+			` + distanceExpr + ` as distance
+		FROM chunks_fts
+		JOIN chunks c ON c.id = chunks_fts.rowid
+		JOIN documents d ON c.document_id = d.id
+		LEFT JOIN document_metadata m ON m.document_id = d.id
+		LEFT JOIN vec_chunks v ON v.rowid = c.id
+		WHERE chunks_fts MATCH ? AND ` + filterClause + `
+		ORDER BY rank ASC
+		LIMIT ?
+	`
+
+	args = append(args, match)
+	args = append(args, filterArgs...)
+	args = append(args, topK)
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute keyword search query: %w", err)
+	}
+	defer rows.Close()
+
+	results := make([]SearchResult, 0, topK)
+	for rows.Next() {
+		var result SearchResult
+		var rank float64
+		var distance *float64
+
+		err := rows.Scan(&result.ChunkID, &result.DocumentName, &result.ChunkContent, &result.Position, &result.HeadingPath, &rank, &distance)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan result row: %w", err)
+		}
+
+		result.Score = -rank
+		if distance != nil {
+			result.Similarity = 1.0 - (*distance / 2.0)
+		}
+
+		results = append(results, result)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating result rows: %w", err)
+	}
+
+	return results, nil
+}
+
+// hybridSearch fuses vector and keyword rankings with reciprocal rank fusion
+func (db *DB) hybridSearch(text string, queryVector []float32, topK int, filters []Filter) ([]SearchResult, error) {
+	candidates := topK * hybridCandidateFactor
+
+	vectorResults, err := db.vectorSearch(queryVector, candidates, filters)
+	if err != nil {
+		return nil, err
+	}
+
+	var keywordResults []SearchResult
+	if buildMatchQuery(text) != "" {
+		keywordResults, err = db.keywordSearch(text, queryVector, candidates, filters)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return fuseRRF(topK, vectorResults, keywordResults), nil
+}
+
+// fuseRRF merges ranked result lists by summing 1/(rrfK + rank) per chunk
+func fuseRRF(topK int, lists ...[]SearchResult) []SearchResult {
+	fused := make(map[int64]*SearchResult)
+	for _, list := range lists {
+		for rank, r := range list {
+			entry, ok := fused[r.ChunkID]
+			if !ok {
+				copied := r
+				copied.Score = 0
+				entry = &copied
+				fused[r.ChunkID] = entry
+			}
+			entry.Score += 1.0 / float64(rrfK+rank+1)
+		}
+	}
+
+	results := make([]SearchResult, 0, len(fused))
+	for _, r := range fused {
+		results = append(results, *r)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		if results[i].Similarity != results[j].Similarity {
+			return results[i].Similarity > results[j].Similarity
+		}
+		return results[i].ChunkID < results[j].ChunkID
+	})
+
+	if len(results) > topK {
+		results = results[:topK]
+	}
+
+	return results
+}
+
+// buildMatchQuery turns free text into an FTS5 query
+// Each whitespace-separated term is quoted so that identifiers such as
+// ERR_AUTH_001 or config.key are matched literally, and terms are ORed so BM25 ranks them
+func buildMatchQuery(text string) string {
+	terms := strings.Fields(text)
+	quoted := make([]string, 0, len(terms))
+	for _, term := range terms {
+		term = strings.ReplaceAll(term, `"`, `""`)
+		quoted = append(quoted, `"`+term+`"`)
+	}
+	return strings.Join(quoted, " OR ")
+}
@@ -0,0 +1,313 @@
+package vectordb
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// insertSearchFixture inserts documents whose chunks all share the same embedding,
+// so that only the keyword ranking distinguishes them
+func insertSearchFixture(t *testing.T, db *DB) {
+	t.Helper()
+
+	docs := map[string][]string{
+		"errors.md": {"The server returns ERR_AUTH_001 when the token has expired.", "Retry with a fresh token."},
+		"config.md": {"Set cache.max_entries to limit memory usage.", "The default is 1000 entries."},
+	}
+
+	for filename, contents := range docs {
+		chunks := make([]ChunkInterface, len(contents))
+		embeddings := make([][]float32, len(contents))
+		for i, content := range contents {
+			chunks[i] = testChunk{content: content, position: i}
+			embeddings[i] = make([]float32, 384)
+			embeddings[i][0] = 1
+		}
+		if err := db.InsertDocument(filename, time.Now(), "", nil, chunks, embeddings); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestParseSearchMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    SearchMode
+		wantErr bool
+	}{
+		{"", ModeVector, false},
+		{"vector", ModeVector, false},
+		{"Keyword", ModeKeyword, false},
+		{"hybrid", ModeHybrid, false},
+		{"fuzzy", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseSearchMode(tt.name)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Expected error for %q, got nil", tt.name)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseSearchMode(%q) = %v, %v; want %v", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestBuildMatchQuery(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"ERR_AUTH_001", `"ERR_AUTH_001"`},
+		{"cache.max_entries  memory", `"cache.max_entries" OR "memory"`},
+		{`say "hi"`, `"say" OR """hi"""`},
+		{"   ", ""},
+	}
+
+	for _, tt := range tests {
+		if got := buildMatchQuery(tt.text); got != tt.want {
+			t.Errorf("buildMatchQuery(%q) = %s, want %s", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestFuseRRF(t *testing.T) {
+	vector := []SearchResult{{ChunkID: 1}, {ChunkID: 2}, {ChunkID: 3}}
+	keyword := []SearchResult{{ChunkID: 3}, {ChunkID: 4}}
+
+	results := fuseRRF(3, vector, keyword)
+
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, got %d", len(results))
+	}
+
+	// Chunk 3 appears in both lists and must rank first
+	if results[0].ChunkID != 3 {
+		t.Errorf("Expected chunk 3 first, got %d", results[0].ChunkID)
+	}
+	if results[1].ChunkID != 1 {
+		t.Errorf("Expected chunk 1 second, got %d", results[1].ChunkID)
+	}
+}
+
+func TestSearch_Keyword(t *testing.T) {
+	db, err := Init(t.TempDir()+"/test.db", 384)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if !db.HasKeywordIndex() {
+		t.Skip("SQLite built without FTS5")
+	}
+
+	insertSearchFixture(t, db)
+
+	results, err := db.Search(SearchQuery{Text: "ERR_AUTH_001", TopK: 5, Mode: ModeKeyword})
+	if err != nil {
+		t.Fatalf("Keyword search failed: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 result, got %d", len(results))
+	}
+	if results[0].DocumentName != "errors.md" || results[0].Position != 0 {
+		t.Errorf("Unexpected result: %s #%d", results[0].DocumentName, results[0].Position)
+	}
+
+	// Deleting the document must remove it from the keyword index
+	if err := db.DeleteDocument("errors.md"); err != nil {
+		t.Fatal(err)
+	}
+	results, err = db.Search(SearchQuery{Text: "ERR_AUTH_001", TopK: 5, Mode: ModeKeyword})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("Expected no results after delete, got %d", len(results))
+	}
+}
+
+func TestSearch_Hybrid(t *testing.T) {
+	db, err := Init(t.TempDir()+"/test.db", 384)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if !db.HasKeywordIndex() {
+		t.Skip("SQLite built without FTS5")
+	}
+
+	insertSearchFixture(t, db)
+
+	query := make([]float32, 384)
+	query[0] = 1
+
+	results, err := db.Search(SearchQuery{Text: "cache.max_entries", Vector: query, TopK: 2, Mode: ModeHybrid})
+	if err != nil {
+		t.Fatalf("Hybrid search failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+
+	// All vectors are identical, so the keyword hit decides the top result
+	if results[0].DocumentName != "config.md" || results[0].Position != 0 {
+		t.Errorf("Expected config.md #0 first, got %s #%d", results[0].DocumentName, results[0].Position)
+	}
+	if results[0].Similarity < 0.99 {
+		t.Errorf("Expected similarity to be filled in for keyword hits, got %f", results[0].Similarity)
+	}
+}
+
+func TestSearch_KeywordUnavailable(t *testing.T) {
+	db, err := Init(t.TempDir()+"/test.db", 384)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if db.HasKeywordIndex() {
+		t.Skip("SQLite built with FTS5")
+	}
+
+	if _, err := db.Search(SearchQuery{Text: "anything", TopK: 5, Mode: ModeKeyword}); err == nil {
+		t.Error("Expected error for keyword search without FTS5, got nil")
+	}
+}
+
+func TestInit_UpgradesVectorTableToCosine(t *testing.T) {
+	dbPath := t.TempDir() + "/test.db"
+
+	// Create a database with the original L2 vec0 table
+	db, err := Init(dbPath, 384)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks := []ChunkInterface{testChunk{content: "legacy chunk", position: 0}}
+	embedding := make([]float32, 384)
+	embedding[0] = 1
+	if err := db.InsertDocument("legacy.md", time.Now(), "", nil, chunks, [][]float32{embedding}); err != nil {
+		t.Fatal(err)
+	}
+	statements := []string{
+		"CREATE TEMP TABLE backup AS SELECT rowid AS id, embedding FROM vec_chunks",
+		"DROP TABLE vec_chunks",
+		"CREATE VIRTUAL TABLE vec_chunks USING vec0(embedding FLOAT[384])",
+		"INSERT INTO vec_chunks (rowid, embedding) SELECT id, embedding FROM backup",
+		"PRAGMA user_version = 2",
+	}
+	for _, stmt := range statements {
+		if _, err := db.conn.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+
+	// Reopen and verify the table was rebuilt with its vectors intact
+	db, err = Init(dbPath, 384)
+	if err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	defer db.Close()
+
+	var ddl string
+	if err := db.conn.QueryRow("SELECT sql FROM sqlite_master WHERE name = 'vec_chunks'").Scan(&ddl); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(ddl, "distance_metric=cosine") {
+		t.Errorf("Expected cosine metric after upgrade, got %s", ddl)
+	}
+
+	results, err := db.Search(SearchQuery{Vector: embedding, TopK: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Similarity < 0.99 {
+		t.Errorf("Expected the legacy chunk with similarity ~1, got %+v", results)
+	}
+}
+
+func TestSearch_TopKAboveKNNLimit(t *testing.T) {
+	db, err := Init(t.TempDir()+"/test.db", 384)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	insertSearchFixture(t, db)
+
+	query := make([]float32, 384)
+	query[0] = 1
+
+	results, err := db.Search(SearchQuery{Vector: query, TopK: maxKNN + 1})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 4 {
+		t.Errorf("Expected all 4 chunks, got %d", len(results))
+	}
+}
+
+func TestSearch_ReturnsHeadingPath(t *testing.T) {
+	db, err := Init(t.TempDir()+"/test.db", 384)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	chunks := []ChunkInterface{testChunk{content: "Refresh tokens expire after 30 days.", position: 0, headingPath: "Auth > JWT > Refresh tokens"}}
+	embedding := make([]float32, 384)
+	embedding[0] = 1
+	if err := db.InsertDocument("auth.md", time.Now(), "", nil, chunks, [][]float32{embedding}); err != nil {
+		t.Fatal(err)
+	}
+
+	modes := []SearchMode{ModeVector}
+	if db.HasKeywordIndex() {
+		modes = append(modes, ModeKeyword, ModeHybrid)
+	}
+	for _, mode := range modes {
+		results, err := db.Search(SearchQuery{Text: "refresh", Vector: embedding, TopK: 1, Mode: mode})
+		if err != nil {
+			t.Fatalf("%s search failed: %v", mode, err)
+		}
+		if len(results) != 1 || results[0].HeadingPath != "Auth > JWT > Refresh tokens" {
+			t.Errorf("%s search: expected heading path, got %+v", mode, results)
+		}
+	}
+}
+
+func TestInit_AddsHeadingPathColumn(t *testing.T) {
+	dbPath := t.TempDir() + "/test.db"
+
+	// Create a database whose chunks table predates heading_path
+	db, err := Init(dbPath, 384)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.conn.Exec("ALTER TABLE chunks DROP COLUMN heading_path"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.conn.Exec("PRAGMA user_version = 4"); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	db, err = Init(dbPath, 384)
+	if err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	defer db.Close()
+
+	chunks := []ChunkInterface{testChunk{content: "chunk", position: 0, headingPath: "Intro"}}
+	embedding := make([]float32, 384)
+	embedding[0] = 1
+	if err := db.InsertDocument("doc.md", time.Now(), "", nil, chunks, [][]float32{embedding}); err != nil {
+		t.Fatalf("InsertDocument failed after upgrade: %v", err)
+	}
+}
@@ -2,25 +2,48 @@ package vectordb
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"os"
+	"regexp"
+	"strconv"
 
 	sqlite_vec "github.com/asg017/sqlite-vec-go-bindings/cgo"
 	_ "github.com/mattn/go-sqlite3"
 )
 
 type DB struct {
-	conn *sql.DB
+	conn       *sql.DB
+	dimensions int  // embedding dimension of vec_chunks
+	fts        bool // FTS5 keyword index is available
 }
 
+// ErrDocumentNotFound is returned when a document is not in the index
+var ErrDocumentNotFound = errors.New("document not found")
+
+// ErrChunkNotFound is returned when a chunk ID is not in the index
+var ErrChunkNotFound = errors.New("chunk not found")
+
+// vecDimensionPattern extracts the embedding dimension from the vec_chunks schema
+var vecDimensionPattern = regexp.MustCompile(`(?i)FLOAT\[(\d+)\]`)
+
+var errFTSUnavailable = errors.New("keyword search requires SQLite with FTS5 (build with -tags sqlite_fts5)")
+
 // Init initializes the SQLite database
-func Init(dbPath string) (*DB, error) {
+// dimensions is the embedding dimension used when the vector table is created
+func Init(dbPath string, dimensions int) (*DB, error) {
+	if dimensions <= 0 {
+		return nil, fmt.Errorf("dimensions must be positive, got %d", dimensions)
+	}
+
 	fmt.Fprintf(os.Stderr, "[INFO] Initializing database: %s\n", dbPath)
 
 	// Enable sqlite-vec extension for all connections
 	sqlite_vec.Auto()
 
-	conn, err := sql.Open("sqlite3", dbPath)
+	// Foreign keys are a per-connection setting, so they are enabled in the DSN for
+	// every pooled connection; metadata and tags rely on ON DELETE CASCADE
+	conn, err := sql.Open("sqlite3", "file:"+dbPath+"?_foreign_keys=on")
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -34,21 +57,156 @@ func Init(dbPath string) (*DB, error) {
 	}
 	fmt.Fprintf(os.Stderr, "[INFO] sqlite-vec version: %s\n", vecVersion)
 
-	// Enable foreign keys
-	if _, err := conn.Exec("PRAGMA foreign_keys = ON"); err != nil {
+	// Create or upgrade the schema
+	if err := migrate(conn, dimensions); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to migrate schema: %w", err)
+	}
+
+	db := &DB{conn: conn}
+
+	// An existing vector table keeps the dimension it was created with
+	if err := db.loadDimensions(); err != nil {
 		conn.Close()
-		return nil, fmt.Errorf("failed to enable foreign keys: %w", err)
+		return nil, err
 	}
 
-	// Create tables (including vec_chunks virtual table)
-	if _, err := conn.Exec(schemaSQL); err != nil {
+	// Set up keyword index
+	if err := db.initFTS(); err != nil {
 		conn.Close()
-		return nil, fmt.Errorf("failed to create schema: %w", err)
+		return nil, fmt.Errorf("failed to initialize keyword index: %w", err)
 	}
 
 	fmt.Fprintf(os.Stderr, "[INFO] Database initialized successfully\n")
 
-	return &DB{conn: conn}, nil
+	return db, nil
+}
+
+// OpenReadOnly opens an existing database without the ability to modify it
+// The schema must already be at the latest version because migrations cannot run
+func OpenReadOnly(dbPath string) (*DB, error) {
+	fmt.Fprintf(os.Stderr, "[INFO] Opening database read-only: %s\n", dbPath)
+
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	// Enable sqlite-vec extension for all connections
+	sqlite_vec.Auto()
+
+	conn, err := sql.Open("sqlite3", "file:"+dbPath+"?mode=ro&_query_only=true")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	version, err := schemaVersion(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if version != latestSchemaVersion {
+		conn.Close()
+		return nil, fmt.Errorf("database schema version %d does not match the supported version %d, open it once without read-only mode to migrate it", version, latestSchemaVersion)
+	}
+
+	db := &DB{conn: conn}
+	if err := db.loadDimensions(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	// The keyword index can be used, but not created
+	if err := conn.QueryRow(
+		"SELECT sqlite_compileoption_used('ENABLE_FTS5') AND EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'trigger' AND name = ?)",
+		ftsTriggers[0],
+	).Scan(&db.fts); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to check keyword index: %w", err)
+	}
+	if !db.fts {
+		fmt.Fprintf(os.Stderr, "[WARN] Keyword index not available, keyword and hybrid search are disabled\n")
+	}
+
+	fmt.Fprintf(os.Stderr, "[INFO] Database opened successfully\n")
+
+	return db, nil
+}
+
+// loadDimensions reads the embedding dimension from the vec_chunks schema
+func (db *DB) loadDimensions() error {
+	var ddl string
+	if err := db.conn.QueryRow("SELECT sql FROM sqlite_master WHERE name = 'vec_chunks'").Scan(&ddl); err != nil {
+		return fmt.Errorf("failed to read vec_chunks schema: %w", err)
+	}
+
+	dimensions, err := parseVecDimensions(ddl)
+	if err != nil {
+		return err
+	}
+	db.dimensions = dimensions
+	return nil
+}
+
+// parseVecDimensions extracts the embedding dimension from a vec_chunks definition
+func parseVecDimensions(ddl string) (int, error) {
+	m := vecDimensionPattern.FindStringSubmatch(ddl)
+	if m == nil {
+		return 0, fmt.Errorf("failed to find embedding dimension in vec_chunks schema: %s", ddl)
+	}
+	return strconv.Atoi(m[1])
+}
+
+// initFTS creates the FTS5 mirror of chunks when SQLite supports it
+// Without FTS5 the sync triggers are dropped so that chunks stay writable;
+// the index is rebuilt from chunks the next time an FTS5 build opens the database
+func (db *DB) initFTS() error {
+	var enabled bool
+	if err := db.conn.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&enabled); err != nil {
+		return fmt.Errorf("failed to check FTS5 support: %w", err)
+	}
+
+	if !enabled {
+		fmt.Fprintf(os.Stderr, "[WARN] SQLite built without FTS5, keyword and hybrid search are disabled\n")
+		for _, trigger := range ftsTriggers {
+			if _, err := db.conn.Exec("DROP TRIGGER IF EXISTS " + trigger); err != nil {
+				return fmt.Errorf("failed to drop trigger %s: %w", trigger, err)
+			}
+		}
+		return nil
+	}
+
+	// A missing insert trigger means the table is new or was left stale by a build without FTS5
+	var triggerCount int
+	err := db.conn.QueryRow(
+		"SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name = ?", ftsTriggers[0],
+	).Scan(&triggerCount)
+	if err != nil {
+		return fmt.Errorf("failed to check FTS triggers: %w", err)
+	}
+
+	if _, err := db.conn.Exec(ftsSchemaSQL); err != nil {
+		return fmt.Errorf("failed to create FTS schema: %w", err)
+	}
+
+	if triggerCount == 0 {
+		if _, err := db.conn.Exec("INSERT INTO chunks_fts(chunks_fts) VALUES ('rebuild')"); err != nil {
+			return fmt.Errorf("failed to rebuild FTS index: %w", err)
+		}
+	}
+
+	db.fts = true
+	return nil
+}
+
+// Dimensions returns the embedding dimension of the vector table
+// For an existing database this is the dimension it was created with
+func (db *DB) Dimensions() int {
+	return db.dimensions
+}
+
+// HasKeywordIndex reports whether keyword and hybrid search are available
+func (db *DB) HasKeywordIndex() bool {
+	return db.fts
 }
 
 // Close closes the database connection
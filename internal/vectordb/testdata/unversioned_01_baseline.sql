@@ -0,0 +1,24 @@
+-- Schema of databases created before versioning: Initial release
+
+CREATE TABLE documents (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    filename TEXT NOT NULL UNIQUE,
+    indexed_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    modified_at DATETIME NOT NULL
+);
+
+CREATE INDEX idx_filename ON documents(filename);
+
+CREATE TABLE chunks (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    document_id INTEGER NOT NULL,
+    position INTEGER NOT NULL,
+    content TEXT NOT NULL,
+    FOREIGN KEY (document_id) REFERENCES documents(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_document_id ON chunks(document_id);
+
+CREATE VIRTUAL TABLE vec_chunks USING vec0(
+    embedding FLOAT[384]
+);
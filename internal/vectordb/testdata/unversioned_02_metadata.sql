@@ -0,0 +1,42 @@
+-- Schema of databases created before versioning: Document metadata and tags
+
+CREATE TABLE documents (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    filename TEXT NOT NULL UNIQUE,
+    indexed_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    modified_at DATETIME NOT NULL
+);
+
+CREATE INDEX idx_filename ON documents(filename);
+
+CREATE TABLE chunks (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    document_id INTEGER NOT NULL,
+    position INTEGER NOT NULL,
+    content TEXT NOT NULL,
+    FOREIGN KEY (document_id) REFERENCES documents(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_document_id ON chunks(document_id);
+
+CREATE TABLE document_metadata (
+    document_id INTEGER PRIMARY KEY,
+    domain TEXT NOT NULL DEFAULT '',
+    doc_type TEXT NOT NULL DEFAULT '',
+    language TEXT NOT NULL DEFAULT '',
+    project TEXT NOT NULL DEFAULT '',
+    FOREIGN KEY (document_id) REFERENCES documents(id) ON DELETE CASCADE
+);
+
+CREATE TABLE document_tags (
+    document_id INTEGER NOT NULL,
+    tag TEXT NOT NULL,
+    PRIMARY KEY (document_id, tag),
+    FOREIGN KEY (document_id) REFERENCES documents(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_document_tags_tag ON document_tags(tag);
+
+CREATE VIRTUAL TABLE vec_chunks USING vec0(
+    embedding FLOAT[384]
+);
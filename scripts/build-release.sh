@@ -34,9 +34,10 @@ build_platform() {
   echo "Building for ${goos}/${goarch}..."
 
   CGO_ENABLED=1 GOOS=$goos GOARCH=$goarch go build \
+    -tags sqlite_fts5 \
     -ldflags="$LDFLAGS" \
     -o "${DIST_DIR}/${output_name}" \
-    cmd/main.go
+    ./cmd
 
   if [ $? -eq 0 ]; then
     echo "✓ Built ${output_name}"